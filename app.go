@@ -11,6 +11,7 @@ import (
 
 	"github.com/cg917658910/win-pdf/internal/engine/v2"
 	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/wailsapp/wails/v2/pkg/menu"
@@ -69,11 +70,11 @@ func (a *App) initFonts() {
 		}
 	}
 	if len(files) == 0 {
-		rt.LogPrintf(a.ctx, "initFonts: no CJK fonts found in Windows\\Fonts")
+		logging.Warn("initFonts: no CJK fonts found in Windows\\Fonts")
 		return
 	}
 	if err := api.InstallFonts(files); err != nil {
-		rt.LogPrintf(a.ctx, "initFonts: install fonts error: %v", err)
+		logging.Error("initFonts: install fonts", "err", err)
 	}
 }
 
@@ -88,7 +89,7 @@ func (a *App) BeforeSetExpiry(opts engine.Options) (string, error) {
 	for _, f := range files {
 		info, err := os.Stat(f)
 		if err != nil {
-			rt.LogPrintf(a.ctx, "获取文件信息失败: %v", err)
+			logging.Error("获取文件信息失败", "err", err)
 			continue
 		}
 		totalSize += info.Size()
@@ -101,7 +102,7 @@ func (a *App) BeforeSetExpiry(opts engine.Options) (string, error) {
 			Message: fmt.Sprintf("当前添加的文档累计大小超过了1GB，如果在加密过程中出现电脑卡顿、死机或加密等待时间过长等现象，请退出程序同时减少文件数量并再次尝试加密；如果是单个文档请压缩后再次尝试加密，谢谢配合！"),
 		})
 		if err != nil {
-			rt.LogPrintf(a.ctx, "MessageDialog error: %v", err)
+			logging.Error("MessageDialog error", "err", err)
 			return "", err
 		}
 		return res, nil
@@ -109,6 +110,128 @@ func (a *App) BeforeSetExpiry(opts engine.Options) (string, error) {
 	return "继续操作", nil
 }
 
+// Preflight 在批量处理前逐个分析输入文档，返回每个文件的警告与建议操作
+func (a *App) Preflight(opts engine.Options) ([]engine.PreflightReport, error) {
+	reports, err := engine.PreflightBatch(opts)
+	if err != nil {
+		logging.Error("预检失败", "err", err)
+		return nil, fmt.Errorf("错误：请先添加要处理的文档")
+	}
+	return reports, nil
+}
+
+// ListPresets 返回已保存的预设
+func (a *App) ListPresets() ([]engine.Preset, error) {
+	presets, err := engine.ListPresets()
+	if err != nil {
+		logging.Error("读取预设失败", "err", err)
+		return nil, fmt.Errorf("读取预设失败：%v", err)
+	}
+	return presets, nil
+}
+
+// SavePreset 保存预设，同名预设被替换
+func (a *App) SavePreset(p engine.Preset) error {
+	return engine.SavePreset(p)
+}
+
+// DeletePreset 删除预设
+func (a *App) DeletePreset(name string) error {
+	return engine.DeletePreset(name)
+}
+
+// ImportPresets 选择预设文件并导入，返回导入的预设名称；取消选择时返回空列表
+func (a *App) ImportPresets() ([]string, error) {
+	path, err := rt.OpenFileDialog(a.ctx, rt.OpenDialogOptions{Title: "导入预设", Filters: []rt.FileFilter{
+		{DisplayName: "预设文件", Pattern: "*.json"},
+	}})
+	if err != nil || path == "" {
+		return nil, err
+	}
+	return engine.ImportPresets(path)
+}
+
+// ExportPresets 把全部预设导出到用户选择的文件，返回文件路径；取消选择时返回空字符串
+func (a *App) ExportPresets() (string, error) {
+	path, err := rt.SaveFileDialog(a.ctx, rt.SaveDialogOptions{Title: "导出预设", DefaultFilename: "presets.json", Filters: []rt.FileFilter{
+		{DisplayName: "预设文件", Pattern: "*.json"},
+	}})
+	if err != nil || path == "" {
+		return "", err
+	}
+	return path, engine.ExportPresets(path)
+}
+
+// SearchAudit 查询审计日志
+func (a *App) SearchAudit(q engine.AuditQuery) ([]engine.AuditEntry, error) {
+	entries, err := engine.SearchAudit(q)
+	if err != nil {
+		logging.Error("读取审计日志失败", "err", err)
+		return nil, fmt.Errorf("读取审计日志失败：%v", err)
+	}
+	return entries, nil
+}
+
+// ExportAudit 把符合条件的审计记录导出到用户选择的 CSV 或 JSON Lines 文件，返回文件路径；取消选择时返回空字符串
+func (a *App) ExportAudit(q engine.AuditQuery) (string, error) {
+	path, err := rt.SaveFileDialog(a.ctx, rt.SaveDialogOptions{Title: "导出审计日志", DefaultFilename: "audit.csv", Filters: []rt.FileFilter{
+		{DisplayName: "CSV 文件", Pattern: "*.csv"},
+		{DisplayName: "JSON Lines 文件", Pattern: "*.jsonl"},
+	}})
+	if err != nil || path == "" {
+		return "", err
+	}
+	q.Limit = 0
+	if _, err := engine.ExportAudit(path, q); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ListIssued 查询已发放文档登记表
+func (a *App) ListIssued(q engine.IssuedQuery) ([]engine.IssuedRecord, error) {
+	records, err := engine.ListIssued(q)
+	if err != nil {
+		logging.Error("读取登记表失败", "err", err)
+		return nil, fmt.Errorf("读取登记表失败：%v", err)
+	}
+	return records, nil
+}
+
+// RevokeIssued 把已发放的文档标记为已撤销
+func (a *App) RevokeIssued(id, reason string) error {
+	return engine.RevokeIssued(id, reason)
+}
+
+// RenewIssued 按登记的设置重新生成文档并设置新的有效期，返回新文档
+func (a *App) RenewIssued(id string, ro engine.RenewOptions) (*engine.IssuedDocument, error) {
+	return engine.RenewIssued(id, ro)
+}
+
+// ExportDiagnostics 把日志、运行环境、字体与 opts.Files 的预检报告打包到用户选择的 ZIP，返回文件路径；取消选择时返回空字符串。
+// 预检按界面当前的设置进行，与“预检”按钮的结果一致
+func (a *App) ExportDiagnostics(opts engine.Options) (string, error) {
+	path, err := rt.SaveFileDialog(a.ctx, rt.SaveDialogOptions{
+		Title:           "导出诊断信息",
+		DefaultFilename: "win-pdf-diagnostics-" + time.Now().Format("20060102-150405") + ".zip",
+		Filters:         []rt.FileFilter{{DisplayName: "ZIP 文件", Pattern: "*.zip"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	var files []string
+	for _, p := range strings.Split(opts.Files, ";") {
+		if p = strings.TrimSpace(p); p != "" {
+			files = append(files, p)
+		}
+	}
+	if err := engine.ExportDiagnostics(path, files, opts); err != nil {
+		logging.Error("导出诊断信息失败", "err", err)
+		return "", fmt.Errorf("导出诊断信息失败：%v", err)
+	}
+	return path, nil
+}
+
 // 设置有效期
 func (a *App) SetExpiry(opts engine.Options) (string, error) {
 	// 2.OutputDir不能为空
@@ -133,7 +256,7 @@ func (a *App) SetExpiry(opts engine.Options) (string, error) {
 	// 未注册用户只能处理最多1个文件
 	isActivated, _, err := license.IsActivated()
 	if err != nil {
-		rt.LogPrintf(a.ctx, "检查注册状态失败: %v", err)
+		logging.Error("检查注册状态失败", "err", err)
 	}
 	paths := strings.Split(opts.Files, ";")
 	if !isActivated {
@@ -143,7 +266,7 @@ func (a *App) SetExpiry(opts engine.Options) (string, error) {
 		for _, p := range paths {
 			info, err := os.Stat(p)
 			if err != nil {
-				rt.LogPrintf(a.ctx, "获取文件信息失败: %v", err)
+				logging.Error("获取文件信息失败", "err", err)
 				return "", errors.New("获取文件信息失败")
 			}
 			if info.Size() > 500*1024 {
@@ -152,6 +275,9 @@ func (a *App) SetExpiry(opts engine.Options) (string, error) {
 		}
 	}
 	successCount, err := engine.RunBatch(opts)
+	if err != nil {
+		logging.Error("批量处理失败", "succeeded", successCount, "files", len(paths), "err", err)
+	}
 	if successCount == 0 && err != nil {
 		return "", fmt.Errorf("%v", err)
 	}
@@ -180,7 +306,7 @@ func parseISOTimeRange(startStr, endStr string) (time.Time, time.Time, error) {
 func (a *App) IsRegistered() bool {
 	isActivated, _, err := license.IsActivated()
 	if err != nil {
-		rt.LogPrintf(a.ctx, "IsRegistered error: %v", err)
+		logging.Error("IsRegistered error", "err", err)
 		return false
 	}
 	return isActivated
@@ -194,7 +320,7 @@ func (a *App) GetMachineCode() (string, error) {
 // Register 尝试使用注册码注册应用
 func (a *App) Register(code string) (string, error) {
 	if err := license.ActivateWithRegCode(code); err != nil {
-		rt.LogPrintf(a.ctx, "Register error: %v", err)
+		logging.Error("Register error", "err", err)
 		return "", errors.New("注册失败")
 	}
 	// 发送事件，通知前端更新注册状态
@@ -208,7 +334,7 @@ func (a *App) Register(code string) (string, error) {
 func (a *App) OpenDirectoryAndListFiles() ([]string, error) {
 	dirPath, err := rt.OpenDirectoryDialog(a.ctx, rt.OpenDialogOptions{Title: "选择文件夹"})
 	if err != nil {
-		rt.LogPrintf(a.ctx, "OpenDirectoryDialog error: %v", err)
+		logging.Error("OpenDirectoryDialog error", "err", err)
 		return nil, err
 	}
 	// 列出目录下所有文件
@@ -245,10 +371,10 @@ func (a *App) OpenDirectoryDialog() (string, error) {
 	// 使用 Wails runtime 提供的 OpenDirectoryDialog
 	path, err := rt.OpenDirectoryDialog(a.ctx, rt.OpenDialogOptions{Title: "选择保存目录"})
 	if err != nil {
-		rt.LogPrintf(a.ctx, "OpenDirectoryDialog error: %v", err)
+		logging.Error("OpenDirectoryDialog error", "err", err)
 		return "", err
 	}
-	rt.LogPrintf(a.ctx, "用户选择的目录: %s", path)
+	logging.Info("用户选择的目录", "path", path)
 	return path, nil
 }
 
@@ -258,13 +384,13 @@ func (a *App) OpenMultipleFilesDialog() ([]string, error) {
 		{DisplayName: "PDF 文件", Pattern: "*.pdf"},
 	}})
 	if err != nil {
-		rt.LogPrintf(a.ctx, "OpenMultipleFilesDialog error: %v", err)
+		logging.Error("OpenMultipleFilesDialog error", "err", err)
 		return nil, err
 	}
 	// 未注册用户只能选择最多1个文件&&单个文件大小不能超过500kb
 	isActivated, _, err := license.IsActivated()
 	if err != nil {
-		rt.LogPrintf(a.ctx, "检查注册状态失败: %v", err)
+		logging.Error("检查注册状态失败", "err", err)
 	}
 	if !isActivated {
 		if len(paths) > 1 {
@@ -273,7 +399,7 @@ func (a *App) OpenMultipleFilesDialog() ([]string, error) {
 		for _, p := range paths {
 			info, err := os.Stat(p)
 			if err != nil {
-				rt.LogPrintf(a.ctx, "获取文件信息失败: %v", err)
+				logging.Error("获取文件信息失败", "err", err)
 				return nil, errors.New("获取文件信息失败")
 			}
 			if info.Size() > 500*1024 {
@@ -296,7 +422,7 @@ func (a *App) MessageDialog(title, message, dialogType string) (string, error) {
 	}
 	res, err := rt.MessageDialog(a.ctx, msgDialogOpts)
 	if err != nil {
-		rt.LogPrintf(a.ctx, "MessageDialog error: %v", err)
+		logging.Error("MessageDialog error", "err", err)
 		return "", err
 	}
 	return res, nil
@@ -318,7 +444,7 @@ func (a *App) GetTitleWithRegStatus() string {
 	title := "PDF文档有效期设置工具"
 	isActivated, _, err1 := license.IsActivated()
 	if err1 != nil {
-		rt.LogPrintf(a.ctx, "检查注册状态失败: %v", err1)
+		logging.Error("检查注册状态失败", "err", err1)
 	}
 	if isActivated {
 		title += "(已注册)"
@@ -336,7 +462,7 @@ func NewAppMenu(app *App) *menu.Menu {
 	FileMenu.AddText("选择文件", keys.CmdOrCtrl("o"), func(_ *menu.CallbackData) {
 		files, err := app.OpenMultipleFilesDialog()
 		if err != nil {
-			rt.LogPrintf(app.ctx, "OpenMultipleFilesDialog error: %v", err)
+			logging.Error("OpenMultipleFilesDialog error", "err", err)
 			rt.MessageDialog(app.ctx, rt.MessageDialogOptions{
 				Title:   "错误",
 				Message: fmt.Sprintf("选择文件出错：%v", err),
@@ -351,7 +477,7 @@ func NewAppMenu(app *App) *menu.Menu {
 	FileMenu.AddText("选择文件夹", keys.CmdOrCtrl("shift+o"), func(_ *menu.CallbackData) {
 		files, err := app.OpenDirectoryAndListFiles()
 		if err != nil {
-			rt.LogPrintf(app.ctx, "OpenDirectoryAndListFiles error: %v", err)
+			logging.Error("OpenDirectoryAndListFiles error", "err", err)
 			rt.MessageDialog(app.ctx, rt.MessageDialogOptions{
 				Title:   "错误",
 				Message: fmt.Sprintf("选择文件夹出错：%v", err),
@@ -363,6 +489,16 @@ func NewAppMenu(app *App) *menu.Menu {
 		rt.EventsEmit(app.ctx, "user:filesSelected", files)
 	})
 	FileMenu.AddSeparator()
+	FileMenu.AddText("审计日志...", nil, func(_ *menu.CallbackData) {
+		rt.EventsEmit(app.ctx, "menu:audit")
+	})
+	FileMenu.AddText("已发放文档...", nil, func(_ *menu.CallbackData) {
+		rt.EventsEmit(app.ctx, "menu:issued")
+	})
+	FileMenu.AddText("导出诊断信息...", nil, func(_ *menu.CallbackData) {
+		rt.EventsEmit(app.ctx, "menu:diagnostics")
+	})
+	FileMenu.AddSeparator()
 	FileMenu.AddText("退出", keys.CmdOrCtrl("q"), func(_ *menu.CallbackData) {
 		// `rt` is an alias of "github.com/wailsapp/wails/v2/pkg/runtime" to prevent collision with standard package
 		rt.Quit(app.ctx)
@@ -373,7 +509,7 @@ func NewAppMenu(app *App) *menu.Menu {
 		// 判断是否注册
 		isActivated, _, err := license.IsActivated()
 		if err != nil {
-			rt.LogPrintf(app.ctx, "检查注册状态失败: %v", err)
+			logging.Error("检查注册状态失败", "err", err)
 		}
 		if isActivated {
 			// 提示已经注册
@@ -5,16 +5,30 @@ import {context} from '../models';
 
 export function BeforeSetExpiry(arg1:engine.Options):Promise<string>;
 
+export function DeletePreset(arg1:string):Promise<void>;
+
+export function ExportAudit(arg1:engine.AuditQuery):Promise<string>;
+
+export function ExportDiagnostics(arg1:engine.Options):Promise<string>;
+
+export function ExportPresets():Promise<string>;
+
 export function GetMachineCode():Promise<string>;
 
 export function GetTitleWithRegStatus():Promise<string>;
 
 export function Greet(arg1:string):Promise<string>;
 
+export function ImportPresets():Promise<Array<string>>;
+
 export function IsRegistered():Promise<boolean>;
 
+export function ListIssued(arg1:engine.IssuedQuery):Promise<Array<engine.IssuedRecord>>;
+
 export function ListPDFInDir(arg1:string):Promise<Array<string>>;
 
+export function ListPresets():Promise<Array<engine.Preset>>;
+
 export function MessageDialog(arg1:string,arg2:string,arg3:string):Promise<string>;
 
 export function OnDomReady(arg1:context.Context):Promise<void>;
@@ -25,6 +39,16 @@ export function OpenDirectoryDialog():Promise<string>;
 
 export function OpenMultipleFilesDialog():Promise<Array<string>>;
 
+export function Preflight(arg1:engine.Options):Promise<Array<engine.PreflightReport>>;
+
 export function Register(arg1:string):Promise<string>;
 
+export function RenewIssued(arg1:string,arg2:engine.RenewOptions):Promise<engine.IssuedDocument>;
+
+export function RevokeIssued(arg1:string,arg2:string):Promise<void>;
+
+export function SavePreset(arg1:engine.Preset):Promise<void>;
+
+export function SearchAudit(arg1:engine.AuditQuery):Promise<Array<engine.AuditEntry>>;
+
 export function SetExpiry(arg1:engine.Options):Promise<string>;
@@ -6,6 +6,22 @@ export function BeforeSetExpiry(arg1) {
   return window['go']['main']['App']['BeforeSetExpiry'](arg1);
 }
 
+export function DeletePreset(arg1) {
+  return window['go']['main']['App']['DeletePreset'](arg1);
+}
+
+export function ExportAudit(arg1) {
+  return window['go']['main']['App']['ExportAudit'](arg1);
+}
+
+export function ExportDiagnostics(arg1) {
+  return window['go']['main']['App']['ExportDiagnostics'](arg1);
+}
+
+export function ExportPresets() {
+  return window['go']['main']['App']['ExportPresets']();
+}
+
 export function GetMachineCode() {
   return window['go']['main']['App']['GetMachineCode']();
 }
@@ -18,14 +34,26 @@ export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ImportPresets() {
+  return window['go']['main']['App']['ImportPresets']();
+}
+
 export function IsRegistered() {
   return window['go']['main']['App']['IsRegistered']();
 }
 
+export function ListIssued(arg1) {
+  return window['go']['main']['App']['ListIssued'](arg1);
+}
+
 export function ListPDFInDir(arg1) {
   return window['go']['main']['App']['ListPDFInDir'](arg1);
 }
 
+export function ListPresets() {
+  return window['go']['main']['App']['ListPresets']();
+}
+
 export function MessageDialog(arg1, arg2, arg3) {
   return window['go']['main']['App']['MessageDialog'](arg1, arg2, arg3);
 }
@@ -46,10 +74,30 @@ export function OpenMultipleFilesDialog() {
   return window['go']['main']['App']['OpenMultipleFilesDialog']();
 }
 
+export function Preflight(arg1) {
+  return window['go']['main']['App']['Preflight'](arg1);
+}
+
 export function Register(arg1) {
   return window['go']['main']['App']['Register'](arg1);
 }
 
+export function RenewIssued(arg1, arg2) {
+  return window['go']['main']['App']['RenewIssued'](arg1, arg2);
+}
+
+export function RevokeIssued(arg1, arg2) {
+  return window['go']['main']['App']['RevokeIssued'](arg1, arg2);
+}
+
+export function SavePreset(arg1) {
+  return window['go']['main']['App']['SavePreset'](arg1);
+}
+
+export function SearchAudit(arg1) {
+  return window['go']['main']['App']['SearchAudit'](arg1);
+}
+
 export function SetExpiry(arg1) {
   return window['go']['main']['App']['SetExpiry'](arg1);
 }
@@ -1,5 +1,149 @@
 export namespace engine {
 	
+	export class SignOptions {
+	    Enabled: boolean;
+	    CertPath: string;
+	    KeyPath: string;
+	    Password: string;
+	    Reason: string;
+	    Location: string;
+	    ContactInfo: string;
+	    Visible: boolean;
+	    Page: number;
+	    Rect: number[];
+	    TSAURL: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SignOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Enabled = source["Enabled"];
+	        this.CertPath = source["CertPath"];
+	        this.KeyPath = source["KeyPath"];
+	        this.Password = source["Password"];
+	        this.Reason = source["Reason"];
+	        this.Location = source["Location"];
+	        this.ContactInfo = source["ContactInfo"];
+	        this.Visible = source["Visible"];
+	        this.Page = source["Page"];
+	        this.Rect = source["Rect"];
+	        this.TSAURL = source["TSAURL"];
+	    }
+	}
+	export class ExpiredCover {
+	    Enabled: boolean;
+	    LogoPath: string;
+	    Title: string;
+	    Body: string;
+	    Contact: string;
+	    RenewalURL: string;
+	    Background: string;
+	    TitleColor: string;
+	    TextColor: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExpiredCover(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Enabled = source["Enabled"];
+	        this.LogoPath = source["LogoPath"];
+	        this.Title = source["Title"];
+	        this.Body = source["Body"];
+	        this.Contact = source["Contact"];
+	        this.RenewalURL = source["RenewalURL"];
+	        this.Background = source["Background"];
+	        this.TitleColor = source["TitleColor"];
+	        this.TextColor = source["TextColor"];
+	    }
+	}
+	export class MessageStyle {
+	    FontSize: number;
+	    Color: string;
+	    Align: string;
+	    VAlign: string;
+	    Margin: number;
+	    LineHeight: number;
+	    Background: string;
+	    Padding: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MessageStyle(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.FontSize = source["FontSize"];
+	        this.Color = source["Color"];
+	        this.Align = source["Align"];
+	        this.VAlign = source["VAlign"];
+	        this.Margin = source["Margin"];
+	        this.LineHeight = source["LineHeight"];
+	        this.Background = source["Background"];
+	        this.Padding = source["Padding"];
+	    }
+	}
+	export class Permissions {
+	    PrintLowRes: boolean;
+	    PrintHighRes: boolean;
+	    Modify: boolean;
+	    Copy: boolean;
+	    Annotate: boolean;
+	    FillForms: boolean;
+	    Accessibility: boolean;
+	    Assemble: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Permissions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.PrintLowRes = source["PrintLowRes"];
+	        this.PrintHighRes = source["PrintHighRes"];
+	        this.Modify = source["Modify"];
+	        this.Copy = source["Copy"];
+	        this.Annotate = source["Annotate"];
+	        this.FillForms = source["FillForms"];
+	        this.Accessibility = source["Accessibility"];
+	        this.Assemble = source["Assemble"];
+	    }
+	}
+	export class Recipient {
+	    CertPath: string;
+	    Permissions: Permissions;
+	
+	    static createFrom(source: any = {}) {
+	        return new Recipient(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.CertPath = source["CertPath"];
+	        this.Permissions = this.convertValues(source["Permissions"], Permissions);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Options {
 	    Input: string;
 	    Output: string;
@@ -17,10 +161,34 @@ export namespace engine {
 	    WatermarkDesc: string;
 	    WatermarkTiled: boolean;
 	    WatermarkSpacing: number;
+	    InputPassword: string;
+	    InputPasswords: Record<string, string>;
+	    InputPasswordFile: string;
+	    Recipients: Recipient[];
+	    EmbedFont: boolean;
+	    EmbedFontName: string;
+	    TextLang: string;
+	    MessageStyle: MessageStyle;
+	    ExpiredCover: ExpiredCover;
+	    FlattenAnnotations: boolean;
+	    Sign: SignOptions;
+	    SignedInputPolicy: string;
+	    OutputMode: string;
 	    AllowedPrint: boolean;
 	    AllowedCopy: boolean;
 	    AllowedEdit: boolean;
 	    AllowedConvert: boolean;
+	    Permissions?: Permissions;
+	    Encryption: string;
+	    PDFVersion: string;
+	    UserPasswordMode: string;
+	    PasswordRecipients: string[];
+	    PasswordLength: number;
+	    PasswordAlphabet: string;
+	    PasswordManifestFormat: string;
+	    PasswordManifestProtection: string;
+	    PasswordManifestPassword: string;
+	    Operator: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Options(source);
@@ -44,10 +212,412 @@ export namespace engine {
 	        this.WatermarkDesc = source["WatermarkDesc"];
 	        this.WatermarkTiled = source["WatermarkTiled"];
 	        this.WatermarkSpacing = source["WatermarkSpacing"];
+	        this.InputPassword = source["InputPassword"];
+	        this.InputPasswords = source["InputPasswords"];
+	        this.InputPasswordFile = source["InputPasswordFile"];
+	        this.Recipients = this.convertValues(source["Recipients"], Recipient);
+	        this.EmbedFont = source["EmbedFont"];
+	        this.EmbedFontName = source["EmbedFontName"];
+	        this.TextLang = source["TextLang"];
+	        this.MessageStyle = this.convertValues(source["MessageStyle"], MessageStyle);
+	        this.ExpiredCover = this.convertValues(source["ExpiredCover"], ExpiredCover);
+	        this.FlattenAnnotations = source["FlattenAnnotations"];
+	        this.Sign = this.convertValues(source["Sign"], SignOptions);
+	        this.SignedInputPolicy = source["SignedInputPolicy"];
+	        this.OutputMode = source["OutputMode"];
 	        this.AllowedPrint = source["AllowedPrint"];
 	        this.AllowedCopy = source["AllowedCopy"];
 	        this.AllowedEdit = source["AllowedEdit"];
 	        this.AllowedConvert = source["AllowedConvert"];
+	        this.Permissions = this.convertValues(source["Permissions"], Permissions);
+	        this.Encryption = source["Encryption"];
+	        this.PDFVersion = source["PDFVersion"];
+	        this.UserPasswordMode = source["UserPasswordMode"];
+	        this.PasswordRecipients = source["PasswordRecipients"];
+	        this.PasswordLength = source["PasswordLength"];
+	        this.PasswordAlphabet = source["PasswordAlphabet"];
+	        this.PasswordManifestFormat = source["PasswordManifestFormat"];
+	        this.PasswordManifestProtection = source["PasswordManifestProtection"];
+	        this.PasswordManifestPassword = source["PasswordManifestPassword"];
+	        this.Operator = source["Operator"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditEntry {
+	    // Go type: time
+	    StartedAt: any;
+	    // Go type: time
+	    FinishedAt: any;
+	    Input: string;
+	    InputSHA256: string;
+	    Output: string;
+	    OutputSHA256: string;
+	    DocumentID: string;
+	    InstanceID: string;
+	    Recipient: string;
+	    Operator: string;
+	    MachineID: string;
+	    Result: string;
+	    Error: string;
+	    Options: Options;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.StartedAt = this.convertValues(source["StartedAt"], null);
+	        this.FinishedAt = this.convertValues(source["FinishedAt"], null);
+	        this.Input = source["Input"];
+	        this.InputSHA256 = source["InputSHA256"];
+	        this.Output = source["Output"];
+	        this.OutputSHA256 = source["OutputSHA256"];
+	        this.DocumentID = source["DocumentID"];
+	        this.InstanceID = source["InstanceID"];
+	        this.Recipient = source["Recipient"];
+	        this.Operator = source["Operator"];
+	        this.MachineID = source["MachineID"];
+	        this.Result = source["Result"];
+	        this.Error = source["Error"];
+	        this.Options = this.convertValues(source["Options"], Options);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditQuery {
+	    Text: string;
+	    // Go type: time
+	    Since: any;
+	    // Go type: time
+	    Until: any;
+	    Result: string;
+	    MachineID: string;
+	    Limit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Text = source["Text"];
+	        this.Since = this.convertValues(source["Since"], null);
+	        this.Until = this.convertValues(source["Until"], null);
+	        this.Result = source["Result"];
+	        this.MachineID = source["MachineID"];
+	        this.Limit = source["Limit"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class IssuedDocument {
+	    ID: string;
+	    DocumentID: string;
+	    Source: string;
+	    SourceSHA256: string;
+	    Output: string;
+	    OutputSHA256: string;
+	    Recipient: string;
+	    Operator: string;
+	    // Go type: time
+	    IssuedAt: any;
+	    // Go type: time
+	    StartTime: any;
+	    // Go type: time
+	    EndTime: any;
+	    PasswordProtected: boolean;
+	    Revoked: boolean;
+	    // Go type: time
+	    RevokedAt: any;
+	    RevokeReason: string;
+	    RenewalOf: string;
+	    RenewedBy: string;
+	    Options: Options;
+	
+	    static createFrom(source: any = {}) {
+	        return new IssuedDocument(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ID = source["ID"];
+	        this.DocumentID = source["DocumentID"];
+	        this.Source = source["Source"];
+	        this.SourceSHA256 = source["SourceSHA256"];
+	        this.Output = source["Output"];
+	        this.OutputSHA256 = source["OutputSHA256"];
+	        this.Recipient = source["Recipient"];
+	        this.Operator = source["Operator"];
+	        this.IssuedAt = this.convertValues(source["IssuedAt"], null);
+	        this.StartTime = this.convertValues(source["StartTime"], null);
+	        this.EndTime = this.convertValues(source["EndTime"], null);
+	        this.PasswordProtected = source["PasswordProtected"];
+	        this.Revoked = source["Revoked"];
+	        this.RevokedAt = this.convertValues(source["RevokedAt"], null);
+	        this.RevokeReason = source["RevokeReason"];
+	        this.RenewalOf = source["RenewalOf"];
+	        this.RenewedBy = source["RenewedBy"];
+	        this.Options = this.convertValues(source["Options"], Options);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class IssuedQuery {
+	    Text: string;
+	    Status: string;
+	    // Go type: time
+	    ExpiresFrom: any;
+	    // Go type: time
+	    ExpiresTo: any;
+	    ExpiringDays: number;
+	    IncludeRenewed: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new IssuedQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Text = source["Text"];
+	        this.Status = source["Status"];
+	        this.ExpiresFrom = this.convertValues(source["ExpiresFrom"], null);
+	        this.ExpiresTo = this.convertValues(source["ExpiresTo"], null);
+	        this.ExpiringDays = source["ExpiringDays"];
+	        this.IncludeRenewed = source["IncludeRenewed"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class IssuedRecord {
+	    Document: IssuedDocument;
+	    Status: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new IssuedRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Document = this.convertValues(source["Document"], IssuedDocument);
+	        this.Status = source["Status"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	export class PreflightReport {
+	    File: string;
+	    Size: number;
+	    Pages: number;
+	    Version: string;
+	    CanProcess: boolean;
+	    Encrypted: boolean;
+	    NeedsPassword: boolean;
+	    WrongPassword: boolean;
+	    EncryptionHandler: string;
+	    Signed: boolean;
+	    XFA: boolean;
+	    PDFA: string;
+	    Tagged: boolean;
+	    EmptyPages: number[];
+	    MalformedXRef: boolean;
+	    HasOCGs: boolean;
+	    HasJavaScript: boolean;
+	    Protected: boolean;
+	    EstimatedMemory: number;
+	    EstimatedOutputSize: number;
+	    Warnings: string[];
+	    Actions: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PreflightReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.File = source["File"];
+	        this.Size = source["Size"];
+	        this.Pages = source["Pages"];
+	        this.Version = source["Version"];
+	        this.CanProcess = source["CanProcess"];
+	        this.Encrypted = source["Encrypted"];
+	        this.NeedsPassword = source["NeedsPassword"];
+	        this.WrongPassword = source["WrongPassword"];
+	        this.EncryptionHandler = source["EncryptionHandler"];
+	        this.Signed = source["Signed"];
+	        this.XFA = source["XFA"];
+	        this.PDFA = source["PDFA"];
+	        this.Tagged = source["Tagged"];
+	        this.EmptyPages = source["EmptyPages"];
+	        this.MalformedXRef = source["MalformedXRef"];
+	        this.HasOCGs = source["HasOCGs"];
+	        this.HasJavaScript = source["HasJavaScript"];
+	        this.Protected = source["Protected"];
+	        this.EstimatedMemory = source["EstimatedMemory"];
+	        this.EstimatedOutputSize = source["EstimatedOutputSize"];
+	        this.Warnings = source["Warnings"];
+	        this.Actions = source["Actions"];
+	    }
+	}
+	export class Preset {
+	    Name: string;
+	    Description: string;
+	    ValidDays: number;
+	    Options: Options;
+	    // Go type: time
+	    UpdatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Preset(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.Name = source["Name"];
+	        this.Description = source["Description"];
+	        this.ValidDays = source["ValidDays"];
+	        this.Options = this.convertValues(source["Options"], Options);
+	        this.UpdatedAt = this.convertValues(source["UpdatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class RenewOptions {
+	    ValidDays: number;
+	    UserPassword: string;
+	    InputPassword: string;
+	    SignPassword: string;
+	    Operator: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RenewOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ValidDays = source["ValidDays"];
+	        this.UserPassword = source["UserPassword"];
+	        this.InputPassword = source["InputPassword"];
+	        this.SignPassword = source["SignPassword"];
+	        this.Operator = source["Operator"];
 	    }
 	}
 
@@ -0,0 +1,237 @@
+// Package cms 生成 PDF 签名使用的 CMS SignedData 与证书加密使用的 EnvelopedData（RFC 5652）。
+//
+// 摘要算法固定为 SHA-256，签名属性只包含 content-type、message-digest 与 signing-certificate-v2，
+// 不带 signing-time（PAdES 要求签名时间写在签名字典的 /M 中）。
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	OIDData                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDAttrContentType        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OIDAttrMessageDigest      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OIDAttrSigningCertificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	OIDAttrTimeStampToken     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+	OIDTSTInfo                = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	OIDSHA256                 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRSAEncryption          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256        = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+// Signer 是签名者的私钥与证书，Chain 为中间证书（不含签名证书本身）。
+type Signer struct {
+	Key         crypto.Signer
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+}
+
+// SignedData 是已签名、可追加未签名属性（如时间戳）的 CMS 结构。
+type SignedData struct {
+	contentType   asn1.ObjectIdentifier
+	content       []byte // nil 表示分离式签名
+	certs         []*x509.Certificate
+	sid           []byte
+	sigAlg        []byte
+	signedAttrs   [][]byte
+	signature     []byte
+	unsignedAttrs [][]byte
+}
+
+// SignDetached 对外部内容的 SHA-256 摘要签名，生成分离式 SignedData（PDF 签名的 /Contents）。
+func SignDetached(s Signer, digest []byte) (*SignedData, error) {
+	return sign(s, OIDData, nil, digest)
+}
+
+// SignContent 签名并内嵌 content，contentType 为其类型（如时间戳令牌的 TSTInfo）。
+func SignContent(s Signer, contentType asn1.ObjectIdentifier, content []byte) (*SignedData, error) {
+	digest := sha256.Sum256(content)
+	return sign(s, contentType, content, digest[:])
+}
+
+func sign(s Signer, contentType asn1.ObjectIdentifier, content, digest []byte) (*SignedData, error) {
+	if s.Key == nil || s.Certificate == nil {
+		return nil, errors.New("cms: missing key or certificate")
+	}
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("cms: invalid sha-256 digest length %d", len(digest))
+	}
+
+	sd := &SignedData{
+		contentType: contentType,
+		content:     content,
+		certs:       append([]*x509.Certificate{s.Certificate}, s.Chain...),
+	}
+	var err error
+	if sd.sid, err = issuerAndSerial(s.Certificate); err != nil {
+		return nil, err
+	}
+
+	var sigOID asn1.ObjectIdentifier
+	switch s.Key.Public().(type) {
+	case *rsa.PublicKey:
+		sigOID = oidRSAEncryption
+	case *ecdsa.PublicKey:
+		sigOID = oidECDSAWithSHA256
+	default:
+		return nil, fmt.Errorf("cms: unsupported key type %T", s.Key.Public())
+	}
+	sd.sigAlg = algorithm(sigOID, sigOID.Equal(oidRSAEncryption))
+
+	certHash := sha256.Sum256(s.Certificate.Raw)
+	essCert, err := signingCertificateV2(s.Certificate, certHash[:])
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range []struct {
+		oid asn1.ObjectIdentifier
+		val interface{}
+	}{
+		{OIDAttrContentType, contentType},
+		{OIDAttrMessageDigest, digest},
+	} {
+		v, err := asn1.Marshal(a.val)
+		if err != nil {
+			return nil, err
+		}
+		sd.signedAttrs = append(sd.signedAttrs, Attribute(a.oid, v))
+	}
+	sd.signedAttrs = append(sd.signedAttrs, Attribute(OIDAttrSigningCertificate, essCert))
+
+	// 签名对象是 DER 编码的 SET OF Attribute（而非 [0] IMPLICIT）
+	attrsDigest := sha256.Sum256(set(sd.signedAttrs...))
+	if sd.signature, err = s.Key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256); err != nil {
+		return nil, fmt.Errorf("cms: sign: %w", err)
+	}
+	return sd, nil
+}
+
+// Signature 返回签名值，时间戳（RFC 3161）对它计算摘要。
+func (sd *SignedData) Signature() []byte {
+	return sd.signature
+}
+
+// AddUnsignedAttribute 追加一个未签名属性，value 为单个属性值的 DER 编码。
+func (sd *SignedData) AddUnsignedAttribute(oid asn1.ObjectIdentifier, value []byte) {
+	sd.unsignedAttrs = append(sd.unsignedAttrs, Attribute(oid, value))
+}
+
+// Bytes 返回 DER 编码的 ContentInfo。
+func (sd *SignedData) Bytes() ([]byte, error) {
+	oid := func(o asn1.ObjectIdentifier) []byte {
+		b, _ := asn1.Marshal(o)
+		return b
+	}
+	version := 1
+	if !sd.contentType.Equal(OIDData) {
+		version = 3
+	}
+	ver, _ := asn1.Marshal(version)
+
+	encap := [][]byte{oid(sd.contentType)}
+	if sd.content != nil {
+		octets, err := asn1.Marshal(sd.content)
+		if err != nil {
+			return nil, err
+		}
+		encap = append(encap, tagged(0, octets))
+	}
+
+	var certs [][]byte
+	for _, c := range sd.certs {
+		certs = append(certs, c.Raw)
+	}
+
+	signature, _ := asn1.Marshal(sd.signature)
+	siVer, _ := asn1.Marshal(1)
+	si := [][]byte{siVer, sd.sid, algorithm(OIDSHA256, false), implicitSet(0, sd.signedAttrs...), sd.sigAlg, signature}
+	if len(sd.unsignedAttrs) > 0 {
+		si = append(si, implicitSet(1, sd.unsignedAttrs...))
+	}
+
+	signedData := seq(
+		ver,
+		set(algorithm(OIDSHA256, false)),
+		seq(encap...),
+		implicitSet(0, certs...),
+		set(seq(si...)),
+	)
+	return seq(oid(OIDSignedData), tagged(0, signedData)), nil
+}
+
+// Attribute 编码 Attribute ::= SEQUENCE { attrType OID, attrValues SET OF ANY }。
+func Attribute(oid asn1.ObjectIdentifier, values ...[]byte) []byte {
+	b, _ := asn1.Marshal(oid)
+	return seq(b, set(values...))
+}
+
+// signingCertificateV2 编码 ESS SigningCertificateV2（RFC 5035），哈希算法为默认的 SHA-256 时省略。
+func signingCertificateV2(cert *x509.Certificate, certHash []byte) ([]byte, error) {
+	hash, err := asn1.Marshal(certHash)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	// IssuerSerial ::= SEQUENCE { issuer GeneralNames, serialNumber }，directoryName 为 [4] EXPLICIT Name
+	issuerSerial := seq(seq(tagged(4, cert.RawIssuer)), serial)
+	return seq(seq(seq(hash, issuerSerial))), nil
+}
+
+func issuerAndSerial(cert *x509.Certificate) ([]byte, error) {
+	serial, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return seq(cert.RawIssuer, serial), nil
+}
+
+func algorithm(oid asn1.ObjectIdentifier, nullParams bool) []byte {
+	b, _ := asn1.Marshal(oid)
+	if nullParams {
+		return seq(b, asn1.NullBytes)
+	}
+	return seq(b)
+}
+
+func seq(parts ...[]byte) []byte {
+	return raw(asn1.ClassUniversal, asn1.TagSequence, bytes.Join(parts, nil))
+}
+
+// set 编码 SET OF，DER 要求元素按编码排序。
+func set(parts ...[]byte) []byte {
+	return raw(asn1.ClassUniversal, asn1.TagSet, sortedJoin(parts))
+}
+
+func implicitSet(tag int, parts ...[]byte) []byte {
+	return raw(asn1.ClassContextSpecific, tag, sortedJoin(parts))
+}
+
+func tagged(tag int, inner []byte) []byte {
+	return raw(asn1.ClassContextSpecific, tag, inner)
+}
+
+func sortedJoin(parts [][]byte) []byte {
+	sorted := append([][]byte(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return bytes.Compare(sorted[i], sorted[j]) < 0 })
+	return bytes.Join(sorted, nil)
+}
+
+func raw(class, tag int, content []byte) []byte {
+	b, _ := asn1.Marshal(asn1.RawValue{Class: class, Tag: tag, IsCompound: true, Bytes: content})
+	return b
+}
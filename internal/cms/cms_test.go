@@ -0,0 +1,241 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// 测试用的 CMS 解析结构，只解析验证签名所需的字段
+type testContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type testSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContent     struct {
+		ContentType asn1.ObjectIdentifier
+		Content     []byte `asn1:"explicit,optional,tag:0"`
+	}
+	Certificates asn1.RawValue    `asn1:"optional,tag:0"`
+	SignerInfos  []testSignerInfo `asn1:"set"`
+}
+
+type testSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    asn1.RawValue
+	SignedAttrs        asn1.RawValue `asn1:"tag:0"`
+	SignatureAlgorithm asn1.RawValue
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type testAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// newTestChain 生成 CA 与由它签发的签名证书。
+func newTestChain(t *testing.T, key crypto.Signer) (Signer, *x509.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return Signer{Key: key, Certificate: cert, Chain: []*x509.Certificate{ca}}, ca
+}
+
+// verifySignedData 用 crypto/x509 验证 DER 编码的 SignedData：证书链、签名属性中的摘要与签名值。
+func verifySignedData(t *testing.T, der []byte, digest []byte, ca *x509.Certificate) testSignedData {
+	t.Helper()
+	var ci testContentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) > 0 {
+		t.Fatalf("parse ContentInfo: %v (%d trailing bytes)", err, len(rest))
+	}
+	if !ci.ContentType.Equal(OIDSignedData) {
+		t.Fatalf("content type = %s", ci.ContentType)
+	}
+	var sd testSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatalf("parse SignedData: %v", err)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		t.Fatalf("parse certificates: %v", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		t.Fatalf("signer infos = %d", len(sd.SignerInfos))
+	}
+	si := sd.SignerInfos[0]
+
+	// 按 issuerAndSerialNumber 找到签名证书，并验证到 CA 的证书链
+	var leaf *x509.Certificate
+	for _, c := range certs {
+		if sid, _ := issuerAndSerial(c); bytes.Equal(sid, si.SID.FullBytes) {
+			leaf = c
+		}
+	}
+	if leaf == nil {
+		t.Fatal("signer certificate not found in SignedData")
+	}
+	roots, inter := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, c := range certs {
+		if c != leaf {
+			inter.AddCert(c)
+		}
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: inter, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Fatalf("verify chain: %v", err)
+	}
+
+	// 签名属性按 SET OF 重新标记后计算签名
+	signed := append([]byte(nil), si.SignedAttrs.FullBytes...)
+	signed[0] = 0x31
+	alg := x509.SHA256WithRSA
+	if _, ok := leaf.PublicKey.(*ecdsa.PublicKey); ok {
+		alg = x509.ECDSAWithSHA256
+	}
+	if err := leaf.CheckSignature(alg, signed, si.Signature); err != nil {
+		t.Fatalf("check signature: %v", err)
+	}
+
+	var gotDigest []byte
+	var certHash []byte
+	for rest := si.SignedAttrs.Bytes; len(rest) > 0; {
+		var a testAttribute
+		if rest, err = asn1.Unmarshal(rest, &a); err != nil {
+			t.Fatalf("parse attribute: %v", err)
+		}
+		switch {
+		case a.Type.Equal(OIDAttrMessageDigest):
+			asn1.Unmarshal(a.Values.Bytes, &gotDigest)
+		case a.Type.Equal(OIDAttrSigningCertificate):
+			// SigningCertificateV2 ::= SEQUENCE { certs SEQUENCE OF ESSCertIDv2 }，ESSCertIDv2 以 certHash 开头
+			var v struct {
+				Certs []struct {
+					Hash   []byte
+					Issuer asn1.RawValue `asn1:"optional"`
+				}
+			}
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &v); err != nil || len(v.Certs) == 0 {
+				t.Fatalf("parse signing-certificate-v2: %v", err)
+			}
+			certHash = v.Certs[0].Hash
+		}
+	}
+	if !bytes.Equal(gotDigest, digest) {
+		t.Fatalf("message digest = %x, want %x", gotDigest, digest)
+	}
+	if want := sha256.Sum256(leaf.Raw); !bytes.Equal(certHash, want[:]) {
+		t.Fatalf("signing certificate hash = %x, want %x", certHash, want)
+	}
+	return sd
+}
+
+func TestSignDetachedVerifies(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, key := range map[string]crypto.Signer{"rsa": rsaKey, "ecdsa": ecKey} {
+		t.Run(name, func(t *testing.T) {
+			signer, ca := newTestChain(t, key)
+			digest := sha256.Sum256([]byte("%PDF-1.7 signed byte range"))
+			sd, err := SignDetached(signer, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			sd.AddUnsignedAttribute(OIDAttrTimeStampToken, []byte{0x05, 0x00})
+			der, err := sd.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := verifySignedData(t, der, digest[:], ca)
+			if got.EncapContent.Content != nil {
+				t.Fatal("detached signature carries content")
+			}
+			if len(got.SignerInfos[0].UnsignedAttrs.Bytes) == 0 {
+				t.Fatal("unsigned attribute missing")
+			}
+		})
+	}
+}
+
+func TestSignContentEmbedsContent(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, ca := newTestChain(t, key)
+	content := []byte("tst-info")
+	sd, err := SignContent(signer, OIDTSTInfo, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(content)
+	got := verifySignedData(t, der, digest[:], ca)
+	if got.Version != 3 || !got.EncapContent.ContentType.Equal(OIDTSTInfo) || !bytes.Equal(got.EncapContent.Content, content) {
+		t.Fatalf("encapsulated content = v%d %s %q", got.Version, got.EncapContent.ContentType, got.EncapContent.Content)
+	}
+}
+
+func TestSignRejectsBadDigest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, _ := newTestChain(t, key)
+	if _, err := SignDetached(signer, []byte("short")); err == nil {
+		t.Fatal("expected an error for a non SHA-256 digest")
+	}
+}
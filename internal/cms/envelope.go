@@ -0,0 +1,79 @@
+package cms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	OIDEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// Envelope 用随机的 AES-256 密钥加密 content，并用每个接收者证书的 RSA 公钥（PKCS#1 v1.5）封装该密钥，
+// 返回 DER 编码的 ContentInfo（EnvelopedData）。PDF 阅读器只支持密钥传输方式，不支持 EC 证书。
+func Envelope(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("cms: no recipients")
+	}
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	var infos [][]byte
+	for _, c := range recipients {
+		pub, ok := c.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("cms: recipient %q: unsupported key type %T, an RSA certificate is required", c.Subject.CommonName, c.PublicKey)
+		}
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, fmt.Errorf("cms: wrap key for %q: %w", c.Subject.CommonName, err)
+		}
+		rid, err := issuerAndSerial(c)
+		if err != nil {
+			return nil, err
+		}
+		ver, _ := asn1.Marshal(0)
+		encKey, _ := asn1.Marshal(ek)
+		// KeyTransRecipientInfo ::= SEQUENCE { version, rid, keyEncryptionAlgorithm, encryptedKey }
+		infos = append(infos, seq(ver, rid, algorithm(oidRSAEncryption, true), encKey))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	pad := aes.BlockSize - len(content)%aes.BlockSize
+	ciphertext := append(append([]byte(nil), content...), make([]byte, pad)...)
+	for i := len(content); i < len(ciphertext); i++ {
+		ciphertext[i] = byte(pad)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	oid := func(o asn1.ObjectIdentifier) []byte {
+		b, _ := asn1.Marshal(o)
+		return b
+	}
+	ivParam, _ := asn1.Marshal(iv)
+	ver, _ := asn1.Marshal(0)
+	// EncryptedContentInfo 的 encryptedContent 为 [0] IMPLICIT OCTET STRING
+	encContent, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext})
+	envelopedData := seq(
+		ver,
+		set(infos...),
+		seq(oid(OIDData), seq(oid(oidAES256CBC), ivParam), encContent),
+	)
+	return seq(oid(OIDEnvelopedData), tagged(0, envelopedData)), nil
+}
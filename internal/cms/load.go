@@ -0,0 +1,141 @@
+package cms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// LoadSigner 读取签名私钥与证书链。certPath 为 PKCS#12（.p12/.pfx）文件或 PEM 证书链，
+// keyPath 为 PEM 私钥，为空时从 certPath 中读取；password 用于 PKCS#12。
+// 签名证书为公钥与私钥匹配的那一张，其余作为中间证书。
+func LoadSigner(certPath, keyPath, password string) (Signer, error) {
+	var (
+		keys  []crypto.Signer
+		certs []*x509.Certificate
+	)
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return Signer{}, fmt.Errorf("read certificate: %w", err)
+	}
+
+	var blocks []*pem.Block
+	switch strings.ToLower(filepath.Ext(certPath)) {
+	case ".p12", ".pfx":
+		if blocks, err = pkcs12.ToPEM(data, password); err != nil {
+			if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+				return Signer{}, fmt.Errorf("incorrect PKCS#12 password")
+			}
+			// x/crypto/pkcs12 只支持传统的 3DES/RC2 加密，AES 加密的文件需先转换
+			return Signer{}, fmt.Errorf("decode PKCS#12 (AES-encrypted files must be re-exported with -legacy or converted to PEM): %w", err)
+		}
+	default:
+		blocks = pemBlocks(data)
+		if keyPath != "" && keyPath != certPath {
+			keyData, err := os.ReadFile(keyPath)
+			if err != nil {
+				return Signer{}, fmt.Errorf("read private key: %w", err)
+			}
+			blocks = append(blocks, pemBlocks(keyData)...)
+		}
+	}
+
+	for _, b := range blocks {
+		switch {
+		case b.Type == "CERTIFICATE":
+			c, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return Signer{}, fmt.Errorf("parse certificate: %w", err)
+			}
+			certs = append(certs, c)
+		case b.Type == "ENCRYPTED PRIVATE KEY" || b.Headers["Proc-Type"] == "4,ENCRYPTED":
+			return Signer{}, fmt.Errorf("encrypted PEM private keys are not supported, use PKCS#12 instead")
+		case strings.HasSuffix(b.Type, "PRIVATE KEY"):
+			k, err := parsePrivateKey(b.Bytes)
+			if err != nil {
+				return Signer{}, err
+			}
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return Signer{}, fmt.Errorf("no private key found")
+	}
+	if len(certs) == 0 {
+		return Signer{}, fmt.Errorf("no certificate found")
+	}
+
+	s := Signer{Key: keys[0]}
+	pub, ok := keys[0].Public().(interface{ Equal(crypto.PublicKey) bool })
+	for _, c := range certs {
+		if s.Certificate == nil && ok && pub.Equal(c.PublicKey) {
+			s.Certificate = c
+			continue
+		}
+		s.Chain = append(s.Chain, c)
+	}
+	if s.Certificate == nil {
+		return Signer{}, fmt.Errorf("no certificate matches the private key")
+	}
+	return s, nil
+}
+
+// LoadCertificate 读取 PEM 或 DER 编码的 X.509 证书，PEM 文件取第一张证书。
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read certificate: %w", err)
+	}
+	for _, b := range pemBlocks(data) {
+		if b.Type == "CERTIFICATE" {
+			data = b.Bytes
+			break
+		}
+	}
+	c, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate %s: %w", filepath.Base(path), err)
+	}
+	return c, nil
+}
+
+func pemBlocks(data []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for {
+		var b *pem.Block
+		if b, data = pem.Decode(data); b == nil {
+			return blocks
+		}
+		blocks = append(blocks, b)
+	}
+}
+
+// parsePrivateKey 依次尝试 PKCS#1、SEC1 与 PKCS#8 编码。
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", k)
+}
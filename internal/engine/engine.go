@@ -147,16 +147,16 @@ func exec(opt Options) error {
 	var permissions model.PermissionFlags = 0xF0C3 // PermissionsNone - 禁止所有操作
 
 	if opt.AllowedPrint {
-		permissions = model.PermissionsPrint
+		permissions |= model.PermissionsPrint
 	}
 	if opt.AllowedCopy {
-		permissions = model.PermissionExtract
+		permissions |= model.PermissionExtract
 	}
 	if opt.AllowedEdit {
-		permissions = model.PermissionModify
+		permissions |= model.PermissionModify
 	}
 	if opt.AllowedConvert {
-		permissions = model.PermissionAssembleRev3
+		permissions |= model.PermissionAssembleRev3
 	}
 	ctx.Permissions = permissions
 	fmt.Println("Applying time-limited two-layer protection completed.")
@@ -54,15 +54,113 @@ func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText,
 		fmt.Printf("injectOpenActionJS: %v\n", err)
 	}
 
-	ctx.RootDict["Names"] = types.Dict{
-		"JavaScript": types.Dict{
-			"Names": types.Array{
-				types.StringLiteral("OpenActionJS"),
-				*iref,
-			},
-		},
+	// 合并进原有的 Names/JavaScript 名称树，保留文档自身的文档级脚本
+	if err := addDocumentJavaScript(ctx, "OpenActionJS", *iref); err != nil {
+		fmt.Printf("addDocumentJavaScript: %v\n", err)
 	}
 
 	// Set OpenAction to the JavaScript action indirect reference.
 	ctx.RootDict["OpenAction"] = *iref
 }
+
+// addDocumentJavaScript 把 key -> ref 插入 Names/JavaScript 名称树，不存在时创建。
+func addDocumentJavaScript(ctx *model.Context, key string, ref types.IndirectRef) error {
+	names, err := ctx.DereferenceDict(ctx.RootDict["Names"])
+	if err != nil {
+		return err
+	}
+	if names == nil {
+		names = types.Dict{}
+		ctx.RootDict["Names"] = names
+	}
+	tree, err := ctx.DereferenceDict(names["JavaScript"])
+	if err != nil {
+		return err
+	}
+	if tree == nil {
+		names["JavaScript"] = types.Dict{"Names": types.Array{types.StringLiteral(key), ref}}
+		return nil
+	}
+	return insertIntoNameTree(ctx, tree, key, ref)
+}
+
+// insertIntoNameTree 按键顺序把 key 插入名称树节点，同名时替换，并更新沿途的 Limits。
+func insertIntoNameTree(ctx *model.Context, node types.Dict, key string, val types.Object) error {
+	if kids, err := ctx.DereferenceArray(node["Kids"]); err == nil && len(kids) > 0 {
+		// 选第一个上限不小于 key 的子节点，否则选最后一个
+		target := kids[len(kids)-1]
+		for _, kid := range kids {
+			kd, err := ctx.DereferenceDict(kid)
+			if err != nil || kd == nil {
+				continue
+			}
+			if _, hi, ok := nameTreeLimits(ctx, kd); ok && key <= hi {
+				target = kid
+				break
+			}
+		}
+		kd, err := ctx.DereferenceDict(target)
+		if err != nil || kd == nil {
+			return fmt.Errorf("invalid name tree kid: %v", err)
+		}
+		if err := insertIntoNameTree(ctx, kd, key, val); err != nil {
+			return err
+		}
+		updateNameTreeLimits(ctx, node, key)
+		return nil
+	}
+
+	arr, err := ctx.DereferenceArray(node["Names"])
+	if err != nil {
+		return err
+	}
+	i := 0
+	for ; i+1 < len(arr); i += 2 {
+		k, err := types.StringOrHexLiteral(arr[i])
+		if err != nil || k == nil {
+			continue
+		}
+		if *k == key {
+			arr[i+1] = val
+			node["Names"] = arr
+			return nil
+		}
+		if *k > key {
+			break
+		}
+	}
+	merged := append(types.Array{}, arr[:i]...)
+	merged = append(merged, types.StringLiteral(key), val)
+	merged = append(merged, arr[i:]...)
+	node["Names"] = merged
+	updateNameTreeLimits(ctx, node, key)
+	return nil
+}
+
+func nameTreeLimits(ctx *model.Context, node types.Dict) (lo, hi string, ok bool) {
+	limits, err := ctx.DereferenceArray(node["Limits"])
+	if err != nil || len(limits) != 2 {
+		return "", "", false
+	}
+	l, err1 := types.StringOrHexLiteral(limits[0])
+	h, err2 := types.StringOrHexLiteral(limits[1])
+	if err1 != nil || err2 != nil || l == nil || h == nil {
+		return "", "", false
+	}
+	return *l, *h, true
+}
+
+// updateNameTreeLimits 在节点带 Limits 时把 key 纳入范围（根节点没有 Limits）。
+func updateNameTreeLimits(ctx *model.Context, node types.Dict, key string) {
+	lo, hi, ok := nameTreeLimits(ctx, node)
+	if !ok {
+		return
+	}
+	if key < lo {
+		lo = key
+	}
+	if key > hi {
+		hi = key
+	}
+	node["Limits"] = types.Array{types.StringLiteral(lo), types.StringLiteral(hi)}
+}
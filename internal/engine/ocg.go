@@ -11,13 +11,23 @@ func ensureOCGs(ctx *model.Context) (types.IndirectRef, types.IndirectRef) {
 	normal := newOCG(ctx, "OCG_Normal")
 	fallback := newOCG(ctx, "OCG_Fallback")
 
-	ctx.RootDict["OCProperties"] = types.Dict{
-		"OCGs": types.Array{normal, fallback},
-		"D": types.Dict{
-			"ON":  types.Array{fallback}, // 默认只显示 Fallback
-			"OFF": types.Array{normal},
-		},
+	// 合并进原有的 OCProperties，保留文档自身的图层与配置
+	ocProps := types.Dict{}
+	if d, err := ctx.DereferenceDict(ctx.RootDict["OCProperties"]); err == nil && d != nil {
+		ocProps = d.Clone().(types.Dict)
+	}
+	ocgs, _ := ctx.DereferenceArray(ocProps["OCGs"])
+	ocProps["OCGs"] = append(append(types.Array{}, ocgs...), normal, fallback)
+	dd := types.Dict{}
+	if d, err := ctx.DereferenceDict(ocProps["D"]); err == nil && d != nil {
+		dd = d.Clone().(types.Dict)
 	}
+	on, _ := ctx.DereferenceArray(dd["ON"])
+	off, _ := ctx.DereferenceArray(dd["OFF"])
+	dd["ON"] = append(append(types.Array{}, on...), fallback) // 默认只显示 Fallback
+	dd["OFF"] = append(append(types.Array{}, off...), normal)
+	ocProps["D"] = dd
+	ctx.RootDict["OCProperties"] = ocProps
 
 	// Also add a mapping name->ref in the Root so JS can find OCGs by name if needed
 	ctx.RootDict["OCGNames"] = types.Dict{
@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// WinZip AES（AE-2）加密的 ZIP 条目，7-Zip、WinRAR 等可直接解压；Windows 资源管理器不支持，需要第三方解压工具。
+const (
+	zipMethodAES   = 99
+	zipAESExtraID  = 0x9901
+	zipAES256Salt  = 16
+	zipAESKeyLen   = 32
+	zipAESAuthLen  = 10
+	zipAESIterRate = 1000
+)
+
+// writeAESZip 把 content 压缩后以 WinZip AES-256 加密，作为 name 写入只含一个条目的 ZIP。
+func writeAESZip(w io.Writer, name string, content []byte, password string) error {
+	var deflated bytes.Buffer
+	fw, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(content); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	salt := make([]byte, zipAES256Salt)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	// 派生加密密钥、认证密钥与 2 字节的密码校验值
+	dk, err := pbkdf2.Key(sha1.New, password, salt, zipAESIterRate, 2*zipAESKeyLen+2)
+	if err != nil {
+		return err
+	}
+	encKey, authKey, verifier := dk[:zipAESKeyLen], dk[zipAESKeyLen:2*zipAESKeyLen], dk[2*zipAESKeyLen:]
+
+	ciphertext, err := aesCTRLittleEndian(encKey, deflated.Bytes())
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha1.New, authKey)
+	mac.Write(ciphertext)
+
+	// AES 扩展字段：版本 AE-2、厂商 "AE"、强度 3（AES-256）、实际的压缩方法
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], zipAESExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], 2)
+	copy(extra[6:], "AE")
+	extra[8] = 3
+	binary.LittleEndian.PutUint16(extra[9:], zip.Deflate)
+
+	// CreateRaw 不换算 Modified，直接填写 MS-DOS 格式的日期与时间
+	now := time.Now()
+	zw := zip.NewWriter(w)
+	entry, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zipMethodAES,
+		Flags:              0x1, // 已加密
+		ModifiedDate:       uint16((now.Year()-1980)<<9 | int(now.Month())<<5 | now.Day()),
+		ModifiedTime:       uint16(now.Hour()<<11 | now.Minute()<<5 | now.Second()/2),
+		Extra:              extra,
+		CompressedSize64:   uint64(len(salt) + len(verifier) + len(ciphertext) + zipAESAuthLen),
+		UncompressedSize64: uint64(len(content)),
+		// AE-2 不写 CRC，完整性由 HMAC 保证
+	})
+	if err != nil {
+		return err
+	}
+	for _, b := range [][]byte{salt, verifier, ciphertext, mac.Sum(nil)[:zipAESAuthLen]} {
+		if _, err := entry.Write(b); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// aesCTRLittleEndian 是 WinZip AES 使用的 CTR 模式：计数器从 1 开始，按小端序递增。
+func aesCTRLittleEndian(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		for j := range counter {
+			counter[j]++
+			if counter[j] != 0 {
+				break
+			}
+		}
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < len(data) && j < i+aes.BlockSize; j++ {
+			out[j] = data[j] ^ stream[j-i]
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// ctrBlock 按 WinZip AES 的定义构造第 n 个计数器块：n 以小端序写在块首，其余为 0。
+func ctrBlock(n uint64) []byte {
+	b := make([]byte, aes.BlockSize)
+	binary.LittleEndian.PutUint64(b, n)
+	return b
+}
+
+// decryptAESZipEntry 独立于 writeAESZip 解开 AE-2 条目：校验扩展字段、密码校验值与 HMAC，再解密并解压。
+func decryptAESZipEntry(t *testing.T, f *zip.File, password string) ([]byte, error) {
+	t.Helper()
+	if f.Method != zipMethodAES || f.Flags&0x1 == 0 {
+		t.Fatalf("method %d flags %#x", f.Method, f.Flags)
+	}
+	extra := f.Extra
+	if len(extra) != 11 || binary.LittleEndian.Uint16(extra) != 0x9901 || binary.LittleEndian.Uint16(extra[2:]) != 7 {
+		t.Fatalf("AES extra field %x", extra)
+	}
+	if version, vendor, strength := binary.LittleEndian.Uint16(extra[4:]), string(extra[6:8]), extra[8]; version != 2 || vendor != "AE" || strength != 3 {
+		t.Fatalf("AE-%d vendor %q strength %d", version, vendor, strength)
+	}
+	if f.CRC32 != 0 {
+		t.Fatal("AE-2 entries must not carry a CRC")
+	}
+	r, err := f.OpenRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt, verifier := raw[:16], raw[16:18]
+	ciphertext, mac := raw[18:len(raw)-10], raw[len(raw)-10:]
+
+	dk, err := pbkdf2.Key(sha1.New, password, salt, 1000, 66)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk[64:], verifier) {
+		return nil, errors.New("password verifier mismatch")
+	}
+	h := hmac.New(sha1.New, dk[32:64])
+	h.Write(ciphertext)
+	if !hmac.Equal(h.Sum(nil)[:10], mac) {
+		return nil, errors.New("authentication code mismatch")
+	}
+
+	block, err := aes.NewCipher(dk[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, len(ciphertext))
+	stream := make([]byte, aes.BlockSize)
+	for i := 0; i < len(ciphertext); i += aes.BlockSize {
+		block.Encrypt(stream, ctrBlock(uint64(i/aes.BlockSize+1)))
+		for j := i; j < len(ciphertext) && j < i+aes.BlockSize; j++ {
+			plain[j] = ciphertext[j] ^ stream[j-i]
+		}
+	}
+	return io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+}
+
+func TestAESZipDecryptsIndependently(t *testing.T) {
+	// 超过 256 个块，计数器的低字节会进位
+	content := []byte("file,recipient,password\n" + strings.Repeat("报价单.pdf,张三,Abc234xyz\n", 400))
+	var buf bytes.Buffer
+	if err := writeAESZip(&buf, "passwords.csv", content, "manifest secret"); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "passwords.csv" || zr.File[0].UncompressedSize64 != uint64(len(content)) {
+		t.Fatalf("entries %+v", zr.File)
+	}
+	got, err := decryptAESZipEntry(t, zr.File[0], "manifest secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatal("decrypted content differs")
+	}
+	if _, err := decryptAESZipEntry(t, zr.File[0], "wrong"); err == nil {
+		t.Fatal("entry opened with a wrong password")
+	}
+}
+
+func TestAESCTRLittleEndianCounter(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 257*aes.BlockSize+5)
+	got, err := aesCTRLittleEndian(key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 明文全 0 时密文就是密钥流，第 n 块为 AES(n)，n 从 1 开始
+	want := make([]byte, aes.BlockSize)
+	for _, n := range []uint64{1, 2, 255, 256, 257, 258} {
+		block.Encrypt(want, ctrBlock(n))
+		off := int(n-1) * aes.BlockSize
+		end := min(off+aes.BlockSize, len(data))
+		if !bytes.Equal(got[off:end], want[:end-off]) {
+			t.Fatalf("keystream block %d differs", n)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// 注释标志位（ISO 32000-1 12.5.3）
+const (
+	annotFlagHidden = 1 << 1
+	annotFlagNoView = 1 << 5
+)
+
+// annotationOC 返回注释应使用的 /OC：仅当所有 mask OCG 关闭（即在有效期内且阅读器执行了 JS）时可见，
+// 过期或阅读器不支持 JS 时隐藏。注释原有 /OC 时与之取“与”，原有图层控制继续生效。
+func (p *protection) annotationOC(orig types.Object) (types.Object, error) {
+	var origExpr types.Object
+	if orig != nil {
+		origExpr = ocVisibilityExpr(p.ctx, orig)
+	}
+	if origExpr == nil {
+		if p.annotOCMD == nil {
+			ocgs := types.Array{}
+			for _, r := range p.maskOCGs {
+				ocgs = append(ocgs, *r)
+			}
+			ref, err := p.ctx.IndRefForNewObject(types.Dict{
+				"Type": types.Name("OCMD"),
+				"OCGs": ocgs,
+				"P":    types.Name("AllOff"),
+			})
+			if err != nil {
+				return nil, err
+			}
+			p.annotOCMD = ref
+		}
+		return *p.annotOCMD, nil
+	}
+
+	ve := types.Array{types.Name("And"), origExpr}
+	ocgs := types.Array{}
+	for _, r := range p.maskOCGs {
+		ve = append(ve, types.Array{types.Name("Not"), *r})
+		ocgs = append(ocgs, *r)
+	}
+	// 不支持 VE 的阅读器退回 OCGs + P，只按保护状态控制
+	ref, err := p.ctx.IndRefForNewObject(types.Dict{
+		"Type": types.Name("OCMD"),
+		"OCGs": ocgs,
+		"P":    types.Name("AllOff"),
+		"VE":   ve,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return *ref, nil
+}
+
+// ocVisibilityExpr 把 OCG 或 OCMD 转换为可见性表达式（VE）；无效或不影响可见性时返回 nil。
+func ocVisibilityExpr(ctx *model.Context, oc types.Object) types.Object {
+	d, err := ctx.DereferenceDict(oc)
+	if err != nil || d == nil {
+		return nil
+	}
+	if t := d.NameEntry("Type"); t == nil || *t != "OCMD" {
+		return oc // OCG 本身即表达式
+	}
+	if ve, ok := d["VE"]; ok && ve != nil {
+		return ve
+	}
+
+	var ocgs types.Array
+	switch o := d["OCGs"].(type) {
+	case types.IndirectRef:
+		if arr, err := ctx.DereferenceArray(o); err == nil && arr != nil {
+			ocgs = arr
+		} else {
+			ocgs = types.Array{o}
+		}
+	case types.Array:
+		ocgs = o
+	}
+	if len(ocgs) == 0 {
+		return nil
+	}
+
+	policy := "AnyOn"
+	if p := d.NameEntry("P"); p != nil {
+		policy = *p
+	}
+	op := types.Name("Or")
+	if policy == "AllOn" || policy == "AllOff" {
+		op = types.Name("And")
+	}
+	expr := types.Array{op}
+	for _, g := range ocgs {
+		if policy == "AnyOff" || policy == "AllOff" {
+			expr = append(expr, types.Array{types.Name("Not"), g})
+		} else {
+			expr = append(expr, g)
+		}
+	}
+	return expr
+}
+
+// protectPageAnnotations 给页面上的注释设置 /OC，使其随保护状态显示或隐藏。
+// flatten 为 true 时，先把有外观流的注释与全部表单控件合并进页面内容（见 flattenPageAnnotations）。
+func (p *protection) protectPageAnnotations(pageDict types.Dict, inh *model.InheritedPageAttrs, flatten bool) error {
+	annots, err := p.ctx.DereferenceArray(pageDict["Annots"])
+	if err != nil || len(annots) == 0 {
+		return err
+	}
+	if flatten {
+		if annots, err = flattenPageAnnotations(p.ctx, pageDict, inh, annots); err != nil {
+			return fmt.Errorf("flatten annotations: %w", err)
+		}
+		if len(annots) == 0 {
+			delete(pageDict, "Annots")
+			return nil
+		}
+		pageDict["Annots"] = annots
+	}
+	for _, a := range annots {
+		d, err := p.ctx.DereferenceDict(a)
+		if err != nil || d == nil {
+			continue
+		}
+		oc, err := p.annotationOC(d["OC"])
+		if err != nil {
+			return err
+		}
+		d["OC"] = oc
+	}
+	return nil
+}
+
+// flattenPageAnnotations 把注释的正常外观（/AP /N）绘制进页面内容，返回剩余的注释。
+// 无外观的注释（如链接）保留；表单控件（Widget）一律移除，表单整体在 finish 中删除。
+func flattenPageAnnotations(ctx *model.Context, pageDict types.Dict, inh *model.InheritedPageAttrs, annots types.Array) (types.Array, error) {
+	// 资源复制一份再加入外观流，不改动可能被多个页面共用的资源字典
+	var res types.Dict
+	if r, err := ctx.DereferenceDict(pageDict["Resources"]); err == nil && r != nil {
+		res = r.Clone().(types.Dict)
+	} else if inh != nil && inh.Resources != nil {
+		res = inh.Resources.Clone().(types.Dict)
+	} else {
+		res = types.Dict{}
+	}
+	xo := types.Dict{}
+	if d, err := ctx.DereferenceDict(res["XObject"]); err == nil && d != nil {
+		xo = d.Clone().(types.Dict)
+	}
+
+	var (
+		kept    types.Array
+		content []byte
+		n       int
+	)
+	for _, a := range annots {
+		d, err := ctx.DereferenceDict(a)
+		if err != nil || d == nil {
+			continue
+		}
+		isWidget := d.NameEntry("Subtype") != nil && *d.NameEntry("Subtype") == "Widget"
+		ap, ok := annotationAppearance(ctx, d)
+		if !ok {
+			if !isWidget {
+				kept = append(kept, a)
+			}
+			continue
+		}
+		if f := d.IntEntry("F"); f != nil && *f&(annotFlagHidden|annotFlagNoView) != 0 {
+			continue
+		}
+		cm, ok := appearanceMatrix(ctx, d, ap)
+		if !ok {
+			continue
+		}
+		name := ""
+		for name == "" || xo[name] != nil {
+			n++
+			name = fmt.Sprintf("FlatAnnot%d", n)
+		}
+		xo[name] = ap
+		content = append(content, fmt.Sprintf("q\n%s cm\n/%s Do\nQ\n", cm, name)...)
+	}
+	if len(content) == 0 {
+		return kept, nil
+	}
+	res["XObject"] = xo
+	pageDict["Resources"] = res
+
+	// 在原内容前后分别插入 q / Q，隔离原内容残留的图形状态
+	var contents types.Array
+	switch c := pageDict["Contents"].(type) {
+	case types.Array:
+		contents = c
+	case nil:
+	default:
+		contents = types.Array{c}
+	}
+	head, err := newContentStream(ctx, []byte("q\n"))
+	if err != nil {
+		return nil, err
+	}
+	tail, err := newContentStream(ctx, append([]byte("Q\n"), content...))
+	if err != nil {
+		return nil, err
+	}
+	pageDict["Contents"] = append(append(types.Array{*head}, contents...), *tail)
+	return kept, nil
+}
+
+// annotationAppearance 返回注释当前状态的正常外观流引用。
+func annotationAppearance(ctx *model.Context, d types.Dict) (types.Object, bool) {
+	ap, err := ctx.DereferenceDict(d["AP"])
+	if err != nil || ap == nil {
+		return nil, false
+	}
+	n, ok := ap["N"]
+	if !ok || n == nil {
+		return nil, false
+	}
+	// /N 为状态字典时（复选框、单选按钮）按 /AS 选择
+	if states, err := ctx.DereferenceDict(n); err == nil && states != nil {
+		as := d.NameEntry("AS")
+		if as == nil {
+			return nil, false
+		}
+		n, ok = states[*as]
+		if !ok || n == nil {
+			return nil, false
+		}
+	}
+	if _, ok := n.(types.IndirectRef); !ok {
+		return nil, false
+	}
+	if sd, _, err := ctx.DereferenceStreamDict(n); err != nil || sd == nil {
+		return nil, false
+	}
+	return n, true
+}
+
+// appearanceMatrix 按 ISO 32000-1 12.5.5 计算把外观流放进注释 Rect 的变换矩阵：
+// 外观 BBox 经其 Matrix 变换后的包围盒缩放平移到 Rect。
+func appearanceMatrix(ctx *model.Context, d types.Dict, ap types.Object) (string, bool) {
+	rect, err := rectFromObject(ctx, d["Rect"])
+	if err != nil || rect == nil {
+		return "", false
+	}
+	sd, _, err := ctx.DereferenceStreamDict(ap)
+	if err != nil || sd == nil {
+		return "", false
+	}
+	bbox, err := rectFromObject(ctx, sd.Dict["BBox"])
+	if err != nil || bbox == nil {
+		return "", false
+	}
+	m := [6]float64{1, 0, 0, 1, 0, 0}
+	if arr, err := ctx.DereferenceArray(sd.Dict["Matrix"]); err == nil && len(arr) == 6 {
+		for i, o := range arr {
+			if v, err := ctx.DereferenceNumber(o); err == nil {
+				m[i] = v
+			}
+		}
+	}
+	// 变换后的 BBox 包围盒
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, pt := range [][2]float64{{bbox.LL.X, bbox.LL.Y}, {bbox.UR.X, bbox.LL.Y}, {bbox.LL.X, bbox.UR.Y}, {bbox.UR.X, bbox.UR.Y}} {
+		x := m[0]*pt[0] + m[2]*pt[1] + m[4]
+		y := m[1]*pt[0] + m[3]*pt[1] + m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	if maxX-minX <= 0 || maxY-minY <= 0 {
+		return "", false
+	}
+	sx := rect.Width() / (maxX - minX)
+	sy := rect.Height() / (maxY - minY)
+	return fmt.Sprintf("%.4f 0 0 %.4f %.4f %.4f", sx, sy, rect.LL.X-minX*sx, rect.LL.Y-minY*sy), true
+}
+
+// rectFromObject 解析矩形数组并规范为左下、右上两点。
+func rectFromObject(ctx *model.Context, o types.Object) (*types.Rectangle, error) {
+	arr, err := ctx.DereferenceArray(o)
+	if err != nil || len(arr) != 4 {
+		return nil, fmt.Errorf("invalid rectangle: %v", o)
+	}
+	var v [4]float64
+	for i, e := range arr {
+		if v[i], err = ctx.DereferenceNumber(e); err != nil {
+			return nil, err
+		}
+	}
+	return types.NewRectangle(math.Min(v[0], v[2]), math.Min(v[1], v[3]), math.Max(v[0], v[2]), math.Max(v[1], v[3])), nil
+}
+
+func newContentStream(ctx *model.Context, b []byte) (*types.IndirectRef, error) {
+	sd, err := ctx.NewStreamDictForBuf(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
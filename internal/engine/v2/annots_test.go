@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// pageAnnots 返回第一页上的注释字典。
+func pageAnnots(t *testing.T, ctx *model.Context) []types.Dict {
+	t.Helper()
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr, _ := ctx.DereferenceArray(pageDict["Annots"])
+	var annots []types.Dict
+	for _, a := range arr {
+		d, err := ctx.DereferenceDict(a)
+		if err != nil || d == nil {
+			t.Fatalf("resolve annotation %v: %v", a, err)
+		}
+		annots = append(annots, d)
+	}
+	return annots
+}
+
+// checkMaskOCMD 检查 oc 是只在全部遮罩关闭时可见的 OCMD，返回它的字典。
+func checkMaskOCMD(t *testing.T, ctx *model.Context, oc types.Object) types.Dict {
+	t.Helper()
+	d, err := ctx.DereferenceDict(oc)
+	if err != nil || d == nil {
+		t.Fatalf("annotation /OC %v: %v", oc, err)
+	}
+	ocgs, _ := d["OCGs"].(types.Array)
+	if p := d.NameEntry("P"); p == nil || *p != "AllOff" || len(ocgs) != maskNum {
+		t.Fatalf("/OC = %v, want an AllOff OCMD over the masks", d)
+	}
+	for _, g := range ocgs {
+		if !isEngineOCG(ctx, g) {
+			t.Fatalf("OCMD member %v is not an engine OCG", g)
+		}
+	}
+	return d
+}
+
+func TestAnnotationsHiddenByMasks(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	var layer types.IndirectRef
+	editTestPDF(t, input, func(ctx *model.Context) {
+		ref, err := ctx.IndRefForNewObject(types.Dict{"Type": types.Name("OCG"), "Name": types.StringLiteral("Notes")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		layer = *ref
+		ctx.RootDict["OCProperties"] = types.Dict{
+			"OCGs": types.Array{layer},
+			"D":    types.Dict{"ON": types.Array{layer}},
+		}
+		rect := types.NewRectangle(72, 600, 92, 620).Array()
+		addTestAnnotations(t, ctx,
+			types.Dict{"Subtype": types.Name("Text"), "Rect": rect, "Contents": types.StringLiteral("plain")},
+			types.Dict{"Subtype": types.Name("Text"), "Rect": rect, "Contents": types.StringLiteral("layered"), "OC": layer},
+		)
+	})
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := readTestPDF(t, opt.Output, "")
+	annots := pageAnnots(t, ctx)
+	if len(annots) != 2 {
+		t.Fatalf("%d annotations, want 2", len(annots))
+	}
+
+	// 没有 /OC 的注释使用共用的 OCMD
+	checkMaskOCMD(t, ctx, annots[0]["OC"])
+
+	// 原有 /OC 与遮罩条件取“与”：VE 为 [/And 原图层 [/Not 遮罩]...]
+	d := checkMaskOCMD(t, ctx, annots[1]["OC"])
+	ve, _ := d["VE"].(types.Array)
+	if len(ve) != 2+maskNum || ve[0] != types.Name("And") {
+		t.Fatalf("VE = %v", ve)
+	}
+	if ref, ok := ve[1].(types.IndirectRef); !ok || ref.ObjectNumber != layer.ObjectNumber {
+		t.Fatalf("VE does not keep the original layer: %v", ve)
+	}
+	for _, e := range ve[2:] {
+		if not, ok := e.(types.Array); !ok || len(not) != 2 || not[0] != types.Name("Not") || !isEngineOCG(ctx, not[1]) {
+			t.Fatalf("VE term %v, want [/Not mask]", e)
+		}
+	}
+	if annots[0]["OC"] == annots[1]["OC"] {
+		t.Fatal("layered annotation shares the plain OCMD")
+	}
+}
+
+func TestFlattenAnnotations(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	editTestPDF(t, input, func(ctx *model.Context) {
+		sd, err := ctx.NewStreamDictForBuf([]byte("0 0 1 rg 0 0 20 20 re f"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd.InsertName("Type", "XObject")
+		sd.InsertName("Subtype", "Form")
+		sd.Insert("BBox", types.NewRectangle(0, 0, 20, 20).Array())
+		if err := sd.Encode(); err != nil {
+			t.Fatal(err)
+		}
+		ap, err := ctx.IndRefForNewObject(*sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.RootDict["AcroForm"] = types.Dict{"Fields": types.Array{}}
+		rect := types.NewRectangle(72, 600, 92, 620).Array()
+		addTestAnnotations(t, ctx,
+			types.Dict{"Subtype": types.Name("Square"), "Rect": rect, "AP": types.Dict{"N": *ap}},
+			types.Dict{"Subtype": types.Name("Link"), "Rect": rect, "A": types.Dict{"S": types.Name("URI"), "URI": types.StringLiteral("https://example.com")}},
+			types.Dict{"Subtype": types.Name("Widget"), "FT": types.Name("Tx"), "T": types.StringLiteral("name"), "Rect": rect},
+		)
+	})
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.FlattenAnnotations = true
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := readTestPDF(t, opt.Output, "")
+
+	// 有外观的注释并入页面内容，表单控件与 AcroForm 删除，没有外观的链接保留并受保护
+	annots := pageAnnots(t, ctx)
+	if len(annots) != 1 || *annots[0].NameEntry("Subtype") != "Link" {
+		t.Fatalf("annotations after flattening: %v", annots)
+	}
+	checkMaskOCMD(t, ctx, annots[0]["OC"])
+	if _, ok := ctx.RootDict["AcroForm"]; ok {
+		t.Fatal("AcroForm kept after flattening")
+	}
+
+	// 原内容（含展平的外观）包装在 NormalContent 中
+	pageDict, _, _, _ := ctx.PageDict(1, false)
+	res, _ := ctx.DereferenceDict(pageDict["Resources"])
+	xobj, _ := ctx.DereferenceDict(res["XObject"])
+	normal, _, err := ctx.DereferenceStreamDict(xobj[resNormalContent])
+	if err != nil || normal == nil {
+		t.Fatalf("no %s form: %v", resNormalContent, err)
+	}
+	if err := normal.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(normal.Content, []byte("/FlatAnnot1 Do")) || !bytes.Contains(normal.Content, []byte("(Page 1) Tj")) {
+		t.Fatalf("flattened appearance not drawn in the page content:\n%s", normal.Content)
+	}
+	nres, _ := ctx.DereferenceDict(normal.Dict["Resources"])
+	nxo, _ := ctx.DereferenceDict(nres["XObject"])
+	flat, _, err := ctx.DereferenceStreamDict(nxo["FlatAnnot1"])
+	if err != nil || flat == nil {
+		t.Fatalf("FlatAnnot1 = %v: %v", nxo["FlatAnnot1"], err)
+	}
+	if err := flat.Decode(); err != nil || !bytes.Contains(flat.Content, []byte("0 0 20 20 re f")) {
+		t.Fatalf("FlatAnnot1 is not the appearance stream: %q, %v", flat.Content, err)
+	}
+}
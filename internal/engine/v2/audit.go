@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
+)
+
+const auditFileName = "audit.jsonl"
+
+// 审计结果（AuditEntry.Result）
+const (
+	AuditOK     = "ok"
+	AuditFailed = "failed"
+)
+
+// AuditEntry 是审计日志中的一条记录，每处理一个文件追加一条（JSON Lines）。
+type AuditEntry struct {
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Input        string
+	InputSHA256  string
+	Output       string // 处理失败时为空
+	OutputSHA256 string
+	DocumentID   string // 输出 trailer 中 ID 的两项
+	InstanceID   string
+	Recipient    string // 按接收者生成密码时的接收者
+	Operator     string
+	MachineID    string // 本机标识，见 MachineIDFor；不记录可用于生成注册码的机器码
+	Result       string // ok 或 failed，见 Audit* 常量
+	Error        string
+	// 处理设置，不含任何密码
+	Options Options
+}
+
+// AuditQuery 是审计日志的查询条件，零值匹配全部记录。
+type AuditQuery struct {
+	// 在文件名、路径、哈希、文档 ID、接收者、操作人中查找（不区分大小写）
+	Text   string
+	Since  time.Time
+	Until  time.Time
+	Result string
+	// 只返回该本机标识的记录（见 MachineIDFor）
+	MachineID string
+	// 只返回最新的 Limit 条，0 表示不限
+	Limit int
+}
+
+var auditMu sync.Mutex
+
+// auditPath 返回审计日志路径，与 activation.json 位于同一配置目录。
+func auditPath() (string, error) {
+	d, err := license.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(d, auditFileName), nil
+}
+
+// operatorName 返回审计记录的操作人：Options.Operator，为空时为当前系统用户。
+func operatorName(opt Options) string {
+	if op := strings.TrimSpace(opt.Operator); op != "" {
+		return op
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// machineIDDomain 是计算本机标识时加在机器码前的固定前缀，使标识与其他由机器码派生的值无关。
+const machineIDDomain = "win-pdf audit machine id\x00"
+
+// MachineIDFor 由机器码（可带 4-4 格式的分隔符）计算审计记录中的本机标识：带固定前缀的 SHA-256，取前 16 个十六进制字符。
+// 同一台机器（重装后也一样）的标识相同，客服可由用户提供的机器码算出标识并对照记录，但无法由标识还原机器码。
+func MachineIDFor(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(machineIDDomain + code))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// machineID 返回本机的标识，取不到机器码时返回空串。
+func machineID() string {
+	code, err := license.GetMachineCode()
+	if err != nil {
+		return ""
+	}
+	return MachineIDFor(code)
+}
+
+// processFile 处理单个文件并追加审计记录，成功时同时返回待登记的文档（由调用方用 registerIssued 登记，批量处理时一次写入）。
+// 审计日志写入失败或无法生成登记记录只打印警告，不影响处理结果；此时返回的文档 ID 为空。
+func processFile(opt Options, recipient string) (string, IssuedDocument, error) {
+	e := AuditEntry{
+		StartedAt: time.Now(),
+		Input:     opt.Input,
+		Recipient: recipient,
+		Operator:  operatorName(opt),
+		Options:   sanitizedOptions(opt),
+	}
+	if abs, err := filepath.Abs(opt.Input); err == nil {
+		e.Input = abs
+	}
+	e.InputSHA256, _ = fileSHA256(opt.Input)
+	e.MachineID = machineID()
+
+	out, err := runFile(opt)
+
+	e.FinishedAt = time.Now()
+	e.Result = AuditOK
+	if err != nil {
+		e.Result, e.Error = AuditFailed, err.Error()
+	} else {
+		e.Output = out
+		if abs, err := filepath.Abs(out); err == nil {
+			e.Output = abs
+		}
+		e.OutputSHA256, _ = fileSHA256(out)
+		e.DocumentID, e.InstanceID = fileIDs(out)
+	}
+	if aerr := appendAudit(e); aerr != nil {
+		logging.Warn("write audit log", "err", aerr)
+	}
+	if err != nil {
+		return out, IssuedDocument{}, err
+	}
+	doc, derr := issuedDocument(e, opt)
+	if derr != nil {
+		logging.Warn("register issued document", "output", out, "err", derr)
+	}
+	return out, doc, nil
+}
+
+// appendAudit 以追加方式写入一条记录，新建的审计日志仅当前用户可读写。
+func appendAudit(e AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	p, err := auditPath()
+	if err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (q AuditQuery) match(e AuditEntry) bool {
+	if !q.Since.IsZero() && e.StartedAt.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && e.StartedAt.After(q.Until) {
+		return false
+	}
+	if q.Result != "" && e.Result != q.Result {
+		return false
+	}
+	if q.MachineID != "" && !strings.EqualFold(e.MachineID, q.MachineID) {
+		return false
+	}
+	if text := strings.ToLower(strings.TrimSpace(q.Text)); text != "" {
+		for _, f := range []string{e.Input, e.Output, e.InputSHA256, e.OutputSHA256, e.DocumentID, e.InstanceID, e.Recipient, e.Operator} {
+			if strings.Contains(strings.ToLower(f), text) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SearchAudit 按条件查询审计日志，结果按时间从新到旧排列。无法解析的行（例如写入中断留下的半行）会被跳过。
+func SearchAudit(q AuditQuery) ([]AuditEntry, error) {
+	p, err := auditPath()
+	if err != nil {
+		return nil, err
+	}
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 16<<20)
+	for sc.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		if q.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if q.Limit > 0 && len(entries) > q.Limit {
+		entries = entries[:q.Limit]
+	}
+	return entries, nil
+}
+
+// ExportAudit 把符合条件的记录导出到 path：扩展名为 .csv 时导出 CSV（不含处理设置），否则导出 JSON Lines。
+// 返回导出的条数。
+func ExportAudit(path string, q AuditQuery) (int, error) {
+	entries, err := SearchAudit(q)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		err = writeAuditCSV(f, entries)
+	} else {
+		enc := json.NewEncoder(f)
+		for _, e := range entries {
+			if err = enc.Encode(e); err != nil {
+				break
+			}
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return len(entries), err
+}
+
+func writeAuditCSV(f *os.File, entries []AuditEntry) error {
+	// 带 UTF-8 BOM，Excel 可直接识别中文
+	if _, err := f.WriteString("\ufeff"); err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.UseCRLF = true
+	w.Write([]string{"started_at", "finished_at", "result", "input", "input_sha256", "output", "output_sha256",
+		"document_id", "instance_id", "recipient", "operator", "machine_id", "error", "start_time", "end_time", "encryption"})
+	for _, e := range entries {
+		enc := e.Options.Encryption
+		if enc == "" {
+			enc = EncryptionAES256
+		}
+		if len(e.Options.Recipients) > 0 {
+			enc = "certificate (" + strconv.Itoa(len(e.Options.Recipients)) + " recipients)"
+		}
+		w.Write([]string{e.StartedAt.Format(time.RFC3339), e.FinishedAt.Format(time.RFC3339), e.Result, e.Input, e.InputSHA256,
+			e.Output, e.OutputSHA256, e.DocumentID, e.InstanceID, e.Recipient, e.Operator, e.MachineID, e.Error,
+			e.Options.StartTime, e.Options.EndTime, enc})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+)
+
+func TestAuditEntryHidesMachineCode(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	for i := 0; i < 2; i++ {
+		if err := Run(testOptions(input, filepath.Join(dir, "out.pdf"))); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+	entries, err := SearchAudit(AuditQuery{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("SearchAudit = %d entries, %v", len(entries), err)
+	}
+	code, _ := license.GetMachineCode()
+	id := entries[0].MachineID
+	if len(id) != 16 || id != entries[1].MachineID {
+		t.Fatalf("machine ids %q %q", id, entries[1].MachineID)
+	}
+	// 标识只由机器码决定，客服可由（带格式的）机器码算出并查询
+	if code != "" && (id != MachineIDFor(code) || id != MachineIDFor(license.FormatMachineCode(code))) {
+		t.Fatalf("machine id %q does not match the machine code", id)
+	}
+	if found, err := SearchAudit(AuditQuery{MachineID: id}); err != nil || len(found) != 2 {
+		t.Fatalf("search by machine id = %d entries, %v", len(found), err)
+	}
+	if found, _ := SearchAudit(AuditQuery{MachineID: MachineIDFor("other")}); len(found) != 0 {
+		t.Fatalf("another machine matched %d entries", len(found))
+	}
+
+	p, _ := auditPath()
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "" && strings.Contains(string(raw), code) {
+		t.Fatal("audit log contains the machine code")
+	}
+	if fi, err := os.Stat(p); err != nil || runtime.GOOS != "windows" && fi.Mode().Perm() != 0o600 {
+		t.Fatalf("audit log mode %v, %v", fi.Mode(), err)
+	}
+
+	csvPath := filepath.Join(dir, "audit.csv")
+	if _, err := ExportAudit(csvPath, AuditQuery{}); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(csvPath)
+	if code != "" && strings.Contains(string(b), code) || !strings.Contains(string(b), "machine_id") {
+		t.Fatal("CSV export carries the machine code")
+	}
+}
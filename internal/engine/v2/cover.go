@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/cg917658910/win-pdf/internal/qrcode"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ExpiredCover 描述过期后显示的封面：Logo、标题、正文、联系方式与续期二维码。
+// Enabled 为 false 时过期层仍只显示 ExperiredText 一行提示。
+type ExpiredCover struct {
+	Enabled    bool
+	LogoPath   string // Logo 图片（PNG/JPEG 等），为空则不显示
+	Title      string // 标题，例如“文档已过期”
+	Body       string // 正文，为空时使用 Options.ExperiredText
+	Contact    string // 联系方式，显示在页面底部，可多行
+	RenewalURL string // 续期地址，非空时生成二维码并在其下方显示网址
+	Background string // 封面背景色，默认 #FFFFFF
+	TitleColor string // 标题颜色，默认 #222222
+	TextColor  string // 正文与联系方式颜色，默认 #555555
+}
+
+func (c ExpiredCover) withDefaults(expiredText string) ExpiredCover {
+	if strings.TrimSpace(c.Body) == "" {
+		c.Body = expiredText
+	}
+	if strings.TrimSpace(c.Background) == "" {
+		c.Background = "#FFFFFF"
+	}
+	if strings.TrimSpace(c.TitleColor) == "" {
+		c.TitleColor = "#222222"
+	}
+	if strings.TrimSpace(c.TextColor) == "" {
+		c.TextColor = "#555555"
+	}
+	c.RenewalURL = strings.TrimSpace(c.RenewalURL)
+	return c
+}
+
+// coverBlock 是封面中纵向堆叠的一块内容，draw 从 top 处向下绘制。
+type coverBlock struct {
+	height float64
+	draw   func(sb *strings.Builder, top float64) error
+}
+
+// coverAssets 是各页封面共用的资源：颜色、二维码、Logo 图片与字体，每个文档只创建一次。
+type coverAssets struct {
+	cover     ExpiredCover
+	colors    map[string]color.SimpleColor
+	qr        *qrcode.Code
+	logoRef   *types.IndirectRef
+	logoRatio float64
+	tf        *textFonts
+}
+
+func newCoverAssets(ctx *model.Context, opt Options) (*coverAssets, error) {
+	a := &coverAssets{
+		cover:  opt.ExpiredCover.withDefaults(opt.ExperiredText),
+		colors: map[string]color.SimpleColor{},
+	}
+	cover := a.cover
+	for name, c := range map[string]string{"bg": cover.Background, "title": cover.TitleColor, "text": cover.TextColor} {
+		sc, err := color.ParseColor(c)
+		if err != nil {
+			return nil, fmt.Errorf("parse cover color %q: %w", c, err)
+		}
+		a.colors[name] = sc
+	}
+
+	if cover.RenewalURL != "" {
+		var err error
+		if a.qr, err = qrcode.Encode(cover.RenewalURL); err != nil {
+			return nil, fmt.Errorf("encode renewal qrcode: %w", err)
+		}
+	}
+
+	if cover.LogoPath != "" {
+		f, err := os.Open(cover.LogoPath)
+		if err != nil {
+			return nil, fmt.Errorf("open cover logo: %w", err)
+		}
+		ref, w, h, err := model.CreateImageResource(ctx.XRefTable, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("create cover logo image: %w", err)
+		}
+		if w > 0 && h > 0 {
+			a.logoRef, a.logoRatio = ref, float64(w)/float64(h)
+		}
+	}
+
+	allText := strings.Join([]string{cover.Title, cover.Body, cover.Contact, cover.RenewalURL}, "\n")
+	a.tf = newTextFonts(ctx, allText, embedFontName(opt), opt.TextLang)
+	return a, nil
+}
+
+// buildCoverXObject 按页面尺寸生成过期封面 Form XObject，内容整体垂直居中，
+// 放不下时按比例缩小字号、Logo 与二维码。
+func buildCoverXObject(ctx *model.Context, geo *pageGeometry, a *coverAssets) (*types.IndirectRef, error) {
+	cover, colors, qr, tf := a.cover, a.colors, a.qr, a.tf
+	logoRef, logoRatio := a.logoRef, a.logoRatio
+	formW, formH := geo.formSize()
+	box := [4]float64{0, 0, formW, formH}
+	res := types.Dict{}
+	if logoRef != nil {
+		res["XObject"] = types.Dict{"Logo": *logoRef}
+	}
+
+	pageW, pageH := box[2]-box[0], box[3]-box[1]
+	margin := math.Min(pageW, pageH) * 0.08
+	x0, x1 := box[0]+margin, box[2]-margin
+	y0, y1 := box[1]+margin, box[3]-margin
+	colW := x1 - x0
+	cx := (x0 + x1) / 2
+	centered := func(w float64) float64 { return cx - w/2 }
+
+	textBlock := func(text string, size float64, c color.SimpleColor) coverBlock {
+		lineH := size * 1.4
+		lines := wrapText(tf, text, int(colW*1000/size))
+		return coverBlock{
+			height: float64(len(lines)) * lineH,
+			draw: func(sb *strings.Builder, top float64) error {
+				sb.WriteString(fmt.Sprintf("%s rg\n", colorOperands(c)))
+				return writeLines(sb, tf, lines, top, size, lineH, centered)
+			},
+		}
+	}
+
+	layout := func(k float64) (blocks []coverBlock, gap float64) {
+		titleSize := clamp(pageW*0.045, 14, 30) * k
+		bodySize := clamp(pageW*0.022, 9, 16) * k
+		smallSize := math.Max(bodySize*0.8, 6)
+		gap = bodySize * 1.5
+
+		if logoRef != nil {
+			h := math.Min(pageH*0.1, colW*0.5/logoRatio) * k
+			w := h * logoRatio
+			blocks = append(blocks, coverBlock{height: h, draw: func(sb *strings.Builder, top float64) error {
+				sb.WriteString(fmt.Sprintf("q\n%s 0 0 %s %s %s cm\n/Logo Do\nQ\n",
+					fmtFloat(w), fmtFloat(h), fmtFloat(centered(w)), fmtFloat(top-h)))
+				return nil
+			}})
+		}
+		if strings.TrimSpace(cover.Title) != "" {
+			blocks = append(blocks, textBlock(cover.Title, titleSize, colors["title"]))
+		}
+		if strings.TrimSpace(cover.Body) != "" {
+			blocks = append(blocks, textBlock(cover.Body, bodySize, colors["text"]))
+		}
+		if qr != nil {
+			side := math.Min(colW*0.45, pageH*0.28) * k
+			blocks = append(blocks, coverBlock{height: side, draw: func(sb *strings.Builder, top float64) error {
+				drawQRCode(sb, qr, centered(side), top-side, side)
+				return nil
+			}})
+			blocks = append(blocks, textBlock(cover.RenewalURL, smallSize, colors["text"]))
+		}
+		if strings.TrimSpace(cover.Contact) != "" {
+			blocks = append(blocks, textBlock(cover.Contact, smallSize, colors["text"]))
+		}
+		return blocks, gap
+	}
+	totalHeight := func(blocks []coverBlock, gap float64) float64 {
+		total := 0.0
+		for i, b := range blocks {
+			if i > 0 {
+				total += gap
+			}
+			total += b.height
+		}
+		return total
+	}
+
+	k := 1.0
+	blocks, gap := layout(k)
+	for i := 0; i < 4 && totalHeight(blocks, gap) > y1-y0; i++ {
+		k *= (y1 - y0) / totalHeight(blocks, gap)
+		blocks, gap = layout(k)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("q\n")
+	sb.WriteString(fmt.Sprintf("%s rg\n%s %s %s %s re\nf\n", colorOperands(colors["bg"]),
+		fmtFloat(box[0]), fmtFloat(box[1]), fmtFloat(pageW), fmtFloat(pageH)))
+	top := math.Min((y0+y1)/2+totalHeight(blocks, gap)/2, y1)
+	for _, b := range blocks {
+		if err := b.draw(&sb, top); err != nil {
+			return nil, err
+		}
+		top -= b.height + gap
+	}
+	sb.WriteString("Q\n")
+
+	sd, err := ctx.NewStreamDictForBuf([]byte(sb.String()))
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	res["Font"] = tf.dict
+	sd.Dict["Type"] = types.Name("XObject")
+	sd.Dict["Subtype"] = types.Name("Form")
+	sd.Dict["BBox"] = geo.formBBox()
+	sd.Dict["Matrix"] = geo.formMatrix()
+	sd.Dict["Resources"] = res
+
+	return ctx.IndRefForNewObject(*sd)
+}
+
+// drawQRCode 在 (x, y) 处绘制边长为 side 的二维码（含 4 模块静区），同一行相邻深色模块合并为一个矩形。
+func drawQRCode(sb *strings.Builder, qr *qrcode.Code, x, y, side float64) {
+	const quiet = 4
+	m := side / float64(qr.Size+2*quiet)
+	sb.WriteString(fmt.Sprintf("q\n1 1 1 rg\n%s %s %s %s re\nf\n", fmtFloat(x), fmtFloat(y), fmtFloat(side), fmtFloat(side)))
+	sb.WriteString(fmt.Sprintf("%.4f 0 0 %.4f %s %s cm\n0 0 0 rg\n", m, m, fmtFloat(x), fmtFloat(y)))
+	for row := 0; row < qr.Size; row++ {
+		for col := 0; col < qr.Size; {
+			if !qr.Dark(col, row) {
+				col++
+				continue
+			}
+			start := col
+			for col < qr.Size && qr.Dark(col, row) {
+				col++
+			}
+			sb.WriteString(fmt.Sprintf("%d %d %d 1 re\n", quiet+start, quiet+qr.Size-1-row, col-start))
+		}
+	}
+	sb.WriteString("f\nQ\n")
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(v, hi))
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// 诊断包中预检的文件数与附带的失败审计记录数上限
+const (
+	maxDiagnosticFiles  = 20
+	maxDiagnosticAudits = 50
+)
+
+// diagEnvironment 是诊断包中的运行环境信息。
+type diagEnvironment struct {
+	CreatedAt     time.Time
+	OS            string
+	Arch          string
+	NumCPU        int
+	GoVersion     string
+	Module        string
+	ModuleVersion string
+	VCSRevision   string
+	VCSTime       string
+	PDFCPUVersion string
+	MachineID     string // 与审计记录相同的本机标识，不含机器码
+	Activated     bool
+	ActivatedAt   *time.Time `json:",omitempty"`
+	ExpiresAt     *time.Time `json:",omitempty"`
+	ConfigDir     string
+	// 配置目录中的文件及大小，不含内容
+	ConfigFiles map[string]int64
+}
+
+// diagFonts 是诊断包中的字体信息。
+type diagFonts struct {
+	UserFontDir string
+	UserFonts   []string
+	// 水印与提示文字自动选用的 CJK 字体，为空表示没有可用的 CJK 字体
+	CJKFont string
+}
+
+// ExportDiagnostics 把日志、运行环境、已安装字体、files 的预检报告以及最近的失败审计记录打包为 path 指定的 ZIP，
+// 供提交问题时附上。opt 用于打开加密的输入；包中不含任何密码、机器码、注册码或密码库，机器只以本机标识表示。
+func ExportDiagnostics(path string, files []string, opt Options) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	err = writeDiagnostics(zw, files, opt)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	logging.Info("diagnostics exported", "path", path, "files", len(files))
+	return nil
+}
+
+func writeDiagnostics(zw *zip.Writer, files []string, opt Options) error {
+	if err := writeZipJSON(zw, "environment.json", diagnosticEnvironment()); err != nil {
+		return err
+	}
+	// 用户字体目录在 pdfcpu 配置初始化时确定
+	if pdffont.UserFontDir == "" {
+		model.NewDefaultConfiguration()
+	}
+	fonts := diagFonts{UserFontDir: pdffont.UserFontDir, UserFonts: pdffont.UserFontNames(), CJKFont: pickCJKUserFont()}
+	sort.Strings(fonts.UserFonts)
+	if err := writeZipJSON(zw, "fonts.json", fonts); err != nil {
+		return err
+	}
+
+	if len(files) > maxDiagnosticFiles {
+		files = files[:maxDiagnosticFiles]
+	}
+	if len(files) > 0 {
+		reports := make([]PreflightReport, 0, len(files))
+		for _, file := range files {
+			reports = append(reports, Preflight(file, opt))
+		}
+		if err := writeZipJSON(zw, "preflight.json", reports); err != nil {
+			return err
+		}
+	}
+
+	if failed, err := SearchAudit(AuditQuery{Result: AuditFailed, Limit: maxDiagnosticAudits}); err != nil {
+		logging.Warn("read audit log for diagnostics", "err", err)
+	} else if len(failed) > 0 {
+		if err := writeZipJSON(zw, "audit-failed.json", failed); err != nil {
+			return err
+		}
+	}
+
+	logging.Sync()
+	logs, err := logging.Files()
+	if err != nil {
+		return fmt.Errorf("list log files: %w", err)
+	}
+	for _, l := range logs {
+		if err := copyToZip(zw, "logs/"+filepath.Base(l), l); err != nil {
+			return fmt.Errorf("add log %s: %w", l, err)
+		}
+	}
+	return nil
+}
+
+func diagnosticEnvironment() diagEnvironment {
+	env := diagEnvironment{
+		CreatedAt:     time.Now(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		NumCPU:        runtime.NumCPU(),
+		GoVersion:     runtime.Version(),
+		PDFCPUVersion: model.VersionStr,
+		ConfigFiles:   map[string]int64{},
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		env.Module, env.ModuleVersion = bi.Main.Path, bi.Main.Version
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				env.VCSRevision = s.Value
+			case "vcs.time":
+				env.VCSTime = s.Value
+			}
+		}
+	}
+	env.MachineID = machineID()
+	if ok, ai, err := license.IsActivated(); err == nil {
+		env.Activated = ok
+		if ai != nil {
+			env.ActivatedAt, env.ExpiresAt = &ai.ActivatedAt, ai.ExpiresAt
+		}
+	}
+	if dir, err := license.ConfigDir(); err == nil {
+		env.ConfigDir = dir
+		filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				rel, _ := filepath.Rel(dir, p)
+				env.ConfigFiles[filepath.ToSlash(rel)] = info.Size()
+			}
+			return nil
+		})
+	}
+	return env
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func copyToZip(zw *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
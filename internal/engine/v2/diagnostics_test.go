@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+)
+
+func TestDiagnosticsOmitMachineCode(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	// 失败的处理会进入 audit-failed.json
+	if err := Run(testOptions(filepath.Join(dir, "missing.pdf"), filepath.Join(dir, "out.pdf"))); err == nil {
+		t.Fatal("Run succeeded on a missing input")
+	}
+
+	path := filepath.Join(dir, "diag.zip")
+	if err := ExportDiagnostics(path, []string{input}, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	code, _ := license.GetMachineCode()
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != "" && strings.Contains(string(b), code) {
+			t.Fatalf("%s contains the machine code", f.Name)
+		}
+		if f.Name == "environment.json" {
+			var env diagEnvironment
+			if err := json.Unmarshal(b, &env); err != nil || len(env.MachineID) != 16 {
+				t.Fatalf("environment machine id %q, %v", env.MachineID, err)
+			}
+		}
+	}
+	for _, n := range []string{"environment.json", "fonts.json", "preflight.json", "audit-failed.json"} {
+		if !names[n] {
+			t.Fatalf("bundle has no %s", n)
+		}
+	}
+}
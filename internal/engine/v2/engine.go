@@ -2,6 +2,7 @@ package engine
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"math/rand"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cg917658910/win-pdf/internal/cms"
+	"github.com/cg917658910/win-pdf/internal/logging"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
@@ -37,6 +40,32 @@ type Options struct {
 	WatermarkTiled   bool
 	WatermarkSpacing float64
 
+	// 输入文档的打开密码：InputPasswords 按文件路径或文件名指定，优先于通用的 InputPassword；
+	// InputPasswordFile 为密码列表文件，格式见 readPasswordFile
+	InputPassword     string
+	InputPasswords    map[string]string
+	InputPasswordFile string
+	// 证书加密的接收者：设置后改用公钥加密（Adobe.PubSec），不再使用打开密码与所有者密码
+	Recipients []Recipient
+
+	// 提示文字字体：开启后嵌入字体子集，EmbedFontName 为空时自动选择已安装的 CJK 用户字体
+	EmbedFont     bool
+	EmbedFontName string
+	// 提示文字语言（zh-CN、zh-TW、ja、ko 等），决定汉字使用的 CJK 字符集；为空时按文字内容推断
+	TextLang string
+	// 提示文字排版：字号、颜色、对齐、背景框等
+	MessageStyle MessageStyle
+	// 过期封面：Logo、标题、正文、联系方式与续期二维码，开启后替换过期提示文字
+	ExpiredCover ExpiredCover
+	// 注释（链接、批注、图章、表单控件）默认随保护状态显示/隐藏；开启后先把有外观的注释与表单展平进页面内容
+	FlattenAnnotations bool
+	// 数字签名：写出后以增量更新追加签名，签名覆盖最终的受保护文档
+	Sign SignOptions
+	// 输入已签名时的处理策略：warn（默认）、refuse、incremental，见 SignedInput* 常量
+	SignedInputPolicy string
+	// 输出方式：rewrite（默认，优化后整体重写）或 incremental（保留原文件字节，只追加新建与修改的对象）
+	OutputMode string
+
 	// 打印/复制
 	AllowedPrint bool
 	AllowedCopy  bool
@@ -44,6 +73,32 @@ type Options struct {
 	AllowedEdit bool
 	// 转换
 	AllowedConvert bool
+	// 完整的权限设置，设置后取代上面四个选项
+	Permissions *Permissions
+	// 加密算法：aes-256（默认）、aes-128 或 none，见 Encryption* 常量
+	Encryption string
+	// 输出的 PDF 版本：1.5、1.6、1.7 或 2.0，为空时由 pdfcpu 决定（1.7，输入为 2.0 时保持 2.0）
+	PDFVersion string
+
+	// 批量处理的打开密码：shared（默认，全部使用 UserPassword）、per-file（每个输出生成不同的密码）
+	// 或 per-recipient（每个输入为每个接收者各输出一份，同一接收者共用一个密码），见 Password* 常量
+	UserPasswordMode string
+	// per-recipient 模式的接收者（姓名或邮箱），输出文件名后附加接收者
+	PasswordRecipients []string
+	// 生成密码的长度（默认 12）与字符集（默认为去掉易混淆字符的字母与数字）
+	PasswordLength   int
+	PasswordAlphabet string
+	// 生成密码时在输出目录写出密码清单：格式 csv（默认）或 json；保护方式为空（明文）、zip（AES-256 加密的 ZIP）
+	// 或 pdf（作为附件放入加密的 PDF），见 Manifest* 常量；PasswordManifestPassword 为打开清单的密码
+	PasswordManifestFormat     string
+	PasswordManifestProtection string
+	PasswordManifestPassword   string
+
+	// 审计日志中记录的操作人，为空时为当前系统用户
+	Operator string
+
+	// 未设置所有者密码时 Run 为本文件生成的随机密码，写出后存入密码库
+	generatedOwnerPW string
 }
 
 const (
@@ -51,53 +106,67 @@ const (
 	maskNum     = 5
 )
 
+// 输出方式（Options.OutputMode）
+const (
+	OutputRewrite     = "rewrite"
+	OutputIncremental = "incremental"
+)
+
 // 批量处理入口
 func RunBatch(opt Options) (successCount int, err error) {
 	if opt.Files == "" {
 		return successCount, fmt.Errorf("no files provided for batch run")
 	}
-
-	raw := strings.ReplaceAll(opt.Files, ",", ";")
-	parts := strings.Split(raw, ";")
-	files := make([]string, 0, len(parts))
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p != "" {
-			files = append(files, p)
-		}
+	if err := validateSecurityOptions(opt); err != nil {
+		return successCount, err
+	}
+	if err := validatePasswordOptions(opt); err != nil {
+		return successCount, err
 	}
+
+	files := splitFiles(opt.Files)
 	if len(files) == 0 {
 		return successCount, fmt.Errorf("no valid files provided for batch run")
 	}
+	batch, err := batchJobs(opt, files)
+	if err != nil {
+		return successCount, fmt.Errorf("generate passwords: %w", err)
+	}
 
 	startedAt := time.Now()
 	workerCount := runtime.NumCPU()
 	if workerCount < 1 {
 		workerCount = 1
 	}
-	if workerCount > len(files) {
-		workerCount = len(files)
+	if workerCount > len(batch) {
+		workerCount = len(batch)
 	}
 
 	var (
 		firstErr error
 		mu       sync.Mutex
 		wg       sync.WaitGroup
+		manifest = make([]*passwordManifestEntry, len(batch))
+		issued   = make([]IssuedDocument, len(batch))
 	)
-	jobs := make(chan string, len(files))
+	jobs := make(chan batchJob, len(batch))
 
 	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for p := range jobs {
+			for j := range jobs {
 				cur := opt
-				cur.Input = p
-				cur.Output = filepath.Join(cur.OutputDir, filepath.Base(p))
-				fmt.Printf("Running batch for %s -> %s\n", cur.Input, cur.Output)
+				cur.Input = j.input
+				cur.Output = j.output
+				if opt.generatesPasswords() {
+					cur.UserPassword = j.password
+				}
+				logging.Info("batch file started", "input", cur.Input, "output", cur.Output)
 
-				if runErr := Run(cur); runErr != nil {
-					fmt.Printf("RunBatch error for %s: %v\n", p, runErr)
+				out, doc, runErr := processFile(cur, j.recipient)
+				if runErr != nil {
+					logging.Error("batch file failed", "input", j.input, "err", runErr)
 					mu.Lock()
 					if firstErr == nil {
 						firstErr = runErr
@@ -106,60 +175,264 @@ func RunBatch(opt Options) (successCount int, err error) {
 					continue
 				}
 
-				fmt.Printf("RunBatch completed for %s\n", p)
+				logging.Info("batch file completed", "input", j.input, "output", out)
 				mu.Lock()
 				successCount++
+				issued[j.index] = doc
+				manifest[j.index] = &passwordManifestEntry{File: filepath.Base(out), Recipient: j.recipient, Password: j.password, Source: j.input, Path: out}
 				mu.Unlock()
 			}
 		}()
 	}
 
-	for _, p := range files {
-		jobs <- p
+	for _, j := range batch {
+		jobs <- j
 	}
 	close(jobs)
 	wg.Wait()
 
+	// 登记表在全部文件处理完后一次写入，避免每个文件都重写一遍登记表
+	if err := registerIssued(issued...); err != nil {
+		logging.Warn("register issued documents", "err", err)
+	}
+
 	elapsed := time.Since(startedAt)
-	fmt.Printf("RunBatch finished in %s: %d/%d files processed successfully.\n", elapsed.Round(time.Second), successCount, len(files))
+	logging.Info("batch finished", "elapsed", elapsed.Round(time.Second).String(), "succeeded", successCount, "total", len(batch))
+
+	if opt.generatesPasswords() && successCount > 0 {
+		// 清单按输入顺序排列，只列出成功写出的文件
+		var entries []passwordManifestEntry
+		for _, e := range manifest {
+			if e != nil {
+				entries = append(entries, *e)
+			}
+		}
+		path, err := writePasswordManifest(opt, entries)
+		if err != nil {
+			// 没有清单就无从分发密码，按失败处理
+			return successCount, fmt.Errorf("写出密码清单失败：%w", err)
+		}
+		logging.Info("password manifest written", "path", path)
+	}
 	return successCount, firstErr
 }
 
+// splitFiles 拆分以分号或逗号分隔的文件列表，忽略空项。
+func splitFiles(raw string) []string {
+	parts := strings.Split(strings.ReplaceAll(raw, ",", ";"), ";")
+	files := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
 // Run executes the full pipeline: read -> process -> write.
 func Run(opt Options) error {
-	ctx, err := readPDF(opt.Input)
+	// 按文件或接收者生成的密码要写入清单，清单只在批量处理时写出
+	if opt.generatesPasswords() {
+		return fmt.Errorf("按文件或接收者生成打开密码只能用于批量处理")
+	}
+	if err := validatePasswordOptions(opt); err != nil {
+		return err
+	}
+	out, doc, err := processFile(opt, "")
 	if err != nil {
-		//  pdfcpu:
-		if strings.Contains(err.Error(), "pdfcpu:") {
-			return fmt.Errorf("此文档已加密过，不能再次加密！")
-		}
 		return err
 	}
+	if rerr := registerIssued(doc); rerr != nil {
+		logging.Warn("register issued document", "output", out, "err", rerr)
+	}
+	return nil
+}
 
-	processPDF(ctx, opt)
+// runFile 处理单个文件，返回实际写出的文件名（重名时自动加后缀）。
+func runFile(opt Options) (string, error) {
+	if err := validateSecurityOptions(opt); err != nil {
+		return "", err
+	}
+	// 先加载签名证书，证书有误时不必处理文档
+	var signer *cms.Signer
+	if opt.Sign.Enabled {
+		// 签名需要重新读取输出，pdfcpu 无法打开公钥加密的文档
+		if len(opt.Recipients) > 0 {
+			return "", fmt.Errorf("证书加密的文档暂不支持数字签名")
+		}
+		s, err := loadSigner(opt.Sign)
+		if err != nil {
+			return "", fmt.Errorf("load signing certificate: %w", err)
+		}
+		signer = &s
+	}
 
-	return writePDF(ctx, opt.Output)
+	// 增量写出需要处理前的对象摘要；已签名输入是否增量写出要读入后才知道，按策略预先记录
+	snapshot := opt.OutputMode == OutputIncremental || opt.SignedInputPolicy == SignedInputIncremental
+	ctx, before, err := readPDF(opt, snapshot)
+	if err != nil {
+		if isPasswordError(err) {
+			return "", err
+		}
+		return "", fmt.Errorf("无法解析文档：%w", err)
+	}
+	// 增量写出保留输入的加密，签名时要用打开输入的密码读取输出
+	inputPW := ctx.UserPW
+	incremental, err := applySignedInputPolicy(ctx, &opt)
+	if err != nil {
+		return "", err
+	}
+	incremental = incremental || opt.OutputMode == OutputIncremental
+	if incremental && len(opt.Recipients) > 0 {
+		return "", fmt.Errorf("增量更新不能设置证书加密，请改用重写模式")
+	}
+	if incremental {
+		if err := validateIncrementalEncryption(ctx, opt); err != nil {
+			return "", err
+		}
+	}
+	if len(opt.Recipients) > 0 && (opt.PwdEnabled || strings.TrimSpace(opt.UserPassword) != "") {
+		logging.Warn("passwords are ignored when encrypting for certificate recipients", "input", opt.Input)
+	}
+	if incremental {
+		// 加密作用于整个文件，追加的修订无法加密原有对象
+		logging.Info("passwords, permissions, encryption and PDF version are not changed in incremental mode", "input", opt.Input)
+	}
+	// 标准安全处理程序加密的输出需要所有者密码，未设置时每个文件生成不同的随机密码
+	recordOwnerPW := !incremental && len(opt.Recipients) == 0 && opt.Encryption != EncryptionNone
+	if recordOwnerPW && strings.TrimSpace(opt.OwnerPassword) == "" {
+		if opt.generatedOwnerPW, err = newOwnerPassword(); err != nil {
+			return "", fmt.Errorf("generate owner password: %w", err)
+		}
+	}
+
+	// 增量写出不再经过优化校验，处理失败时不能输出只处理了一部分的文件
+	if err := processPDF(ctx, opt, incremental); err != nil {
+		return "", err
+	}
+
+	var out string
+	if incremental {
+		out, err = writeIncrementalPDF(ctx, before, opt.Input, opt.Output)
+	} else {
+		out, err = writePDF(ctx, opt.Output, opt.PDFVersion)
+	}
+	if err != nil {
+		return "", err
+	}
+	if signer != nil {
+		userPW, ownerPW := strings.TrimSpace(opt.UserPassword), ownerPassword(opt)
+		if incremental {
+			userPW, ownerPW = inputPW, inputPW
+		}
+		if err := signFile(out, opt, *signer, userPW, ownerPW); err != nil {
+			// 未签名的输出不保留，避免被当作已签名文档分发
+			os.Remove(out)
+			return "", fmt.Errorf("sign %s: %w", out, err)
+		}
+	}
+	if recordOwnerPW {
+		if err := recordOwnerPassword(out, ownerPassword(opt), ctx.ID); err != nil {
+			// 密码未保存时所有者密码无从找回，不保留输出
+			os.Remove(out)
+			return "", fmt.Errorf("保存所有者密码失败：%w", err)
+		}
+	}
+	return out, nil
 }
 
-func writePDF(ctx *model.Context, output string) error {
+// writePDF 写出文档，返回实际使用的文件名（重名时自动加后缀）。
+func writePDF(ctx *model.Context, output, version string) (string, error) {
 	if err := api.OptimizeContext(ctx); err != nil {
-		return fmt.Errorf("optimize context: %w", err)
+		return "", fmt.Errorf("optimize context: %w", err)
 	}
 	out := uniqueOutputName(output)
-	return api.WriteContextFile(ctx, out)
+	if err := api.WriteContextFile(ctx, out); err != nil {
+		return out, err
+	}
+	if err := setHeaderVersion(out, version); err != nil {
+		return out, fmt.Errorf("set PDF version: %w", err)
+	}
+	return out, nil
+}
+
+// writeIncrementalPDF 保留原文件的全部字节，把与 before 相比新建和修改过的对象作为新修订追加写出，
+// 对象编号不变，返回实际使用的文件名。原文件只做流式复制，大文件也无需整体读入内存。
+func writeIncrementalPDF(ctx *model.Context, before map[int]objectDigest, input, output string) (string, error) {
+	src, err := os.Open(input)
+	if err != nil {
+		return "", fmt.Errorf("read original revision: %w", err)
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("read original revision: %w", err)
+	}
+
+	inc := newIncrement(ctx, fi.Size())
+	if err := inc.addChangedObjects(before); err != nil {
+		return "", err
+	}
+	tail, err := inc.write()
+	if err != nil {
+		return "", fmt.Errorf("write increment: %w", err)
+	}
+
+	out := uniqueOutputName(output)
+	dst, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err == nil {
+		_, err = dst.Write(tail)
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out)
+		return "", fmt.Errorf("write %s: %w", out, err)
+	}
+	return out, nil
 }
 
 // readPDF reads the PDF into a pdfcpu Context.
-func readPDF(input string) (*model.Context, error) {
-	ctx, err := api.ReadContextFile(input)
+// 加密的输入按 opt 中的打开密码解密，处理后按新的设置重新加密。
+// 页面 Contents 先规范化再校验，空页、嵌套数组等不规范写法不会导致整个文件失败。
+// snapshot 为 true 时在规范化之前记录各对象的摘要，增量写出时据此找出新建与修改过的对象。
+func readPDF(opt Options, snapshot bool) (*model.Context, map[int]objectDigest, error) {
+	f, err := os.Open(opt.Input)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read context file: %w", err)
+	}
+	defer f.Close()
+
+	ctx, err := openPDF(f, opt, opt.Input)
 	if err != nil {
-		return nil, fmt.Errorf("read context file: %w", err)
+		if isPasswordError(err) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("read context file: %w", err)
+	}
+	var before map[int]objectDigest
+	if snapshot {
+		if before, err = objectDigests(ctx); err != nil {
+			return nil, nil, fmt.Errorf("read context file: %w", err)
+		}
 	}
-	return ctx, nil
+	if err := normalizePageContents(ctx); err != nil {
+		return nil, nil, fmt.Errorf("normalize page contents: %w", err)
+	}
+	if err := api.ValidateContext(ctx); err != nil {
+		return nil, nil, fmt.Errorf("validate context: %w", err)
+	}
+	return ctx, before, nil
 }
 
 // processPDF processes every page and returns all created OCG refs for the document.
-func processPDF(ctx *model.Context, opt Options) error {
+// 增量写出时保留输入的加密、权限与 PDF 版本。
+func processPDF(ctx *model.Context, opt Options, incremental bool) error {
 
 	conf := model.NewDefaultConfiguration()
 	ctx.Configuration = conf
@@ -173,19 +446,40 @@ func processPDF(ctx *model.Context, opt Options) error {
 	}
 	start := normalizeTime(startTime, true)
 	end := normalizeTime(endTime, false)
-	// 处理页面
+	if opt.EmbedFont && strings.TrimSpace(opt.EmbedFontName) == "" {
+		opt.EmbedFontName = pickCJKUserFont()
+	}
+	// 处理页面：保护对象在各页之间共用，OCProperties 最后一次写入
+	prot, err := newProtection(ctx, opt)
+	if err != nil {
+		return err
+	}
 	for p := 1; p <= ctx.PageCount; p++ {
-		err := processPageStructured(ctx, p, opt, maskNum)
+		err := processPageStructured(prot, p)
 		if err != nil {
 			return fmt.Errorf("process page %d: %w", p, err)
 		}
 	}
-	// 处理加密
-	processEncryption(ctx, opt)
-	// 处理权限
-	processPermissions(ctx, opt)
+	prot.finish()
+	switch {
+	case incremental:
+		// 追加的修订沿用原有的加密字典与密钥，不能改动
+	case len(opt.Recipients) > 0:
+		// 证书加密：权限随各接收者的信封分发
+		if err := processCertificateEncryption(ctx, opt.Recipients); err != nil {
+			return err
+		}
+		applyPDFVersion(ctx, opt.PDFVersion)
+	default:
+		// 处理加密
+		processEncryption(ctx, opt)
+		// 处理权限
+		processPermissions(ctx, opt)
+		applyPDFVersion(ctx, opt.PDFVersion)
+	}
 	// 注入 OpenAction JS
-	injectOpenActionJS(ctx, start, end, opt.ExperiredText, opt.UnsupportedText)
+	engineOCGs, expiredOff, total := prot.jsOCGs()
+	injectOpenActionJS(ctx, start, end, opt.ExperiredText, opt.UnsupportedText, engineOCGs, expiredOff, total)
 	return nil
 }
 
@@ -214,7 +508,7 @@ func applyWatermarkToOriginalContent(ctx *model.Context, opt Options) error {
 	}
 	desc := strings.TrimSpace(opt.WatermarkDesc)
 	desc = ensureCJKFontForWatermark(opt.WatermarkText, desc)
-	fmt.Printf("Applying watermark to original content with desc: %s\n", desc)
+	logging.Debug("applying watermark to original content", "desc", desc)
 	wm, err := api.TextWatermark(opt.WatermarkText, desc, true, false, types.POINTS)
 	if err != nil {
 		return err
@@ -251,25 +545,24 @@ func addTiledWatermarks(ctx *model.Context, base *model.Watermark, spacing float
 	const maxPerPage = 400
 	m := map[int][]*model.Watermark{}
 	for p := 1; p <= ctx.PageCount; p++ {
-		pageDict, _, _, err := ctx.PageDict(p, true)
+		pageDict, _, inh, err := ctx.PageDict(p, true)
 		if err != nil || pageDict == nil {
 			return fmt.Errorf("get page dict: %w", err)
 		}
-		mb := getPageMediaBox(pageDict)
-		x0 := numToFloat(mb[0])
-		y0 := numToFloat(mb[1])
-		x1 := numToFloat(mb[2])
-		y1 := numToFloat(mb[3])
-		pageW := x1 - x0
-		pageH := y1 - y0
+		// pdfcpu 按可见区域（CropBox）并以页面显示方向定位水印
+		g := newPageGeometry(ctx, pageDict, inh)
+		pageW, pageH := g.cropBox.Width(), g.cropBox.Height()
+		if g.rotate == 90 || g.rotate == 270 {
+			pageW, pageH = pageH, pageW
+		}
 		if pageW <= 0 || pageH <= 0 {
 			continue
 		}
 		count := 0
-		startX := x0 + stepX/2
-		startY := y0 + stepY/2
-		endX := x1 - stepX/2
-		endY := y1 - stepY/2
+		startX := stepX / 2
+		startY := stepY / 2
+		endX := pageW - stepX/2
+		endY := pageH - stepY/2
 
 		// If spacing is larger than page size, tiling loops below won't produce any
 		// watermark. Ensure we still have one watermark centered on the page.
@@ -291,8 +584,8 @@ func addTiledWatermarks(ctx *model.Context, base *model.Watermark, spacing float
 				// ensure each watermark has its own object/cache bookkeeping
 				wm.Objs = types.IntSet{}
 				wm.Pos = types.BottomLeft
-				wm.Dx = types.ToUserSpace(x-x0, wm.InpUnit)
-				wm.Dy = types.ToUserSpace(y-y0, wm.InpUnit)
+				wm.Dx = types.ToUserSpace(x, wm.InpUnit)
+				wm.Dy = types.ToUserSpace(y, wm.InpUnit)
 				m[p] = append(m[p], wm)
 				count++
 				if count >= maxPerPage {
@@ -357,17 +650,6 @@ func estimateTextWatermarkBounds(wm *model.Watermark) (float64, float64) {
 	return maxW, totalH
 }
 
-func numToFloat(o types.Object) float64 {
-	switch v := o.(type) {
-	case types.Float:
-		return float64(v)
-	case types.Integer:
-		return float64(v)
-	default:
-		return 0
-	}
-}
-
 func ensureCJKFontForWatermark(text, desc string) string {
 	if !hasNonASCII(text) {
 		return desc
@@ -380,7 +662,7 @@ func ensureCJKFontForWatermark(text, desc string) string {
 	// If no user font set or core font is used, pick a CJK-capable user font.
 	cjkFont := pickCJKUserFont()
 	if cjkFont == "" {
-		fmt.Printf("No user CJK font installed; watermark text may not render.\n")
+		logging.Warn("no user CJK font installed; watermark text may not render")
 		return joinWatermarkDesc(items)
 	}
 	if fontKey == "" {
@@ -475,84 +757,85 @@ func pickCJKUserFont() string {
 	return userFonts[0]
 }
 
+// embedFontName 返回提示文字需要嵌入的字体名，未开启嵌入时返回空串。
+func embedFontName(opt Options) string {
+	if !opt.EmbedFont {
+		return ""
+	}
+	return strings.TrimSpace(opt.EmbedFontName)
+}
+
 // 处理加密
 func processEncryption(ctx *model.Context, opt Options) {
+	if opt.Encryption == EncryptionNone {
+		// 不加密：输入原有的加密一并去掉
+		ctx.Encrypt, ctx.EncKey = nil, nil
+		return
+	}
 	ctx.Cmd = model.ENCRYPT
 	ctx.UserPW = strings.TrimSpace(opt.UserPassword)
-	ctx.OwnerPW = fmt.Sprintf("%s%s", strings.TrimSpace(opt.OwnerPassword), ownerPWMask)
+	ctx.OwnerPW = ownerPassword(opt)
 	ctx.EncryptUsingAES = true
 	ctx.EncryptKeyLength = 256
+	if opt.Encryption == EncryptionAES128 {
+		ctx.EncryptKeyLength = 128
+	}
+}
+
+// ownerPassword 返回实际写入文档的所有者密码。
+func ownerPassword(opt Options) string {
+	if opt.generatedOwnerPW != "" {
+		return opt.generatedOwnerPW
+	}
+	return fmt.Sprintf("%s%s", strings.TrimSpace(opt.OwnerPassword), ownerPWMask)
 }
 
 // 处理权限
 func processPermissions(ctx *model.Context, opt Options) {
-	// 以“默认禁止全部操作”为基础，再按选项开放权限
-	var permissions model.PermissionFlags = 0xF0C3 // PermissionsNone
-
-	if opt.AllowedPrint {
-		permissions |= model.PermissionsPrint
-	}
-	if opt.AllowedCopy || opt.AllowedConvert {
-		permissions |= (model.PermissionExtract + model.PermissionExtractRev3)
-	}
-	if opt.AllowedEdit {
-		permissions |= (model.PermissionModify + model.PermissionAssembleRev3 + model.PermissionFillRev3 + model.PermissionModAnnFillForm)
-	}
-	/* if opt.AllowedConvert {
-		permissions |= model.PermissionExtract
-	} */
-	ctx.Permissions = permissions
+	ctx.Permissions = opt.permissions().flags()
 }
 
 // processPageStructured implements the per-page workflow with single-responsibility steps.
-func processPageStructured(ctx *model.Context, pageNum int, opt Options, maskNum int) error {
+func processPageStructured(prot *protection, pageNum int) error {
+	ctx := prot.ctx
 	// 1. 获取 pageDict
-	pageDict, _, _, err := ctx.PageDict(pageNum, true)
+	pageDict, _, inh, err := ctx.PageDict(pageNum, true)
 	if err != nil {
 		return fmt.Errorf("get page dict: %w", err)
 	}
 	if pageDict == nil {
 		return fmt.Errorf("page dict is nil")
 	}
+	// 遮罩覆盖整个 MediaBox，提示文字按可见区域、旋转与 UserUnit 排版
+	geo := newPageGeometry(ctx, pageDict, inh)
+	// 注释设置 /OC（或先展平进页面内容），仅在有效期内可见
+	if err := prot.protectPageAnnotations(pageDict, inh, prot.opt.FlattenAnnotations); err != nil {
+		return fmt.Errorf("protect annotations: %w", err)
+	}
 	// 2. 提取 pageContent XObject (原始内容合并为 Form XObject)
-	normalXObj, err := extractPageContentAsXObject(ctx, pageDict, pageNum)
+	normalXObj, err := extractPageContentAsXObject(ctx, pageDict, inh)
 	if err != nil {
 		return fmt.Errorf("extract page content as xobject: %w", err)
 	}
-	// 3. 处理 mask：创建 mask OCG，创建 mask XObject
-	maskOCGs, maskXObjs, err := buildMaskOCGsAndXObjectsForPage(ctx, pageDict, pageNum, maskNum)
+	// 3. mask 与 expired_mask：同尺寸页面共用一个遮罩 XObject
+	mask, err := prot.mask(geo.mediaBox)
 	if err != nil {
-		return fmt.Errorf("build mask ocgs and xobjects for page: %w", err)
+		return fmt.Errorf("build mask xobject: %w", err)
 	}
-	insertOCPropertiesOCGs(ctx, maskOCGs)
-	// expired OCG and XObject
-	expiredOCG, expiredXObj, err := buildExpiredOCGAndXObject(ctx, pageDict, pageNum, opt.ExperiredText)
-	if err != nil {
-		return fmt.Errorf("build expired ocg and xobject: %w", err)
+	xobjs := map[string]*types.IndirectRef{resExpiredMask: mask}
+	for i := range prot.maskOCGs {
+		xobjs[maskResName(i)] = mask
 	}
-	insertOCPropertiesOCGs(ctx, []*types.IndirectRef{expiredOCG})
-	// expired_mask OCG and XObject
-	expiredMaskOCG, expiredMaskXObj, err := buildExpiredMaskOCGAndXObject(ctx, pageDict, pageNum)
-	if err != nil {
-		return fmt.Errorf("build expired mask ocg and xobject: %w", err)
+	// 4. expired 与 fallback 提示：同几何页面共用
+	if xobjs[resExpired], err = prot.expiredForm(geo); err != nil {
+		return fmt.Errorf("build expired xobject: %w", err)
 	}
-	insertOCPropertiesOCGs(ctx, []*types.IndirectRef{expiredMaskOCG})
-	// 4. 处理 fallback：创建 fallback OCG，创建 fallback XObject
-	fallbackOCG, fallbackXObj, err := buildFallbackOCGAndXObject(ctx, pageDict, pageNum, opt.UnsupportedText)
-	if err != nil {
-		return fmt.Errorf("build fallback ocg and xobject: %w", err)
-	}
-	insertOCPropertiesOCGs(ctx, []*types.IndirectRef{fallbackOCG})
-	// 5. 绑定 mask OCGResources, 绑定 fallback OCGResources
-	injectOCGResources(ctx, pageDict, pageNum, normalXObj, maskXObjs, fallbackXObj, maskOCGs, fallbackOCG)
-	// expired_mask OCGResources
-	injectExpiredMaskOCGResources(ctx, pageDict, pageNum, expiredMaskXObj, expiredMaskOCG)
-	// expired OCGResources
-	injectExpiredOCGResources(ctx, pageDict, pageNum, expiredXObj, expiredOCG)
-	// 6. Rewrite 页面：插入引用 mask & fallback
-	if err := rewritePageWithMasksAndFallback(ctx, pageDict, pageNum, maskXObjs); err != nil {
-		return fmt.Errorf("rewrite page with masks: %w", err)
+	if xobjs[resText], err = prot.fallbackForm(geo); err != nil {
+		return fmt.Errorf("build fallback xobject: %w", err)
 	}
+	// 5. 替换页面资源与内容流
+	injectOCGResources(pageDict, normalXObj, xobjs, prot.properties)
+	pageDict["Contents"] = *prot.content
 	return nil
 }
 
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// testConfigDir 把配置目录（审计日志、登记表、密码库）指向测试的临时目录，密码库用口令保护。
+func testConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("APPDATA", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv(VaultPassphraseEnv, "test vault passphrase")
+	return dir
+}
+
+// writeTestPDF 写出一个有 pages 页文字的最小 PDF（xref 表，不压缩）。
+func writeTestPDF(t *testing.T, path string, pages int) []byte {
+	t.Helper()
+	var objs []string
+	kids := ""
+	for i := 0; i < pages; i++ {
+		page, content := 4+2*i, 5+2*i
+		kids += fmt.Sprintf("%d 0 R ", page)
+		text := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (Page %d) Tj ET", i+1)
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", content),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(text), text))
+	}
+	objs = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}, objs...)
+	return writeRawTestPDF(t, path, objs)
+}
+
+// writeRawTestPDF 把 objs 依次作为对象 1、2、… 写成 PDF（对象 1 为目录），返回文件内容。
+func writeRawTestPDF(t *testing.T, path string, objs []string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// editTestPDF 用 pdfcpu 读入 path，交给 edit 修改后写回。
+func editTestPDF(t *testing.T, path string, edit func(ctx *model.Context)) {
+	t.Helper()
+	ctx, err := api.ReadContextFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(ctx)
+	if err := api.WriteContextFile(ctx, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addTestAnnotations 把 annots 加到第一页的 /Annots 中。
+func addTestAnnotations(t *testing.T, ctx *model.Context, annots ...types.Dict) {
+	t.Helper()
+	pageDict, pageRef, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arr := types.Array{}
+	for _, a := range annots {
+		a["Type"], a["P"] = types.Name("Annot"), *pageRef
+		ref, err := ctx.IndRefForNewObject(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		arr = append(arr, *ref)
+	}
+	pageDict["Annots"] = arr
+}
+
+// testOptions 返回处理 input 的最小设置：当前时间起一天有效，不加水印与提示文字。
+func testOptions(input, output string) Options {
+	now := time.Now()
+	return Options{
+		Input:     input,
+		Output:    output,
+		StartTime: now.Add(-time.Hour).Format(time.RFC3339),
+		EndTime:   now.Add(24 * time.Hour).Format(time.RFC3339),
+	}
+}
+
+// readTestPDF 用 pdfcpu 读取并校验 path，password 为打开密码。
+func readTestPDF(t *testing.T, path, password string) *model.Context {
+	t.Helper()
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW, conf.OwnerPW = password, password
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	ctx, err := api.ReadAndValidate(f, conf)
+	if err != nil {
+		t.Fatalf("read %s: %v", filepath.Base(path), err)
+	}
+	return ctx
+}
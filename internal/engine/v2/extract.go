@@ -9,40 +9,21 @@ import (
 )
 
 // ExtractPageContentAsXObject 将页面的 Contents（可能是多个流）合并为一个 Form XObject，返回其间接引用。
-
+// 没有 Contents 或内容为空的页面得到一个空的 Form XObject，仍照常叠加遮罩与提示。
 func extractPageContentAsXObject(
 	ctx *model.Context,
 	page types.Dict,
-	pageNr int,
+	inhPAttrs *model.InheritedPageAttrs,
 ) (*types.IndirectRef, error) {
 
-	contents := page["Contents"]
-	if contents == nil {
-		return nil, fmt.Errorf("page has no contents")
-	}
-
-	// 内容流可能是一个或多个
-	var streams types.Array
-	switch c := contents.(type) {
-	case types.IndirectRef:
-		streams = types.Array{c}
-	case types.Array:
-		streams = c
-	default:
-		return nil, fmt.Errorf("unsupported contents type")
-	}
-	_, _, inhPAttrs, err := ctx.PageDict(pageNr, true)
+	// 创建 Form XObject: 将页面的内容流合并为一个 StreamDict
+	streams, err := pageContentStreams(ctx, page["Contents"], 0)
 	if err != nil {
 		return nil, err
 	}
-	// 创建 Form XObject: 将页面的内容流合并为一个 StreamDict
 	var buf bytes.Buffer
 	for _, s := range streams {
-		ir, ok := s.(types.IndirectRef)
-		if !ok {
-			continue
-		}
-		sd, _, err := ctx.DereferenceStreamDict(ir)
+		sd, _, err := ctx.DereferenceStreamDict(s)
 		if err != nil {
 			return nil, err
 		}
@@ -50,8 +31,10 @@ func extractPageContentAsXObject(
 		if err := sd.Decode(); err != nil {
 			return nil, err
 		}
-		if sd.Content != nil {
+		// 流之间补一个换行，避免前一个流末尾的操作符与下一个流粘连
+		if len(sd.Content) > 0 {
 			buf.Write(sd.Content)
+			buf.WriteByte('\n')
 		}
 	}
 
@@ -64,62 +47,108 @@ func extractPageContentAsXObject(
 	}
 	newSD.Dict["Type"] = types.Name("XObject")
 	newSD.Dict["Subtype"] = types.Name("Form")
-	newSD.Dict["Resources"] = page["Resources"]
-	newSD.Dict["BBox"] = inhPAttrs.MediaBox.Array()
+	// 页面自身没有 Resources 时使用从页面树继承的资源
+	if res, ok := page["Resources"]; ok && res != nil {
+		newSD.Dict["Resources"] = res
+	} else if inhPAttrs.Resources != nil {
+		newSD.Dict["Resources"] = inhPAttrs.Resources
+	}
+	newSD.Dict["BBox"] = newPageGeometry(ctx, page, inhPAttrs).mediaBox.Array()
 
 	return ctx.IndRefForNewObject(*newSD)
 }
-func rewritePageWithMasksAndFallback(
-	ctx *model.Context,
-	pageDict types.Dict,
-	pageNr int,
-	masks []*types.IndirectRef,
-) error {
 
+// pageContentStreams 展开 Contents，按顺序返回其中的内容流（间接引用或直接流对象）。
+// 除规范的单个流与流数组外，也接受指向数组的间接引用和（不规范但常见的）嵌套数组；
+// 没有 Contents、空数组或引用了不存在的对象时返回空，按空页处理。
+func pageContentStreams(ctx *model.Context, o types.Object, depth int) (types.Array, error) {
+	if depth > 8 {
+		return nil, fmt.Errorf("contents nested too deeply")
+	}
+	d, err := ctx.Dereference(o)
+	if err != nil {
+		return nil, err
+	}
+	switch c := d.(type) {
+	case nil:
+		return nil, nil
+	case types.Array:
+		var streams types.Array
+		for _, e := range c {
+			sub, err := pageContentStreams(ctx, e, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			streams = append(streams, sub...)
+		}
+		return streams, nil
+	case types.StreamDict:
+		return types.Array{o}, nil
+	default:
+		return nil, fmt.Errorf("unsupported contents type %T", d)
+	}
+}
+
+// normalizePageContents 在校验前把每个页面的 Contents 规范为单个流引用或流引用数组，
+// 直接流对象改为间接对象，没有内容的页面删除 Contents，使 pdfcpu 校验与后续处理都能接受。
+func normalizePageContents(ctx *model.Context) error {
+	if err := ctx.EnsurePageCount(); err != nil {
+		return err
+	}
+	for i := 1; i <= ctx.PageCount; i++ {
+		pageDict, _, _, err := ctx.PageDict(i, false)
+		if err != nil || pageDict == nil {
+			continue
+		}
+		streams, err := pageContentStreams(ctx, pageDict["Contents"], 0)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i, err)
+		}
+		for j, s := range streams {
+			if sd, ok := s.(types.StreamDict); ok {
+				ref, err := ctx.IndRefForNewObject(sd)
+				if err != nil {
+					return err
+				}
+				streams[j] = *ref
+			}
+		}
+		switch len(streams) {
+		case 0:
+			delete(pageDict, "Contents")
+		case 1:
+			pageDict["Contents"] = streams[0]
+		default:
+			pageDict["Contents"] = streams
+		}
+	}
+	return nil
+}
+
+// buildProtectedPageContent 生成所有页面共用的包装内容流：先画原内容，再依次画 OCG 控制的
+// 遮罩、过期提示、过期遮罩与 fallback 提示，资源名见 protection.go。
+func buildProtectedPageContent(ctx *model.Context, maskCount int) (*types.IndirectRef, error) {
 	var buf bytes.Buffer
 
 	buf.WriteString("q\nQ\n") // 🔥 清空历史 GS
-	buf.WriteString("q\n/NormalContent Do\nQ\n")
+	buf.WriteString(fmt.Sprintf("q\n/%s Do\nQ\n", resNormalContent))
 
-	for i := range masks {
-		idx := i
-		buf.WriteString(fmt.Sprintf(
-			"/OC /mask_%02d_%02d BDC\n/mask_%02d_%02d Do\nEMC\n",
-			pageNr,
-			idx,
-			pageNr,
-			idx,
-		))
+	for i := 0; i < maskCount; i++ {
+		buf.WriteString(fmt.Sprintf("/OC /%[1]s BDC\n/%[1]s Do\nEMC\n", maskResName(i)))
+	}
+	// expired、expired_mask、fallback text
+	for _, name := range []string{resExpired, resExpiredMask, resText} {
+		buf.WriteString(fmt.Sprintf("/OC /%[1]s BDC\n/%[1]s Do\nEMC\n", name))
 	}
-	buf.WriteString(fmt.Sprintf(
-		"/OC /expired_%02d BDC\n/expired_%02d Do\nEMC\n", pageNr, pageNr,
-	))
-	// expired_mask
-	buf.WriteString(fmt.Sprintf(
-		"/OC /expired_mask_%02d BDC\n/expired_mask_%02d Do\nEMC\n", pageNr, pageNr,
-	))
-	// fallback text
-	buf.WriteString(fmt.Sprintf(
-		"/OC /text_%02d BDC\n/text_%02d Do\nEMC\n", pageNr, pageNr,
-	))
 
-	sd, err := ctx.NewStreamDictForBuf(
-		buf.Bytes(),
-	)
+	sd, err := ctx.NewStreamDictForBuf(buf.Bytes())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := sd.Encode(); err != nil {
-		return err
-	}
-
-	ref, err := ctx.IndRefForNewObject(*sd)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	pageDict["Contents"] = *ref
-
-	return nil
+	return ctx.IndRefForNewObject(*sd)
 }
 
 // RewritePageWithMasks 生成一个包装内容流，引用 normal/mask/text XObjects 并追加到 pageDict.Contents。
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// textStream 返回写出 s 的内容流对象文本。
+func textStream(s string) string {
+	text := fmt.Sprintf("BT /F1 24 Tf 72 720 Td (%s) Tj ET", s)
+	return fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(text), text)
+}
+
+// normalContent 返回第 i 页 NormalContent 解码后的内容，并检查页面资源中有全部遮罩。
+func normalContent(t *testing.T, ctx *model.Context, i int) []byte {
+	t.Helper()
+	pageDict, _, _, err := ctx.PageDict(i, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, _ := ctx.DereferenceDict(pageDict["Resources"])
+	xobj, _ := ctx.DereferenceDict(res["XObject"])
+	for m := 0; m < maskNum; m++ {
+		if _, ok := xobj[maskResName(m)]; !ok {
+			t.Fatalf("page %d has no %s", i, maskResName(m))
+		}
+	}
+	normal, _, err := ctx.DereferenceStreamDict(xobj[resNormalContent])
+	if err != nil || normal == nil {
+		t.Fatalf("page %d has no %s form: %v", i, resNormalContent, err)
+	}
+	if err := normal.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	return normal.Content
+}
+
+func TestRunIrregularPageContents(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	page := "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >>%s >>"
+	writeRawTestPDF(t, input, []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 5 0 R 6 0 R 7 0 R] /Count 4 >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf(page, " /Contents [8 0 R [9 0 R]]"), // 嵌套数组
+		fmt.Sprintf(page, ""),                           // 没有 Contents
+		fmt.Sprintf(page, " /Contents []"),              // 空数组
+		fmt.Sprintf(page, " /Contents 10 0 R"),          // 指向数组的间接引用
+		textStream("Part A"),
+		textStream("Part B"),
+		"[11 0 R]",
+		textStream("Page 4"),
+	})
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	ctx := readTestPDF(t, opt.Output, "")
+	if ctx.PageCount != 4 {
+		t.Fatalf("page count = %d", ctx.PageCount)
+	}
+
+	// 嵌套数组中的流按顺序合并
+	c := normalContent(t, ctx, 1)
+	a, b := bytes.Index(c, []byte("(Part A) Tj")), bytes.Index(c, []byte("(Part B) Tj"))
+	if a < 0 || b < a {
+		t.Fatalf("page 1 content:\n%s", c)
+	}
+	// 空页同样加上遮罩，原内容为空
+	for _, i := range []int{2, 3} {
+		if c := normalContent(t, ctx, i); len(bytes.TrimSpace(c)) != 0 {
+			t.Fatalf("page %d content:\n%s", i, c)
+		}
+	}
+	if c := normalContent(t, ctx, 4); !bytes.Contains(c, []byte("(Page 4) Tj")) {
+		t.Fatalf("page 4 content:\n%s", c)
+	}
+}
+
+func TestNormalizeDirectContentStream(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	ctx, err := api.ReadContextFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageDict, _, inh, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 直接流对象只能出现在内存中（如其他工具生成的 Context），写出前要改为间接对象
+	sd, err := ctx.NewStreamDictForBuf([]byte("0 0 1 rg 0 0 20 20 re f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.Encode(); err != nil {
+		t.Fatal(err)
+	}
+	orig := pageDict["Contents"]
+	pageDict["Contents"] = types.Array{types.Array{orig}, *sd}
+
+	if err := normalizePageContents(ctx); err != nil {
+		t.Fatalf("normalizePageContents: %v", err)
+	}
+	arr, ok := pageDict["Contents"].(types.Array)
+	if !ok || len(arr) != 2 || arr[0] != orig {
+		t.Fatalf("Contents = %v", pageDict["Contents"])
+	}
+	if _, ok := arr[1].(types.IndirectRef); !ok {
+		t.Fatalf("direct stream kept inline: %v", arr[1])
+	}
+
+	ref, err := extractPageContentAsXObject(ctx, pageDict, inh)
+	if err != nil {
+		t.Fatalf("extractPageContentAsXObject: %v", err)
+	}
+	form, _, err := ctx.DereferenceStreamDict(*ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := bytes.Index(form.Content, []byte("(Page 1) Tj")), bytes.Index(form.Content, []byte("20 20 re f"))
+	if a < 0 || b < a {
+		t.Fatalf("merged content:\n%s", form.Content)
+	}
+}
@@ -1,28 +1,50 @@
 package engine
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"unicode/utf16"
+
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
-// createCIDType0Font 创建一个 Type0 (CID) 字体并附带最小 FontDescriptor。
-// baseFontName 例如 "STSong-Light"。返回 Type0 字体的间接引用。
-func createCIDType0Font(ctx *model.Context, baseFontName string) (*types.IndirectRef, error) {
+// cidFontSpec 描述一个 Adobe 预定义的 CJK 字体（不嵌入，依赖阅读器的亚洲字体包）。
+type cidFontSpec struct {
+	baseFont   string
+	ordering   string
+	supplement int
+	encoding   string
+}
+
+var (
+	cidFontGB1    = cidFontSpec{"STSong-Light", "GB1", 2, "UniGB-UCS2-H"}
+	cidFontCNS1   = cidFontSpec{"MSung-Light", "CNS1", 0, "UniCNS-UCS2-H"}
+	cidFontJapan1 = cidFontSpec{"HeiseiMin-W3", "Japan1", 2, "UniJIS-UCS2-H"}
+	cidFontKorea1 = cidFontSpec{"HYSMyeongJo-Medium", "Korea1", 1, "UniKS-UCS2-H"}
+)
+
+// createCIDType0Font 按 spec 创建一个 Type0 (CID) 字体并附带最小 FontDescriptor，返回 Type0 字体的间接引用。
+// 字体不嵌入，引用预定义 CJK 字符集的 CID 字体须为 CIDFontType0，由阅读器用本机的 CJK 字体替代。
+func createCIDType0Font(ctx *model.Context, spec cidFontSpec) (*types.IndirectRef, error) {
 	cidDict := types.Dict{
 		"Type":     types.Name("Font"),
-		"Subtype":  types.Name("CIDFontType2"),
-		"BaseFont": types.Name(baseFontName),
+		"Subtype":  types.Name("CIDFontType0"),
+		"BaseFont": types.Name(spec.baseFont),
 		"CIDSystemInfo": types.Dict{
 			"Registry":   types.StringLiteral("Adobe"),
-			"Ordering":   types.StringLiteral("GB1"),
-			"Supplement": types.Integer(2),
+			"Ordering":   types.StringLiteral(spec.ordering),
+			"Supplement": types.Integer(spec.supplement),
 		},
 	}
 
 	// 最小 FontDescriptor，防止 Adobe Reader 报 /FontBBox 问题
 	fd := types.Dict{
 		"Type":        types.Name("FontDescriptor"),
-		"FontName":    types.Name(baseFontName),
+		"FontName":    types.Name(spec.baseFont),
 		"Flags":       types.Integer(4),
 		"FontBBox":    types.Array{types.Integer(-500), types.Integer(-500), types.Integer(1500), types.Integer(1500)},
 		"ItalicAngle": types.Integer(0),
@@ -46,8 +68,8 @@ func createCIDType0Font(ctx *model.Context, baseFontName string) (*types.Indirec
 	type0 := types.Dict{
 		"Type":            types.Name("Font"),
 		"Subtype":         types.Name("Type0"),
-		"BaseFont":        types.Name(baseFontName),
-		"Encoding":        types.Name("UniGB-UCS2-H"),
+		"BaseFont":        types.Name(spec.baseFont),
+		"Encoding":        types.Name(spec.encoding),
 		"DescendantFonts": types.Array{*cidRef},
 	}
 
@@ -57,3 +79,203 @@ func createCIDType0Font(ctx *model.Context, baseFontName string) (*types.Indirec
 	}
 	return type0Ref, nil
 }
+
+// createHelveticaFont 创建 Latin Type1 Helvetica + WinAnsiEncoding 字体。
+func createHelveticaFont(ctx *model.Context) (*types.IndirectRef, error) {
+	return ctx.IndRefForNewObject(types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("Type1"),
+		"BaseFont": types.Name("Helvetica"),
+		"Encoding": types.Name("WinAnsiEncoding"),
+	})
+}
+
+// embeddedFont 是嵌入了 TrueType 子集的 Type0 字体，文本按 GID 以 Identity-H 双字节编码输出。
+type embeddedFont struct {
+	ref    *types.IndirectRef
+	chars  map[uint32]uint16
+	widths []int
+}
+
+// glyph 返回 r 在子集字体中的 GID，字体不包含该字符时 ok 为 false。
+func (f *embeddedFont) glyph(r rune) (uint16, bool) {
+	gid, ok := f.chars[uint32(r)]
+	if !ok || gid == 0 {
+		return 0, false
+	}
+	return gid, true
+}
+
+// width 返回 gid 的字宽（1000 单位字形空间）。
+func (f *embeddedFont) width(gid uint16) int {
+	if int(gid) >= len(f.widths) {
+		return 1000
+	}
+	return f.widths[gid]
+}
+
+// createEmbeddedType0Font 基于已安装的 pdfcpu 用户字体 fontName 创建 Type0 字体，
+// 只嵌入 text 中用到的字形，并附带真实字宽 (W) 与 ToUnicode CMap，便于复制与检索。
+func createEmbeddedType0Font(ctx *model.Context, fontName, text string) (*embeddedFont, error) {
+	pdffont.UserFontMetricsLock.RLock()
+	ttf, ok := pdffont.UserFontMetrics[fontName]
+	pdffont.UserFontMetricsLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("user font not installed: %s", fontName)
+	}
+
+	// GID 0 (.notdef) 必须保留
+	used := map[uint16]bool{0: true}
+	toUnicode := map[uint16]rune{}
+	for _, r := range text {
+		gid, ok := ttf.Chars[uint32(r)]
+		if !ok || gid == 0 {
+			continue
+		}
+		used[gid] = true
+		toUnicode[gid] = r
+	}
+	gids := make([]int, 0, len(used))
+	for gid := range used {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	bb, err := pdffont.Subset(fontName, used)
+	if err != nil {
+		return nil, fmt.Errorf("subset font %s: %w", fontName, err)
+	}
+	fontFile, err := ctx.NewStreamDictForBuf(bb)
+	if err != nil {
+		return nil, err
+	}
+	fontFile.InsertInt("Length1", len(bb))
+	if err := fontFile.Encode(); err != nil {
+		return nil, err
+	}
+	fontFileRef, err := ctx.IndRefForNewObject(*fontFile)
+	if err != nil {
+		return nil, err
+	}
+
+	baseFontName := subsetTag(fontName, gids) + "+" + fontName
+	fd := types.Dict{
+		"Type":        types.Name("FontDescriptor"),
+		"FontName":    types.Name(baseFontName),
+		"Flags":       types.Integer(4),
+		"FontBBox":    types.NewNumberArray(ttf.LLx, ttf.LLy, ttf.URx, ttf.URy),
+		"ItalicAngle": types.Float(ttf.ItalicAngle),
+		"Ascent":      types.Integer(ttf.Ascent),
+		"Descent":     types.Integer(ttf.Descent),
+		"CapHeight":   types.Integer(ttf.CapHeight),
+		"StemV":       types.Integer(80),
+		"FontFile2":   *fontFileRef,
+	}
+	fdRef, err := ctx.IndRefForNewObject(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	// W: [gid [width]]，宽度已是 1000 单位的字形空间
+	w := types.Array{}
+	for _, gid := range gids {
+		if gid >= len(ttf.GlyphWidths) {
+			continue
+		}
+		w = append(w, types.Integer(gid), types.Array{types.Integer(ttf.GlyphWidths[gid])})
+	}
+
+	cidDict := types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("CIDFontType2"),
+		"BaseFont": types.Name(baseFontName),
+		"CIDSystemInfo": types.Dict{
+			"Registry":   types.StringLiteral("Adobe"),
+			"Ordering":   types.StringLiteral("Identity"),
+			"Supplement": types.Integer(0),
+		},
+		"FontDescriptor": *fdRef,
+		"CIDToGIDMap":    types.Name("Identity"),
+		"DW":             types.Integer(1000),
+		"W":              w,
+	}
+	cidRef, err := ctx.IndRefForNewObject(cidDict)
+	if err != nil {
+		return nil, err
+	}
+
+	toUniRef, err := buildToUnicodeCMap(ctx, toUnicode)
+	if err != nil {
+		return nil, err
+	}
+
+	type0 := types.Dict{
+		"Type":            types.Name("Font"),
+		"Subtype":         types.Name("Type0"),
+		"BaseFont":        types.Name(baseFontName),
+		"Encoding":        types.Name("Identity-H"),
+		"DescendantFonts": types.Array{*cidRef},
+		"ToUnicode":       *toUniRef,
+	}
+	type0Ref, err := ctx.IndRefForNewObject(type0)
+	if err != nil {
+		return nil, err
+	}
+	return &embeddedFont{ref: type0Ref, chars: ttf.Chars, widths: ttf.GlyphWidths}, nil
+}
+
+// buildToUnicodeCMap 生成 GID -> Unicode 的 ToUnicode CMap 流。
+func buildToUnicodeCMap(ctx *model.Context, toUnicode map[uint16]rune) (*types.IndirectRef, error) {
+	gids := make([]int, 0, len(toUnicode))
+	for gid := range toUnicode {
+		gids = append(gids, int(gid))
+	}
+	sort.Ints(gids)
+
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	// 每个 bfchar 段最多 100 项
+	for i := 0; i < len(gids); i += 100 {
+		end := i + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-i)
+		for _, gid := range gids[i:end] {
+			fmt.Fprintf(&b, "<%04X> <", gid)
+			for _, u := range utf16.Encode([]rune{toUnicode[uint16(gid)]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+
+	sd, err := ctx.NewStreamDictForBuf(b.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err := sd.Encode(); err != nil {
+		return nil, err
+	}
+	return ctx.IndRefForNewObject(*sd)
+}
+
+// subsetTag 根据字体名和所用字形生成稳定的 6 位大写子集前缀（PDF 规范 9.6.4）。
+func subsetTag(fontName string, gids []int) string {
+	h := sha1.New()
+	h.Write([]byte(fontName))
+	for _, gid := range gids {
+		h.Write([]byte{byte(gid >> 8), byte(gid)})
+	}
+	sum := h.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	return string(tag)
+}
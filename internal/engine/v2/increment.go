@@ -0,0 +1,496 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// increment 把新建和修改过的对象作为一个新修订追加到原文件之后（ISO 32000-1 7.5.6）：
+// 原文件字节保持不变，新的交叉引用段通过 Prev 指向原来的最后一段。
+// 原文件使用交叉引用流时新段也写成交叉引用流，否则写 xref 表与 trailer。
+// 加密文档中新写出的字符串与流按原文件的密钥加密，签名字典的 /Contents 除外。
+type increment struct {
+	ctx     *model.Context
+	base    int64 // 原文件长度，新写出内容的偏移从这里开始
+	buf     bytes.Buffer
+	objNrs  []int
+	offsets map[int]int64
+
+	// 签名字典的对象编号与占位符在 buf 中的位置，见 writeSigDict
+	sigObjNr     int
+	sigByteRange int
+	sigContents  int
+	sigLen       int
+}
+
+// sigByteRangeWidth 是 ByteRange 占位符的宽度，足够写下 4 个 10 位数字。
+const sigByteRangeWidth = len("[0 0000000000 0000000000 0000000000]")
+
+func newIncrement(ctx *model.Context, base int64) *increment {
+	inc := &increment{ctx: ctx, base: base, offsets: map[int]int64{}, sigByteRange: -1, sigContents: -1}
+	// 原文件末尾的 %%EOF 之后可能没有换行
+	inc.buf.WriteString("\n")
+	return inc
+}
+
+// add 登记需要写入本次修订的对象，重复登记会被忽略。
+func (inc *increment) add(objNrs ...int) {
+	for _, n := range objNrs {
+		if _, ok := inc.offsets[n]; ok {
+			continue
+		}
+		inc.offsets[n] = -1
+		inc.objNrs = append(inc.objNrs, n)
+	}
+}
+
+// addSignature 登记签名字典，写出时为 ByteRange 与 Contents 留出占位符。
+func (inc *increment) addSignature(objNr int) {
+	inc.sigObjNr = objNr
+	inc.add(objNr)
+}
+
+// objectDigest 是对象序列化后的摘要，流对象包括编码后的数据。
+type objectDigest [sha256.Size]byte
+
+// objectDigests 记录每个在用对象的摘要，处理后与之比较找出修改过的对象。
+func objectDigests(ctx *model.Context) (map[int]objectDigest, error) {
+	digests := map[int]objectDigest{}
+	for n := range ctx.Table {
+		o, err := tableObject(ctx, n)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", n, err)
+		}
+		if o != nil {
+			digests[n] = digestObject(o)
+		}
+	}
+	return digests, nil
+}
+
+// tableObject 返回在用的对象 n；对象流中尚未解码的对象先解码，使前后比较与写出使用同一形式。
+func tableObject(ctx *model.Context, n int) (types.Object, error) {
+	e, ok := ctx.FindTableEntryLight(n)
+	if n == 0 || !ok || e == nil || e.Free || e.Object == nil {
+		return nil, nil
+	}
+	gen := 0
+	if e.Generation != nil {
+		gen = *e.Generation
+	}
+	return ctx.Dereference(*types.NewIndirectRef(n, gen))
+}
+
+func digestObject(o types.Object) objectDigest {
+	h := sha256.New()
+	if sd, ok := o.(types.StreamDict); ok {
+		h.Write([]byte(sd.Dict.PDFString()))
+		if sd.Raw != nil {
+			h.Write(sd.Raw)
+		} else {
+			h.Write(sd.Content)
+		}
+	} else {
+		h.Write([]byte(o.PDFString()))
+	}
+	var d objectDigest
+	h.Sum(d[:0])
+	return d
+}
+
+// addChangedObjects 登记与 before 相比新建或修改过的全部对象。
+func (inc *increment) addChangedObjects(before map[int]objectDigest) error {
+	after, err := objectDigests(inc.ctx)
+	if err != nil {
+		return err
+	}
+	var nrs []int
+	for n, d := range after {
+		if b, ok := before[n]; !ok || b != d {
+			nrs = append(nrs, n)
+		}
+	}
+	sort.Ints(nrs)
+	inc.add(nrs...)
+	return nil
+}
+
+// usedObjects 返回当前在用的对象编号，修改前记录一次，供 addNewObjects 找出新建的对象。
+func usedObjects(ctx *model.Context) map[int]bool {
+	used := map[int]bool{}
+	for n, e := range ctx.Table {
+		if e != nil && !e.Free && e.Object != nil {
+			used[n] = true
+		}
+	}
+	return used
+}
+
+// addNewObjects 登记 before 之后新建的全部对象（包括复用的空闲编号）。
+func (inc *increment) addNewObjects(before map[int]bool) {
+	var nrs []int
+	for n := range usedObjects(inc.ctx) {
+		if n > 0 && !before[n] {
+			nrs = append(nrs, n)
+		}
+	}
+	sort.Ints(nrs)
+	inc.add(nrs...)
+}
+
+// write 写出全部登记的对象和交叉引用段，返回追加到原文件之后的字节。
+func (inc *increment) write() ([]byte, error) {
+	for _, n := range inc.objNrs {
+		if err := inc.writeObject(n); err != nil {
+			return nil, fmt.Errorf("write object %d: %w", n, err)
+		}
+	}
+	if inc.ctx.Read.UsingXRefStreams {
+		if err := inc.writeXRefStream(); err != nil {
+			return nil, err
+		}
+	} else {
+		inc.writeXRefTable()
+	}
+	return inc.buf.Bytes(), nil
+}
+
+func (inc *increment) offset() int64 {
+	return inc.base + int64(inc.buf.Len())
+}
+
+func (inc *increment) writeObject(objNr int) error {
+	obj, err := tableObject(inc.ctx, objNr)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		return fmt.Errorf("undefined object")
+	}
+	gen := inc.generation(objNr)
+	inc.offsets[objNr] = inc.offset()
+	fmt.Fprintf(&inc.buf, "%d %d obj\n", objNr, gen)
+
+	switch o := obj.(type) {
+	case types.StreamDict:
+		if o.Raw == nil {
+			if err := o.Encode(); err != nil {
+				return err
+			}
+		}
+		raw, err := inc.encryptBytes(o.Raw, objNr, gen, inc.ctx.AES4Streams)
+		if err != nil {
+			return err
+		}
+		d := o.Dict.Clone().(types.Dict)
+		d["Length"] = types.Integer(len(raw))
+		enc, err := inc.encrypt(d, objNr, gen)
+		if err != nil {
+			return err
+		}
+		inc.buf.WriteString(enc.PDFString())
+		inc.buf.WriteString("\nstream\n")
+		inc.buf.Write(raw)
+		inc.buf.WriteString("\nendstream")
+	case types.Dict:
+		if objNr == inc.sigObjNr {
+			if err := inc.writeSigDict(o, objNr, gen); err != nil {
+				return err
+			}
+			break
+		}
+		enc, err := inc.encrypt(o, objNr, gen)
+		if err != nil {
+			return err
+		}
+		inc.buf.WriteString(enc.PDFString())
+	default:
+		enc, err := inc.encrypt(o, objNr, gen)
+		if err != nil {
+			return err
+		}
+		if enc == nil {
+			inc.buf.WriteString("null")
+		} else {
+			inc.buf.WriteString(enc.PDFString())
+		}
+	}
+	inc.buf.WriteString("\nendobj\n")
+	return nil
+}
+
+// writeSigDict 写出签名字典：ByteRange 与 Contents 放在末尾并记录位置，签名后原位回填。
+// Contents 按规范不加密，其余字符串照常加密。
+func (inc *increment) writeSigDict(d types.Dict, objNr, gen int) error {
+	contents, ok := d["Contents"].(types.HexLiteral)
+	if !ok {
+		return fmt.Errorf("signature dict: missing Contents placeholder")
+	}
+	rest := d.Clone().(types.Dict)
+	delete(rest, "Contents")
+	delete(rest, "ByteRange")
+	enc, err := inc.encrypt(rest, objNr, gen)
+	if err != nil {
+		return err
+	}
+	s := strings.TrimSuffix(enc.PDFString(), ">>")
+	inc.buf.WriteString(s)
+	inc.buf.WriteString("/ByteRange")
+	inc.sigByteRange = inc.buf.Len()
+	inc.buf.WriteString(fmt.Sprintf("%-*s", sigByteRangeWidth, "[0 0 0 0]"))
+	inc.buf.WriteString("/Contents")
+	inc.sigContents = inc.buf.Len()
+	inc.buf.WriteString(contents.PDFString())
+	inc.sigLen = inc.buf.Len() - inc.sigContents
+	inc.buf.WriteString(">>")
+	return nil
+}
+
+func (inc *increment) writeXRefTable() {
+	start := inc.offset()
+	inc.buf.WriteString("xref\n")
+	nrs := inc.sortedObjNrs()
+	for i := 0; i < len(nrs); {
+		j := i + 1
+		for j < len(nrs) && nrs[j] == nrs[j-1]+1 {
+			j++
+		}
+		fmt.Fprintf(&inc.buf, "%d %d\n", nrs[i], j-i)
+		for _, n := range nrs[i:j] {
+			fmt.Fprintf(&inc.buf, "%010d %05d n\r\n", inc.offsets[n], inc.generation(n))
+		}
+		i = j
+	}
+	trailer := inc.trailerDict(inc.size())
+	inc.buf.WriteString("trailer\n")
+	inc.buf.WriteString(trailer.PDFString())
+	fmt.Fprintf(&inc.buf, "\nstartxref\n%d\n%%%%EOF\n", start)
+}
+
+func (inc *increment) writeXRefStream() error {
+	objNr := inc.size()
+	start := inc.offset()
+	inc.offsets[objNr] = start
+	nrs := append(inc.sortedObjNrs(), objNr)
+
+	// 字段宽度：类型 1 字节，偏移按最大值取字节数，代数 2 字节
+	w2 := 1
+	for v := start >> 8; v > 0; v >>= 8 {
+		w2++
+	}
+	var (
+		data  []byte
+		index types.Array
+	)
+	for i := 0; i < len(nrs); {
+		j := i + 1
+		for j < len(nrs) && nrs[j] == nrs[j-1]+1 {
+			j++
+		}
+		index = append(index, types.Integer(nrs[i]), types.Integer(j-i))
+		for _, n := range nrs[i:j] {
+			data = append(data, 1)
+			off := inc.offsets[n]
+			for k := w2 - 1; k >= 0; k-- {
+				data = append(data, byte(off>>(8*k)))
+			}
+			gen := inc.generation(n)
+			data = append(data, byte(gen>>8), byte(gen))
+		}
+		i = j
+	}
+
+	sd, err := inc.ctx.NewStreamDictForBuf(data)
+	if err != nil {
+		return err
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	d := inc.trailerDict(objNr + 1)
+	for k, v := range sd.Dict {
+		d[k] = v
+	}
+	d["Type"] = types.Name("XRef")
+	d["W"] = types.Array{types.Integer(1), types.Integer(w2), types.Integer(2)}
+	d["Index"] = index
+	d["Length"] = types.Integer(len(sd.Raw))
+
+	fmt.Fprintf(&inc.buf, "%d 0 obj\n", objNr)
+	inc.buf.WriteString(d.PDFString())
+	inc.buf.WriteString("\nstream\n")
+	inc.buf.Write(sd.Raw)
+	inc.buf.WriteString("\nendstream\nendobj\n")
+	fmt.Fprintf(&inc.buf, "startxref\n%d\n%%%%EOF\n", start)
+	return nil
+}
+
+func (inc *increment) trailerDict(size int) types.Dict {
+	ctx := inc.ctx
+	d := types.Dict{
+		"Size": types.Integer(size),
+		"Root": *ctx.Root,
+	}
+	if ctx.Info != nil {
+		d["Info"] = *ctx.Info
+	}
+	if ctx.ID != nil {
+		d["ID"] = ctx.ID
+	}
+	if ctx.Encrypt != nil {
+		d["Encrypt"] = *ctx.Encrypt
+	}
+	if ctx.Write.OffsetPrevXRef != nil {
+		d["Prev"] = types.Integer(*ctx.Write.OffsetPrevXRef)
+	}
+	return d
+}
+
+// size 返回新段 trailer 的 Size：大于所有已用对象编号。
+func (inc *increment) size() int {
+	size := 0
+	if inc.ctx.Size != nil {
+		size = *inc.ctx.Size
+	}
+	for n := range inc.ctx.Table {
+		if n+1 > size {
+			size = n + 1
+		}
+	}
+	return size
+}
+
+func (inc *increment) sortedObjNrs() []int {
+	nrs := append([]int(nil), inc.objNrs...)
+	sort.Ints(nrs)
+	return nrs
+}
+
+func (inc *increment) generation(objNr int) int {
+	if e, ok := inc.ctx.FindTableEntryLight(objNr); ok && e.Generation != nil {
+		return *e.Generation
+	}
+	return 0
+}
+
+// patchByteRange 回填 ByteRange，返回 /Contents 占位符在 buf 中的起止位置。
+// 签名覆盖原文件全部字节与 buf 中占位符以外的部分。
+func (inc *increment) patchByteRange() (contentsStart, contentsEnd int, err error) {
+	if inc.sigContents < 0 {
+		return 0, 0, fmt.Errorf("no signature dict in increment")
+	}
+	contentsStart, contentsEnd = inc.sigContents, inc.sigContents+inc.sigLen
+	total := inc.base + int64(inc.buf.Len())
+	a := inc.base + int64(contentsStart)
+	b := inc.base + int64(contentsEnd)
+	br := fmt.Sprintf("[0 %d %d %d]", a, b, total-b)
+	if len(br) > sigByteRangeWidth {
+		return 0, 0, fmt.Errorf("byte range too long: %s", br)
+	}
+	copy(inc.buf.Bytes()[inc.sigByteRange:], fmt.Sprintf("%-*s", sigByteRangeWidth, br))
+	return contentsStart, contentsEnd, nil
+}
+
+// encrypt 返回按原文件密钥加密了字符串的对象副本；未加密文档原样返回。
+func (inc *increment) encrypt(o types.Object, objNr, gen int) (types.Object, error) {
+	if inc.ctx.EncKey == nil || o == nil {
+		return o, nil
+	}
+	switch v := o.(type) {
+	case types.Dict:
+		d := types.Dict{}
+		for k, e := range v {
+			enc, err := inc.encrypt(e, objNr, gen)
+			if err != nil {
+				return nil, err
+			}
+			d[k] = enc
+		}
+		return d, nil
+	case types.Array:
+		a := make(types.Array, len(v))
+		for i, e := range v {
+			enc, err := inc.encrypt(e, objNr, gen)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = enc
+		}
+		return a, nil
+	case types.StringLiteral:
+		b, err := types.Unescape(v.Value())
+		if err != nil {
+			return nil, err
+		}
+		if b, err = inc.encryptBytes(b, objNr, gen, inc.ctx.AES4Strings); err != nil {
+			return nil, err
+		}
+		return types.NewHexLiteral(b), nil
+	case types.HexLiteral:
+		b, err := v.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		if b, err = inc.encryptBytes(b, objNr, gen, inc.ctx.AES4Strings); err != nil {
+			return nil, err
+		}
+		return types.NewHexLiteral(b), nil
+	}
+	return o, nil
+}
+
+// encryptBytes 按 ISO 32000-1 7.6.2 加密：R5/R6 直接使用文件密钥，否则按对象编号派生密钥。
+func (inc *increment) encryptBytes(b []byte, objNr, gen int, useAES bool) ([]byte, error) {
+	ctx := inc.ctx
+	if ctx.EncKey == nil || ctx.E == nil {
+		return b, nil
+	}
+	key := ctx.EncKey
+	if ctx.E.R != 5 && ctx.E.R != 6 {
+		h := md5.New()
+		h.Write(ctx.EncKey)
+		h.Write([]byte{byte(objNr), byte(objNr >> 8), byte(objNr >> 16), byte(gen), byte(gen >> 8)})
+		if useAES {
+			h.Write([]byte("sAlT"))
+		}
+		key = h.Sum(nil)
+		if n := len(ctx.EncKey) + 5; n < 16 {
+			key = key[:n]
+		}
+	}
+	if !useAES {
+		c, err := rc4.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, len(b))
+		c.XORKeyStream(out, b)
+		return out, nil
+	}
+
+	// AES-CBC，PKCS#5 填充，随机 IV 放在密文前
+	pad := aes.BlockSize - len(b)%aes.BlockSize
+	plain := append(append([]byte(nil), b...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, aes.BlockSize+len(plain))
+	if _, err := io.ReadFull(rand.Reader, out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).CryptBlocks(out[aes.BlockSize:], plain)
+	return out, nil
+}
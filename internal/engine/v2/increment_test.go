@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// runIncremental 以增量方式处理 input，检查原文件字节是输出的前缀且输出可用输入的打开密码由 pdfcpu 校验，返回输出内容。
+func runIncremental(t *testing.T, input string, opt Options) []byte {
+	t.Helper()
+	orig, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt.OutputMode = OutputIncremental
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	out, err := os.ReadFile(opt.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, orig) {
+		t.Fatal("original revision is not a prefix of the output")
+	}
+	if len(out) == len(orig) {
+		t.Fatal("no revision appended")
+	}
+	ctx := readTestPDF(t, opt.Output, opt.InputPassword)
+	if ctx.PageCount != 2 {
+		t.Fatalf("page count = %d", ctx.PageCount)
+	}
+	for _, key := range []string{"OCProperties", "OpenAction"} {
+		if _, ok := ctx.RootDict[key]; !ok {
+			t.Fatalf("catalog has no /%s", key)
+		}
+	}
+	return out
+}
+
+func TestIncrementalOutputXRefTable(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	orig := writeTestPDF(t, input, 2)
+
+	out := runIncremental(t, input, testOptions(input, filepath.Join(dir, "out.pdf")))
+	tail := out[len(orig):]
+	xref := bytes.LastIndex(orig, []byte("startxref"))
+	prev := string(bytes.Fields(orig[xref+len("startxref"):])[0])
+	if !regexp.MustCompile(`/Prev\s+` + prev + `\b`).Match(tail) {
+		t.Fatalf("new trailer does not point to the original xref at %s", prev)
+	}
+	if !bytes.Contains(tail, []byte("\nxref\n")) {
+		t.Fatal("new section is not an xref table")
+	}
+}
+
+func TestIncrementalOutputXRefStream(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.pdf")
+	writeTestPDF(t, plain, 2)
+	// pdfcpu 默认写出对象流与交叉引用流
+	input := filepath.Join(dir, "in.pdf")
+	if err := api.OptimizeFile(plain, input, nil); err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := os.ReadFile(input)
+
+	out := runIncremental(t, input, testOptions(input, filepath.Join(dir, "out.pdf")))
+	if !regexp.MustCompile(`/Type\s*/XRef`).Match(out[len(orig):]) {
+		t.Fatal("new section is not a cross-reference stream")
+	}
+}
+
+func TestIncrementalEncryptedInput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.pdf")
+	writeTestPDF(t, plain, 2)
+	input := filepath.Join(dir, "in.pdf")
+	conf := model.NewAESConfiguration("u1", "o1", 256)
+	if err := api.EncryptFile(plain, input, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	// 追加的修订不能去掉或更换原有的加密
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.OutputMode = OutputIncremental
+	opt.InputPassword = "u1"
+	for _, enc := range []string{EncryptionNone, EncryptionAES128} {
+		opt.Encryption = enc
+		if err := Run(opt); err == nil || !strings.Contains(err.Error(), "加密方式") {
+			t.Fatalf("Encryption %s: Run = %v", enc, err)
+		}
+		if _, err := os.Stat(opt.Output); !os.IsNotExist(err) {
+			t.Fatalf("Encryption %s: output written", enc)
+		}
+	}
+
+	// 沿用输入的加密：新修订同样需要原来的打开密码
+	opt.Encryption = ""
+	runIncremental(t, input, opt)
+	if opensWith(opt.Output, "") {
+		t.Fatal("incremental output opens without the input password")
+	}
+	opt.Encryption = EncryptionAES256
+	opt.Output = filepath.Join(dir, "out-aes256.pdf")
+	runIncremental(t, input, opt)
+}
+
+func TestIncrementalSignedOutput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 2)
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.Sign = SignOptions{Enabled: true, Reason: "test"}
+	opt.Sign.CertPath, opt.Sign.KeyPath = writeTestSigner(t, dir)
+	out := runIncremental(t, input, opt)
+
+	// ByteRange 覆盖除 /Contents 以外的整个文件，签名中的摘要与之一致
+	m := regexp.MustCompile(`/ByteRange\s*\[\s*(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s*\]`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("no /ByteRange in output")
+	}
+	var br [4]int
+	for i := range br {
+		br[i], _ = strconv.Atoi(string(m[i+1]))
+	}
+	if br[0] != 0 || br[2]+br[3] != len(out) {
+		t.Fatalf("ByteRange %v does not cover the file (%d bytes)", br, len(out))
+	}
+	// 占位符中签名之后的部分保持为 0
+	der, err := hex.DecodeString(string(bytes.Trim(out[br[1]:br[2]], "<>")))
+	if err != nil {
+		t.Fatalf("decode /Contents: %v", err)
+	}
+	h := sha256.New()
+	h.Write(out[:br[1]])
+	h.Write(out[br[2]:])
+	if !bytes.Contains(der, h.Sum(nil)) {
+		t.Fatal("signature does not carry the digest of the signed byte range")
+	}
+}
+
+// writeTestSigner 生成自签名证书与私钥（PEM），返回两者的路径。
+func writeTestSigner(t *testing.T, dir string) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath = filepath.Join(dir, "signer.crt"), filepath.Join(dir, "signer.key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
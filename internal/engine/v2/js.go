@@ -2,15 +2,23 @@ package engine
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+
+	"github.com/cg917658910/win-pdf/internal/logging"
 )
 
-func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText, unsupportedText string) {
+// injectOpenActionJS 注入有效期校验脚本。脚本只切换 engineOCGs 中列出的 OCG：按写出时记录的 /OCGs 位置取 getOCGs()
+// 的对象并核对名称，原文档自身的图层保持不变；过期后关闭 expiredOff 中的 OCG。total 为 /OCGs 的长度，
+// 阅读器返回的 OCG 数量不同（顺序不可靠）时改按名称（带文档级随机后缀）查找。
+func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText, unsupportedText string, engineOCGs, expiredOff []jsOCG, total int) {
 	escapedExpiredText := escapeJSString(experiredText)
+	engineJSON, _ := json.Marshal(engineOCGs)
+	expiredOffJSON, _ := json.Marshal(expiredOff)
 
 	js := fmt.Sprintf(`(function(){
   try{
@@ -37,38 +45,37 @@ func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText,
         //alertMsg("无法获取 OCG 列表，可能无法正确显示水印和过期提示！");
         return null;
     };
-    // 关闭所有 OCG
-    var closeAllOCGs = function(){
-        var ocgs = myOCGs();
-        if (ocgs && ocgs.length) {
-            for (var i = 0; i < ocgs.length; i++) {
-              if (ocgs[i]) {
-                // 关闭不是水印的 OCG
-                if (!ocgs[i].name || ocgs[i].name.indexOf("Watermark") !== 0) {
-                  ocgs[i].state = false;
-                }
-              }
-            }
+    var engineOCGs = %s;
+    var expiredOff = %s;
+    var total = %d;
+    // 按写出时的位置找到引擎创建的 OCG，位置上的名称不符时才按名称查找
+    var findOCG = function(ocgs, e){
+        var o = (ocgs.length === total && e.i >= 0) ? ocgs[e.i] : null;
+        if (o && o.name === e.n) {
+            return o;
         }
+        for (var j = 0; j < ocgs.length; j++) {
+          if (ocgs[j] && ocgs[j].name === e.n) {
+            return ocgs[j];
+          }
+        }
+        return null;
     };
-    // 关闭text_*OCG
-    var closeTextOCGs = function(){
+    // 关闭列表中的 OCG（只操作引擎创建的 OCG）
+    var closeOCGs = function(list){
         var ocgs = myOCGs();
         if (ocgs && ocgs.length) {
-            for (var i = 0; i < ocgs.length; i++) {
-              if (ocgs[i] && ocgs[i].name && ocgs[i].name.indexOf("text_") === 0) {
-                ocgs[i].state = false;
-              }
-              //关闭 expired_mask_* OCG
-              if (ocgs[i] && ocgs[i].name && ocgs[i].name.indexOf("expired_mask_") === 0) {
-                ocgs[i].state = false;
+            for (var i = 0; i < list.length; i++) {
+              var o = findOCG(ocgs, list[i]);
+              if (o) {
+                o.state = false;
               }
             }
         }
     };
       if(!inRange){
-        // 过期关闭text提示
-        closeTextOCGs();
+        // 过期关闭 text 提示与 expired_mask
+        closeOCGs(expiredOff);
         if("%s" !== ""){
           alertMsg("%s");
         }
@@ -82,11 +89,11 @@ func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText,
 		}
         return;
       }
-      // zh: 在有效期内，关闭所有 OCG
-      closeAllOCGs();
+      // zh: 在有效期内，关闭引擎创建的所有 OCG
+      closeOCGs(engineOCGs);
       return;
     } catch (e) {}
-})();`, start.Format(time.RFC3339), end.Format(time.RFC3339), escapedExpiredText, escapedExpiredText)
+})();`, start.Format(time.RFC3339), end.Format(time.RFC3339), engineJSON, expiredOffJSON, total, escapedExpiredText, escapedExpiredText)
 
 	// 假设 encodeJSUTF16BE 返回 []byte（UTF‑16BE 带 BOM）
 	utf16Bytes := encodeJSUTF16BE(js)
@@ -94,12 +101,45 @@ func injectOpenActionJS(ctx *model.Context, start, end time.Time, experiredText,
 	// 编成十六进制放进 HexLiteral
 	hexStr := hex.EncodeToString(utf16Bytes)
 
-	iref, err := ctx.IndRefForNewObject(types.Dict{
+	action := types.Dict{
 		"S":  types.Name("JavaScript"),
 		"JS": types.HexLiteral(hexStr),
-	})
+	}
+	// 先执行有效期校验，再通过 /Next 执行文档原有的打开动作（跳转页面/缩放或其它动作）
+	if next := originalOpenAction(ctx); next != nil {
+		action["Next"] = next
+	}
+	iref, err := ctx.IndRefForNewObject(action)
 	if err != nil {
-		fmt.Printf("injectOpenActionJS: %v\n", err)
+		logging.Error("inject OpenAction JavaScript", "err", err)
+		return
 	}
 	ctx.RootDict["OpenAction"] = *iref
 }
+
+// originalOpenAction 把文档原有的 OpenAction 转换为可放入 /Next 的动作：
+// 目标（数组或命名目标）包装为 GoTo 动作，动作字典原样引用；没有或无法识别时返回 nil。
+func originalOpenAction(ctx *model.Context) types.Object {
+	orig, ok := ctx.RootDict["OpenAction"]
+	if !ok || orig == nil {
+		return nil
+	}
+	obj, err := ctx.Dereference(orig)
+	if err != nil || obj == nil {
+		logging.Warn("ignore unresolvable OpenAction", "err", err)
+		return nil
+	}
+	switch o := obj.(type) {
+	case types.Dict:
+		if o.NameEntry("S") == nil {
+			return nil
+		}
+		return orig
+	case types.Array, types.Name, types.StringLiteral, types.HexLiteral:
+		return types.Dict{
+			"S": types.Name("GoTo"),
+			"D": orig,
+		}
+	}
+	return nil
+}
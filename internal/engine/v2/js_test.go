@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// actionScript 返回动作字典中 /JS 的文本。
+func actionScript(t *testing.T, ctx *model.Context, d types.Dict) string {
+	t.Helper()
+	obj, err := ctx.Dereference(d["JS"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch v := obj.(type) {
+	case types.StringLiteral:
+		s, err := types.StringLiteralToString(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	case types.HexLiteral:
+		s, err := types.HexLiteralToString(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	return ""
+}
+
+func TestRunKeepsDocumentScripts(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 2)
+	editTestPDF(t, input, func(ctx *model.Context) {
+		docJS, err := ctx.IndRefForNewObject(types.Dict{"S": types.Name("JavaScript"), "JS": types.StringLiteral("var docInit = 1;")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.RootDict["Names"] = types.Dict{
+			"JavaScript": types.Dict{"Names": types.Array{types.StringLiteral("init"), *docJS}},
+		}
+		ctx.RootDict["OpenAction"] = types.Dict{"S": types.Name("JavaScript"), "JS": types.StringLiteral("var openInit = 1;")}
+	})
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	out := readTestPDF(t, opt.Output, "")
+
+	// 文档级脚本保留在 /Names /JavaScript 中
+	names, err := out.DereferenceDict(out.RootDict["Names"])
+	if err != nil || names == nil {
+		t.Fatalf("output has no /Names: %v", err)
+	}
+	tree, err := out.DereferenceDict(names["JavaScript"])
+	if err != nil || tree == nil {
+		t.Fatalf("output has no JavaScript name tree: %v", err)
+	}
+	kids, _ := tree["Names"].(types.Array)
+	found := false
+	for i := 1; i < len(kids); i += 2 {
+		if a, err := out.DereferenceDict(kids[i]); err == nil && strings.Contains(actionScript(t, out, a), "docInit") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("document script lost: %v", tree)
+	}
+
+	// 有效期校验在前，原有的打开动作通过 /Next 接在后面
+	action, err := out.DereferenceDict(out.RootDict["OpenAction"])
+	if err != nil || action == nil {
+		t.Fatalf("output has no OpenAction: %v", err)
+	}
+	if strings.Contains(actionScript(t, out, action), "openInit") {
+		t.Fatal("original OpenAction replaced the validation script")
+	}
+	next, err := out.DereferenceDict(action["Next"])
+	if err != nil || next == nil || !strings.Contains(actionScript(t, out, next), "openInit") {
+		t.Fatalf("original OpenAction not chained via /Next: %v, %v", next, err)
+	}
+}
@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/color"
+)
+
+// MessageStyle 控制回退/过期提示 XObject 的排版。零值即默认样式：
+// 10pt 灰色文字，左上对齐，按页宽自动换行，无背景框。
+type MessageStyle struct {
+	FontSize   float64 // 字号，默认 10
+	Color      string  // 文字颜色，#RRGGBB 或 pdfcpu 颜色名，默认 #808080
+	Align      string  // 水平对齐：left、center、right，默认 left
+	VAlign     string  // 垂直位置：top、middle、bottom，默认 top
+	Margin     float64 // 排版区域到页面边缘的距离，默认 40
+	LineHeight float64 // 行高倍数，默认 1.4
+	Background string  // 背景框颜色，为空则不绘制
+	Padding    float64 // 背景框内边距，默认 8（仅在有背景框时生效）
+}
+
+// withDefaults 填充未设置的字段。
+func (s MessageStyle) withDefaults() MessageStyle {
+	if s.FontSize <= 0 {
+		s.FontSize = 10
+	}
+	if strings.TrimSpace(s.Color) == "" {
+		s.Color = "#808080"
+	}
+	s.Align = strings.ToLower(strings.TrimSpace(s.Align))
+	s.VAlign = strings.ToLower(strings.TrimSpace(s.VAlign))
+	if s.Margin <= 0 {
+		s.Margin = 40
+	}
+	if s.LineHeight <= 0 {
+		s.LineHeight = 1.4
+	}
+	if strings.TrimSpace(s.Background) == "" {
+		s.Padding = 0
+	} else if s.Padding <= 0 {
+		s.Padding = 8
+	}
+	return s
+}
+
+// textLine 是断行后的一行文字，width 为 1000 单位字形空间下的宽度。
+type textLine struct {
+	runes []rune
+	width int
+}
+
+// lineToken 是断行的最小单位：一个拉丁单词、一个 CJK 字符（连同避头尾标点）或一个空格。
+type lineToken struct {
+	runes []rune
+	width int
+	space bool
+}
+
+// 不能出现在行首的标点（避头）与不能出现在行尾的标点（避尾）。
+const (
+	noLineStart = "，。、；：！？）】》」』〕〉”’…—·%,.;:!?)]}"
+	noLineEnd   = "（【《「『〔〈“‘([{"
+)
+
+// isCJKBreakable 判断 r 两侧是否可以直接断行（汉字、假名、CJK 标点）。
+func isCJKBreakable(r rune) bool {
+	switch classifyRune(r) {
+	case scriptHan, scriptKana, scriptCJKCommon:
+		return true
+	}
+	return false
+}
+
+// tokenize 把段落切分为断行单位。
+func tokenize(tf *textFonts, paragraph []rune) []lineToken {
+	var toks []lineToken
+	var word []rune
+	wordW := 0
+	flushWord := func() {
+		if len(word) > 0 {
+			toks = append(toks, lineToken{runes: word, width: wordW})
+			word, wordW = nil, 0
+		}
+	}
+	for _, r := range paragraph {
+		w := tf.runeWidth(r)
+		switch {
+		case unicode.IsSpace(r):
+			flushWord()
+			toks = append(toks, lineToken{runes: []rune{' '}, width: tf.runeWidth(' '), space: true})
+		case strings.ContainsRune(noLineStart, r) && (len(word) > 0 || len(toks) > 0):
+			// 避头：并入前一个单位
+			if len(word) > 0 {
+				word = append(word, r)
+				wordW += w
+				continue
+			}
+			last := &toks[len(toks)-1]
+			if last.space {
+				toks = append(toks, lineToken{runes: []rune{r}, width: w})
+				continue
+			}
+			last.runes = append(last.runes, r)
+			last.width += w
+		case isCJKBreakable(r):
+			flushWord()
+			if n := len(toks); n > 0 && !toks[n-1].space {
+				prev := toks[n-1].runes
+				if strings.ContainsRune(noLineEnd, prev[len(prev)-1]) {
+					// 避尾：与前一个开括号合并
+					toks[n-1].runes = append(toks[n-1].runes, r)
+					toks[n-1].width += w
+					continue
+				}
+			}
+			toks = append(toks, lineToken{runes: []rune{r}, width: w})
+		default:
+			word = append(word, r)
+			wordW += w
+		}
+	}
+	flushWord()
+	return toks
+}
+
+// breakLines 将段落按 maxWidth（1000 单位字形空间）贪心断行：拉丁文按单词，CJK 按字，
+// 单个单词超过行宽时按字符强制断开。
+func breakLines(tf *textFonts, paragraph []rune, maxWidth int) []textLine {
+	var lines []textLine
+	var cur textLine
+	push := func() {
+		// 去掉行尾空格
+		for len(cur.runes) > 0 && cur.runes[len(cur.runes)-1] == ' ' {
+			cur.runes = cur.runes[:len(cur.runes)-1]
+			cur.width -= tf.runeWidth(' ')
+		}
+		lines = append(lines, cur)
+		cur = textLine{}
+	}
+	for _, tok := range tokenize(tf, paragraph) {
+		if tok.space && len(cur.runes) == 0 {
+			continue
+		}
+		if cur.width+tok.width <= maxWidth {
+			cur.runes = append(cur.runes, tok.runes...)
+			cur.width += tok.width
+			continue
+		}
+		if tok.space {
+			push()
+			continue
+		}
+		if len(cur.runes) > 0 {
+			push()
+		}
+		if tok.width <= maxWidth {
+			cur.runes = append(cur.runes, tok.runes...)
+			cur.width += tok.width
+			continue
+		}
+		for _, r := range tok.runes {
+			w := tf.runeWidth(r)
+			if len(cur.runes) > 0 && cur.width+w > maxWidth {
+				push()
+			}
+			cur.runes = append(cur.runes, r)
+			cur.width += w
+		}
+	}
+	if len(cur.runes) > 0 || len(lines) == 0 {
+		push()
+	}
+	return lines
+}
+
+// layoutMessage 在 box（llx, lly, urx, ury）内排版 text，返回内容流。
+// 文字按 \n 分段，每段独立断行；对齐、字号、颜色、背景框由 style 决定。
+func layoutMessage(tf *textFonts, text string, box [4]float64, style MessageStyle) (string, error) {
+	style = style.withDefaults()
+	size := style.FontSize
+	lineH := size * style.LineHeight
+
+	x0, y0 := box[0]+style.Margin, box[1]+style.Margin
+	x1, y1 := box[2]-style.Margin, box[3]-style.Margin
+	if x1-x0 < size {
+		x0, x1 = box[0], box[2]
+	}
+	if y1-y0 < lineH {
+		y0, y1 = box[1], box[3]
+	}
+	innerW := x1 - x0 - 2*style.Padding
+	if innerW < size {
+		innerW = size
+	}
+	maxWidth := int(innerW * 1000 / size)
+
+	lines := wrapText(tf, text, maxWidth)
+
+	widest := 0.0
+	for _, l := range lines {
+		widest = max(widest, float64(l.width)*size/1000)
+	}
+	blockH := float64(len(lines)) * lineH
+
+	// 文字块顶部（不含内边距）
+	var top float64
+	switch style.VAlign {
+	case "middle", "center":
+		top = (y0+y1)/2 + blockH/2
+	case "bottom":
+		top = y0 + style.Padding + blockH
+	default:
+		top = y1 - style.Padding
+	}
+	lineX := func(w float64) float64 {
+		switch style.Align {
+		case "center":
+			return (x0+x1)/2 - w/2
+		case "right":
+			return x1 - style.Padding - w
+		}
+		return x0 + style.Padding
+	}
+
+	fg, err := color.ParseColor(style.Color)
+	if err != nil {
+		return "", fmt.Errorf("parse message color %q: %w", style.Color, err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("q\n")
+	if style.Background != "" {
+		bg, err := color.ParseColor(style.Background)
+		if err != nil {
+			return "", fmt.Errorf("parse message background %q: %w", style.Background, err)
+		}
+		bx := lineX(widest) - style.Padding
+		sb.WriteString(fmt.Sprintf("%s rg\n%s %s %s %s re\nf\n", colorOperands(bg),
+			fmtFloat(bx), fmtFloat(top-blockH-style.Padding),
+			fmtFloat(widest+2*style.Padding), fmtFloat(blockH+2*style.Padding)))
+	}
+	sb.WriteString(fmt.Sprintf("%s rg\n", colorOperands(fg)))
+	if err := writeLines(&sb, tf, lines, top, size, lineH, lineX); err != nil {
+		return "", err
+	}
+	sb.WriteString("Q\n")
+	return sb.String(), nil
+}
+
+// wrapText 按 \n 分段并逐段断行。
+func wrapText(tf *textFonts, text string, maxWidth int) []textLine {
+	var lines []textLine
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, para := range strings.Split(text, "\n") {
+		lines = append(lines, breakLines(tf, []rune(para), maxWidth)...)
+	}
+	return lines
+}
+
+// writeLines 从 top 开始逐行输出文字，lineX 根据行宽（pt）返回该行起点的 x 坐标。
+func writeLines(sb *strings.Builder, tf *textFonts, lines []textLine, top, size, lineH float64, lineX func(w float64) float64) error {
+	sb.WriteString("BT\n")
+	for i, l := range lines {
+		if len(l.runes) == 0 {
+			continue
+		}
+		runs, err := tf.segment(string(l.runes))
+		if err != nil {
+			return err
+		}
+		// 基线位于行框中线下方约 0.35 字号处，使字形在行内垂直居中
+		baseline := top - float64(i)*lineH - lineH/2 - size*0.35
+		sb.WriteString(fmt.Sprintf("1 0 0 1 %s %s Tm\n", fmtFloat(lineX(float64(l.width)*size/1000)), fmtFloat(baseline)))
+		for _, run := range runs {
+			hexStr := strings.ToUpper(hex.EncodeToString(run.code))
+			sb.WriteString(fmt.Sprintf("/%s %s Tf\n<%s> Tj\n", run.font, fmtFloat(size), hexStr))
+		}
+	}
+	sb.WriteString("ET\n")
+	return nil
+}
+
+func colorOperands(c color.SimpleColor) string {
+	return fmt.Sprintf("%s %s %s", fmtFloat(float64(c.R)), fmtFloat(float64(c.G)), fmtFloat(float64(c.B)))
+}
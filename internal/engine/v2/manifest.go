@@ -0,0 +1,347 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// 打开密码的分配方式（Options.UserPasswordMode）
+const (
+	PasswordShared       = "shared"
+	PasswordPerFile      = "per-file"
+	PasswordPerRecipient = "per-recipient"
+)
+
+// 密码清单的格式与保护方式（Options.PasswordManifestFormat、Options.PasswordManifestProtection）
+const (
+	ManifestCSV  = "csv"
+	ManifestJSON = "json"
+	ManifestZIP  = "zip"
+	ManifestPDF  = "pdf"
+)
+
+const (
+	defaultPasswordLength = 12
+	// 去掉了 0/O、1/l/I 等容易看错的字符
+	defaultPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz23456789"
+	manifestBaseName        = "passwords"
+)
+
+// batchJob 是批量处理中的一个输出：per-recipient 模式下每个输入为每个接收者各有一个。
+type batchJob struct {
+	index     int
+	input     string
+	output    string
+	recipient string
+	password  string
+}
+
+// passwordManifestEntry 是密码清单的一行，供邮件合并分发。
+type passwordManifestEntry struct {
+	File      string `json:"file"`
+	Recipient string `json:"recipient,omitempty"`
+	Password  string `json:"password"`
+	Source    string `json:"source"`
+	Path      string `json:"path"`
+}
+
+// generatesPasswords 判断是否为每个输出生成打开密码。
+func (opt Options) generatesPasswords() bool {
+	return opt.UserPasswordMode == PasswordPerFile || opt.UserPasswordMode == PasswordPerRecipient
+}
+
+// validatePasswordOptions 检查打开密码的分配方式与密码清单的设置。
+func validatePasswordOptions(opt Options) error {
+	switch opt.UserPasswordMode {
+	case "", PasswordShared:
+		return nil
+	case PasswordPerFile, PasswordPerRecipient:
+	default:
+		return fmt.Errorf("不支持的密码分配方式 %q，可选 shared、per-file 或 per-recipient", opt.UserPasswordMode)
+	}
+	switch {
+	case opt.UserPasswordMode == PasswordPerRecipient && len(recipientNames(opt)) == 0:
+		return fmt.Errorf("按接收者生成密码时请至少填写一个接收者")
+	case opt.Encryption == EncryptionNone:
+		return fmt.Errorf("不加密时不能设置打开密码")
+	case len(opt.Recipients) > 0:
+		return fmt.Errorf("证书加密不使用打开密码，不能同时按文件生成密码")
+	case opt.OutputMode == OutputIncremental || opt.SignedInputPolicy == SignedInputIncremental:
+		return fmt.Errorf("增量更新不会修改打开密码，生成密码时请使用重写模式")
+	case strings.TrimSpace(opt.OutputDir) == "":
+		return fmt.Errorf("生成密码时必须指定输出目录，密码清单写在输出目录中")
+	}
+
+	length, alphabet := passwordSpec(opt)
+	if length < 6 || length > 32 {
+		return fmt.Errorf("密码长度应为 6 到 32 位")
+	}
+	if len(uniqueRunes(alphabet)) < 2 {
+		return fmt.Errorf("密码字符集至少需要两个不同的字符")
+	}
+	for _, r := range alphabet {
+		// AES-128 的密码按 PDFDocEncoding 处理，只允许可打印的 ASCII 字符
+		if r <= ' ' || r > '~' {
+			return fmt.Errorf("密码字符集只能包含可打印的 ASCII 字符（不含空格）")
+		}
+	}
+
+	switch opt.PasswordManifestFormat {
+	case "", ManifestCSV, ManifestJSON:
+	default:
+		return fmt.Errorf("不支持的密码清单格式 %q，可选 csv 或 json", opt.PasswordManifestFormat)
+	}
+	switch opt.PasswordManifestProtection {
+	case "":
+	case ManifestZIP, ManifestPDF:
+		if opt.PasswordManifestPassword == "" {
+			return fmt.Errorf("加密的密码清单需要设置清单密码")
+		}
+	default:
+		return fmt.Errorf("不支持的密码清单保护方式 %q，可选 zip 或 pdf", opt.PasswordManifestProtection)
+	}
+	return nil
+}
+
+// passwordSpec 返回生成密码的长度与字符集，未设置时使用默认值。
+func passwordSpec(opt Options) (int, string) {
+	length, alphabet := opt.PasswordLength, opt.PasswordAlphabet
+	if length == 0 {
+		length = defaultPasswordLength
+	}
+	if alphabet == "" {
+		alphabet = defaultPasswordAlphabet
+	}
+	return length, alphabet
+}
+
+func uniqueRunes(s string) []rune {
+	seen := map[rune]bool{}
+	var out []rune
+	for _, r := range s {
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// generatePassword 从字符集中均匀随机选取字符。
+func generatePassword(length int, alphabet string) (string, error) {
+	chars := uniqueRunes(alphabet)
+	n := big.NewInt(int64(len(chars)))
+	var b strings.Builder
+	for i := 0; i < length; i++ {
+		k, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(chars[k.Int64()])
+	}
+	return b.String(), nil
+}
+
+// recipientNames 返回去掉空白与重复项后的接收者。
+func recipientNames(opt Options) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, n := range opt.PasswordRecipients {
+		n = strings.TrimSpace(n)
+		if n != "" && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// batchJobs 为每个输入安排输出文件名与打开密码。
+// per-file 模式每个输出一个密码；per-recipient 模式每个输入为每个接收者各输出一份，同一接收者的文件共用一个密码。
+func batchJobs(opt Options, files []string) ([]batchJob, error) {
+	length, alphabet := passwordSpec(opt)
+	var jobs []batchJob
+	add := func(input, output, recipient, password string) {
+		jobs = append(jobs, batchJob{index: len(jobs), input: input, output: output, recipient: recipient, password: password})
+	}
+
+	if opt.UserPasswordMode != PasswordPerRecipient {
+		for _, p := range files {
+			var pw string
+			if opt.UserPasswordMode == PasswordPerFile {
+				var err error
+				if pw, err = generatePassword(length, alphabet); err != nil {
+					return nil, err
+				}
+			}
+			add(p, filepath.Join(opt.OutputDir, filepath.Base(p)), "", pw)
+		}
+		return jobs, nil
+	}
+
+	for _, name := range recipientNames(opt) {
+		pw, err := generatePassword(length, alphabet)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range files {
+			base := filepath.Base(p)
+			ext := filepath.Ext(base)
+			out := fmt.Sprintf("%s_%s%s", strings.TrimSuffix(base, ext), safeFileName(name), ext)
+			add(p, filepath.Join(opt.OutputDir, out), name, pw)
+		}
+	}
+	return jobs, nil
+}
+
+// safeFileName 把 Windows 文件名中不允许的字符替换为下划线。
+func safeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// writePasswordManifest 在输出目录中写出密码清单，返回实际使用的文件名。
+func writePasswordManifest(opt Options, entries []passwordManifestEntry) (string, error) {
+	format := opt.PasswordManifestFormat
+	if format == "" {
+		format = ManifestCSV
+	}
+	content, err := renderManifest(format, entries)
+	if err != nil {
+		return "", err
+	}
+	name := manifestBaseName + "." + format
+
+	switch opt.PasswordManifestProtection {
+	case ManifestZIP:
+		out := uniqueOutputName(filepath.Join(opt.OutputDir, manifestBaseName+".zip"))
+		var buf bytes.Buffer
+		if err := writeAESZip(&buf, name, content, opt.PasswordManifestPassword); err != nil {
+			return "", fmt.Errorf("write manifest zip: %w", err)
+		}
+		return out, os.WriteFile(out, buf.Bytes(), 0o600)
+	case ManifestPDF:
+		out := uniqueOutputName(filepath.Join(opt.OutputDir, manifestBaseName+".pdf"))
+		if err := writeManifestPDF(out, name, content, opt.PasswordManifestPassword); err != nil {
+			return "", fmt.Errorf("write manifest pdf: %w", err)
+		}
+		return out, nil
+	}
+	out := uniqueOutputName(filepath.Join(opt.OutputDir, name))
+	return out, os.WriteFile(out, content, 0o600)
+}
+
+// renderManifest 生成清单内容。CSV 带 UTF-8 BOM，Excel 与 Word 邮件合并可直接识别中文。
+func renderManifest(format string, entries []passwordManifestEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if format == ManifestJSON {
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(entries)
+		return buf.Bytes(), err
+	}
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+	w.Write([]string{"file", "recipient", "password", "source", "path"})
+	for _, e := range entries {
+		w.Write([]string{e.File, e.Recipient, e.Password, e.Source, e.Path})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeManifestPDF 把清单作为附件放入一个以 password 加密（AES-256）的单页 PDF。
+func writeManifestPDF(path, name string, content []byte, password string) error {
+	ctx, err := pdfcpu.CreateContextWithXRefTable(model.NewDefaultConfiguration(), types.PaperSize["A4"])
+	if err != nil {
+		return err
+	}
+	pagesRef, ok := ctx.RootDict["Pages"].(types.IndirectRef)
+	if !ok {
+		return fmt.Errorf("missing page tree")
+	}
+	pages, err := ctx.DereferenceDict(pagesRef)
+	if err != nil {
+		return err
+	}
+
+	font, err := ctx.IndRefForNewObject(types.Dict{
+		"Type":     types.Name("Font"),
+		"Subtype":  types.Name("Type1"),
+		"BaseFont": types.Name("Helvetica"),
+		"Encoding": types.Name("WinAnsiEncoding"),
+	})
+	if err != nil {
+		return err
+	}
+	text := fmt.Sprintf("BT /F1 16 Tf 72 770 Td (Password manifest) Tj /F1 11 Tf 0 -28 Td (Open the attached file %s to see the passwords.) Tj ET", name)
+	sd, err := ctx.NewStreamDictForBuf([]byte(text))
+	if err != nil {
+		return err
+	}
+	if err := sd.Encode(); err != nil {
+		return err
+	}
+	contents, err := ctx.IndRefForNewObject(*sd)
+	if err != nil {
+		return err
+	}
+	page, err := ctx.IndRefForNewObject(types.Dict{
+		"Type":      types.Name("Page"),
+		"Parent":    pagesRef,
+		"MediaBox":  types.RectForFormat("A4").Array(),
+		"Resources": types.Dict{"Font": types.Dict{"F1": *font}},
+		"Contents":  *contents,
+	})
+	if err != nil {
+		return err
+	}
+	pages["Kids"] = types.Array{*page}
+	pages["Count"] = types.Integer(1)
+	ctx.PageCount = 1
+
+	// 以文件包（Portfolio）方式打开，阅读器直接显示附件列表
+	if err := ctx.AddAttachment(model.Attachment{Reader: bytes.NewReader(content), ID: name, FileName: name, Desc: "Password manifest"}, true); err != nil {
+		return fmt.Errorf("attach manifest: %w", err)
+	}
+
+	// 新建的文档没有读取上下文，不能直接加密写出，先写到内存再加密
+	var buf bytes.Buffer
+	if err := api.WriteContext(ctx, &buf); err != nil {
+		return err
+	}
+	ownerPW, err := newOwnerPassword()
+	if err != nil {
+		return err
+	}
+	conf := model.NewAESConfiguration(password, ownerPW, 256)
+	conf.Permissions = model.PermissionsAll
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := api.Encrypt(bytes.NewReader(buf.Bytes()), f, conf); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
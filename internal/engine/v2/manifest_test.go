@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// parseManifest 解析 CSV 或 JSON 格式的密码清单。
+func parseManifest(t *testing.T, format string, content []byte) []passwordManifestEntry {
+	t.Helper()
+	var entries []passwordManifestEntry
+	if format == ManifestJSON {
+		if err := json.Unmarshal(content, &entries); err != nil {
+			t.Fatalf("parse manifest: %v", err)
+		}
+		return entries
+	}
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\ufeff")))).ReadAll()
+	if err != nil {
+		t.Fatalf("parse manifest: %v", err)
+	}
+	if len(rows) == 0 || strings.Join(rows[0], ",") != "file,recipient,password,source,path" {
+		t.Fatalf("manifest header %v", rows)
+	}
+	for _, r := range rows[1:] {
+		entries = append(entries, passwordManifestEntry{File: r[0], Recipient: r[1], Password: r[2], Source: r[3], Path: r[4]})
+	}
+	return entries
+}
+
+// opensWith 判断 path 能否以 password 作为打开密码读取。
+func opensWith(path, password string) bool {
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW, conf.OwnerPW = password, ""
+	return api.ValidateFile(path, conf) == nil
+}
+
+// checkManifestOutputs 检查清单中的每个输出只能用清单里的密码打开。
+func checkManifestOutputs(t *testing.T, entries []passwordManifestEntry, want int) {
+	t.Helper()
+	if len(entries) != want {
+		t.Fatalf("manifest has %d entries, want %d", len(entries), want)
+	}
+	for _, e := range entries {
+		if e.Password == "" || len(e.Password) != defaultPasswordLength {
+			t.Fatalf("%s: password %q", e.File, e.Password)
+		}
+		if filepath.Base(e.Path) != e.File {
+			t.Fatalf("manifest path %s does not match file %s", e.Path, e.File)
+		}
+		ctx := readTestPDF(t, e.Path, e.Password)
+		if ctx.PageCount != 1 {
+			t.Fatalf("%s: page count = %d", e.File, ctx.PageCount)
+		}
+		if opensWith(e.Path, "") || opensWith(e.Path, e.Password+"x") {
+			t.Fatalf("%s opens without its password", e.File)
+		}
+	}
+}
+
+// writeBatchInputs 在 dir 中写出 n 个单页输入，返回以分号分隔的文件列表。
+func writeBatchInputs(t *testing.T, dir string, n int) string {
+	t.Helper()
+	var files []string
+	for i := 0; i < n; i++ {
+		p := filepath.Join(dir, string(rune('a'+i))+".pdf")
+		writeTestPDF(t, p, 1)
+		files = append(files, p)
+	}
+	return strings.Join(files, ";")
+}
+
+func TestPerFilePasswordsOpenOutputs(t *testing.T) {
+	for _, format := range []string{ManifestCSV, ManifestJSON} {
+		t.Run(format, func(t *testing.T) {
+			testConfigDir(t)
+			dir := t.TempDir()
+			opt := testOptions("", "")
+			opt.Files = writeBatchInputs(t, dir, 3)
+			opt.OutputDir = filepath.Join(dir, "out")
+			opt.UserPasswordMode = PasswordPerFile
+			opt.PasswordManifestFormat = format
+			if err := os.Mkdir(opt.OutputDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := RunBatch(opt); err != nil || n != 3 {
+				t.Fatalf("RunBatch = %d, %v", n, err)
+			}
+			content, err := os.ReadFile(filepath.Join(opt.OutputDir, "passwords."+format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries := parseManifest(t, format, content)
+			checkManifestOutputs(t, entries, 3)
+			seen := map[string]bool{}
+			for _, e := range entries {
+				if seen[e.Password] {
+					t.Fatal("per-file passwords repeat")
+				}
+				seen[e.Password] = true
+			}
+		})
+	}
+}
+
+func TestPerRecipientPasswordsWithProtectedManifest(t *testing.T) {
+	for _, protection := range []string{ManifestZIP, ManifestPDF} {
+		t.Run(protection, func(t *testing.T) {
+			testConfigDir(t)
+			dir := t.TempDir()
+			opt := testOptions("", "")
+			opt.Files = writeBatchInputs(t, dir, 2)
+			opt.OutputDir = filepath.Join(dir, "out")
+			opt.UserPasswordMode = PasswordPerRecipient
+			opt.PasswordRecipients = []string{"张三", "李四"}
+			opt.PasswordManifestProtection = protection
+			// pdfcpu 读取 AES-256 文档时按 PRECIS 标识符处理密码，不接受空格
+			opt.PasswordManifestPassword = "manifest-secret"
+			if err := os.Mkdir(opt.OutputDir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if n, err := RunBatch(opt); err != nil || n != 4 {
+				t.Fatalf("RunBatch = %d, %v", n, err)
+			}
+
+			var content []byte
+			path := filepath.Join(opt.OutputDir, "passwords."+protection)
+			if protection == ManifestZIP {
+				zr, err := zip.OpenReader(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer zr.Close()
+				if content, err = decryptAESZipEntry(t, zr.File[0], opt.PasswordManifestPassword); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				if opensWith(path, "") {
+					t.Fatal("manifest PDF opens without a password")
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				conf := model.NewDefaultConfiguration()
+				conf.UserPW, conf.OwnerPW = opt.PasswordManifestPassword, opt.PasswordManifestPassword
+				atts, err := api.ExtractAttachmentsRaw(f, "", nil, conf)
+				if err != nil || len(atts) != 1 {
+					t.Fatalf("extract manifest: %d attachments, %v", len(atts), err)
+				}
+				if content, err = io.ReadAll(atts[0]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries := parseManifest(t, ManifestCSV, content)
+			checkManifestOutputs(t, entries, 4)
+			// 同一接收者的文件共用一个密码，不同接收者的密码不同
+			byRecipient := map[string]string{}
+			for _, e := range entries {
+				if pw, ok := byRecipient[e.Recipient]; ok && pw != e.Password {
+					t.Fatalf("%s has two passwords", e.Recipient)
+				}
+				byRecipient[e.Recipient] = e.Password
+				if !strings.Contains(e.File, e.Recipient) {
+					t.Fatalf("output %s is not named after %s", e.File, e.Recipient)
+				}
+			}
+			if len(byRecipient) != 2 || byRecipient["张三"] == byRecipient["李四"] {
+				t.Fatalf("recipient passwords %v", byRecipient)
+			}
+		})
+	}
+}
+
+func TestRunRejectsGeneratedPasswords(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.OutputDir = dir
+	for _, mode := range []string{PasswordPerFile, PasswordPerRecipient} {
+		opt.UserPasswordMode = mode
+		opt.PasswordRecipients = []string{"张三"}
+		if err := Run(opt); err == nil || !strings.Contains(err.Error(), "批量") {
+			t.Fatalf("%s: Run = %v", mode, err)
+		}
+	}
+	opt.UserPasswordMode = "random"
+	if err := Run(opt); err == nil {
+		t.Fatal("Run accepted an unknown password mode")
+	}
+	if _, err := os.Stat(opt.Output); !os.IsNotExist(err) {
+		t.Fatal("Run wrote output for rejected options")
+	}
+}
@@ -7,18 +7,15 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
-// BuildMaskXObject 创建一个用于遮罩/覆盖的 Form XObject。
-func buildMaskXObject(ctx *model.Context, mediaBox types.Array) (*types.IndirectRef, error) {
-	w := mediaBox[2]
-	h := mediaBox[3]
-
+// BuildMaskXObject 创建一个用于遮罩/覆盖的 Form XObject，覆盖整个 mediaBox（含非零原点）。
+func buildMaskXObject(ctx *model.Context, mediaBox *types.Rectangle) (*types.IndirectRef, error) {
 	content := fmt.Sprintf(`
 q
 1 1 1 rg
-0 0 %v %v re
+%s %s %s %s re
 f
 Q
-`, w, h)
+`, fmtFloat(mediaBox.LL.X), fmtFloat(mediaBox.LL.Y), fmtFloat(mediaBox.Width()), fmtFloat(mediaBox.Height()))
 
 	sd, err := ctx.NewStreamDictForBuf([]byte(content))
 	if err != nil {
@@ -29,48 +26,7 @@ Q
 	}
 	sd.Dict["Type"] = types.Name("XObject")
 	sd.Dict["Subtype"] = types.Name("Form")
-	sd.Dict["BBox"] = mediaBox
+	sd.Dict["BBox"] = mediaBox.Array()
 
 	return ctx.IndRefForNewObject(*sd)
 }
-
-// 批量创建Mask XObjects
-func buildMaskXObjects(ctx *model.Context, mediaBox types.Array, count int) ([]*types.IndirectRef, error) {
-	var refs []*types.IndirectRef
-	for i := 0; i < count; i++ {
-		ref, err := buildMaskXObject(ctx, mediaBox)
-		if err != nil {
-			return nil, err
-		}
-		refs = append(refs, ref)
-	}
-	return refs, nil
-}
-
-// BuildMaskOCG 创建指定数量的遮罩 OCG。
-func buildMaskOCGs(ctx *model.Context, pageNr, count int) ([]*types.IndirectRef, error) {
-	var refs []*types.IndirectRef
-	for i := 0; i < count; i++ {
-		name := fmt.Sprintf("mask_%02d_%02d", pageNr, i+1)
-		ref, err := createOCG(ctx, name)
-		if err != nil {
-			return nil, err
-		}
-		refs = append(refs, ref)
-	}
-	return refs, nil
-}
-
-// BuildMaskOCGsAndXObjects 创建指定数量的遮罩 OCG 和对应的遮罩 XObject。
-func buildMaskOCGsAndXObjectsForPage(ctx *model.Context, page types.Dict, pageNr int, count int) ([]*types.IndirectRef, []*types.IndirectRef, error) {
-	mediaBox := getPageMediaBox(page)
-	ocgs, err := buildMaskOCGs(ctx, pageNr, count)
-	if err != nil {
-		return nil, nil, fmt.Errorf("build mask ocgs: %w", err)
-	}
-	objs, err := buildMaskXObjects(ctx, mediaBox, count)
-	if err != nil {
-		return nil, nil, fmt.Errorf("build mask xobjects: %w", err)
-	}
-	return ocgs, objs, nil
-}
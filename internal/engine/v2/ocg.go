@@ -5,11 +5,23 @@ import (
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
+// ocgCreator 写入保护 OCG 的 /Usage /CreatorInfo，标记为本工具创建。
+const ocgCreator = "win-pdf"
+
 // CreateOCG 创建一个 Optional Content Group (OCG) 对象并返回其间接引用。
+// 保护 OCG 的 Intent 同时包含 View 与 Design：无论原文档的配置使用哪种意图，遮罩都参与可见性计算；
+// Usage 只带 CreatorInfo，不设置 View/Print 状态，避免阅读器按用途（AS）自动切换。
 func createOCG(ctx *model.Context, name string) (*types.IndirectRef, error) {
 	d := types.Dict{
-		"Type": types.Name("OCG"),
-		"Name": types.StringLiteral(name),
+		"Type":   types.Name("OCG"),
+		"Name":   types.StringLiteral(name),
+		"Intent": types.Array{types.Name("View"), types.Name("Design")},
+		"Usage": types.Dict{
+			"CreatorInfo": types.Dict{
+				"Creator": types.StringLiteral(ocgCreator),
+				"Subtype": types.Name("Artwork"),
+			},
+		},
 	}
 	ref, err := ctx.IndRefForNewObject(d)
 	if err != nil {
@@ -18,44 +30,76 @@ func createOCG(ctx *model.Context, name string) (*types.IndirectRef, error) {
 	return ref, nil
 }
 
-// ApplyOCProperties 在文档根字典上安装 /OCProperties，使阅读器识别并显示图层。
+// ApplyOCProperties 在文档根字典上安装 /OCProperties，所有页面处理完后调用一次。
+// 原有的 OCProperties 整体保留（D、Configs、RBGroups、AS 等），引擎的 OCG 只追加到 OCGs，
+// 并合并进默认配置和每个备用配置（见 mergeOCConfig）。
 func applyOCProperties(ctx *model.Context, ocgs []*types.IndirectRef) {
-	arr := types.Array{}
+	var existing types.Array
+	props, d := types.Dict{}, types.Dict{}
+	if ocProps, err := ctx.DereferenceDict(ctx.RootDict["OCProperties"]); err == nil && ocProps != nil {
+		props = ocProps.Clone().(types.Dict)
+		if arr, err := ctx.DereferenceArray(ocProps["OCGs"]); err == nil {
+			existing = arr
+		}
+		if dd, err := ctx.DereferenceDict(ocProps["D"]); err == nil && dd != nil {
+			d = dd
+		}
+	}
+
+	ours := types.Array{}
 	for _, r := range ocgs {
-		arr = append(arr, *r)
-	}
-	ctx.RootDict["OCProperties"] = types.Dict{
-		"OCGs": arr,
-		"D": types.Dict{
-			"ON":       arr,
-			"Order":    arr,
-			"ListMode": types.Name("AllOn"),
-		},
+		ours = append(ours, *r)
 	}
+
+	props["OCGs"] = append(append(types.Array{}, existing...), ours...)
+	props["D"] = mergeOCConfig(ctx, d, existing, ours)
+	if configs, err := ctx.DereferenceArray(props["Configs"]); err == nil && len(configs) > 0 {
+		merged := types.Array{}
+		for _, c := range configs {
+			cd, err := ctx.DereferenceDict(c)
+			if err != nil || cd == nil {
+				merged = append(merged, c)
+				continue
+			}
+			merged = append(merged, mergeOCConfig(ctx, cd, existing, ours))
+		}
+		props["Configs"] = merged
+	}
+	ctx.RootDict["OCProperties"] = props
 }
 
-func insertOCPropertiesOCGs(ctx *model.Context, ocgs []*types.IndirectRef) {
-	arr := types.Array{}
-
-	// 判断是否已经存在 OCProperties
-	if _, found := ctx.RootDict["OCProperties"]; found {
-		// 如果存在，更新 OCGs、ON 和 Order 数组
-		ocProps := ctx.RootDict["OCProperties"].(types.Dict)
-		// 判断是否存在 OCGs 键
-		_, ocgsFound := ocProps["OCGs"]
-		if ocgsFound {
-			arr = ocProps["OCGs"].(types.Array)
+// mergeOCConfig 返回合并了引擎 OCG 的配置字典副本：引擎 OCG 加入 ON 与 Locked、移出 OFF，
+// 但不进入 Order，图层面板中既看不到也无法手动切换；原有 Order、RBGroups、AS、Intent 不变，
+// 原配置没有 Order 时把原有 OCG 全部列出，使它们仍出现在图层面板中。
+func mergeOCConfig(ctx *model.Context, cfg types.Dict, existing, ours types.Array) types.Dict {
+	d := cfg.Clone().(types.Dict)
+	isOurs := map[types.IndirectRef]bool{}
+	for _, o := range ours {
+		isOurs[o.(types.IndirectRef)] = true
+	}
+
+	appendArray := func(key string, refs types.Array) {
+		arr, _ := ctx.DereferenceArray(d[key])
+		d[key] = append(append(types.Array{}, arr...), refs...)
+	}
+	appendArray("ON", ours)
+	appendArray("Locked", ours)
+	if off, err := ctx.DereferenceArray(d["OFF"]); err == nil && off != nil {
+		kept := types.Array{}
+		for _, o := range off {
+			if ref, ok := o.(types.IndirectRef); ok && isOurs[ref] {
+				continue
+			}
+			kept = append(kept, o)
 		}
+		d["OFF"] = kept
 	}
-	for _, ref := range ocgs {
-		arr = append(arr, *ref)
+	if _, ok := d["Order"]; !ok {
+		d["Order"] = append(types.Array{}, existing...)
 	}
-	ctx.RootDict["OCProperties"] = types.Dict{
-		"OCGs": arr,
-		"D": types.Dict{
-			"ON":       arr,
-			"Order":    arr,
-			"ListMode": types.Name("AllOn"),
-		},
+	// ListMode 只允许 AllPages、VisiblePages
+	if lm, ok := d["ListMode"].(types.Name); ok && lm != "AllPages" && lm != "VisiblePages" {
+		delete(d, "ListMode")
 	}
+	return d
 }
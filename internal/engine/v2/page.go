@@ -1,16 +1,88 @@
 package engine
 
 import (
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
 )
 
-func getPageMediaBox(pageDict types.Dict) types.Array {
+// pageGeometry 是页面的有效几何信息，MediaBox、CropBox、Rotate 取自继承后的页面属性。
+type pageGeometry struct {
+	mediaBox *types.Rectangle
+	cropBox  *types.Rectangle // 可见区域：CropBox 与 MediaBox 的交集
+	rotate   int              // 顺时针旋转角度，规范为 0/90/180/270
+	userUnit float64          // 用户空间单位（1/72 英寸的倍数），默认 1
+}
 
-	if mb, ok := pageDict["MediaBox"].(types.Array); ok && len(mb) >= 4 {
-		return mb
+// newPageGeometry 根据 ctx.PageDict 返回的继承属性计算页面几何；缺少 MediaBox 时按 Letter 处理。
+func newPageGeometry(ctx *model.Context, pageDict types.Dict, inh *model.InheritedPageAttrs) *pageGeometry {
+	g := &pageGeometry{userUnit: 1}
+	if inh != nil {
+		g.mediaBox = inh.MediaBox
+		g.rotate = ((inh.Rotate % 360) + 360) % 360 / 90 * 90
+		if inh.CropBox != nil && g.mediaBox != nil {
+			g.cropBox = intersectRect(inh.CropBox, g.mediaBox)
+		}
+	}
+	if g.mediaBox == nil {
+		g.mediaBox = types.NewRectangle(0, 0, 612, 792) // 8.5 x 11 inches
+	}
+	if g.cropBox == nil {
+		g.cropBox = g.mediaBox
 	}
+	if o, found := pageDict.Find("UserUnit"); found {
+		if u, err := ctx.DereferenceNumber(o); err == nil && u > 0 {
+			g.userUnit = u
+		}
+	}
+	return g
+}
 
-	defaultMediaBox := types.Array{types.Float(0), types.Float(0), types.Float(612), types.Float(792)} // 8.5 x 11 inches
+// intersectRect 返回 a 与 b 的交集，不相交时返回 nil。
+func intersectRect(a, b *types.Rectangle) *types.Rectangle {
+	llx, lly := math.Max(a.LL.X, b.LL.X), math.Max(a.LL.Y, b.LL.Y)
+	urx, ury := math.Min(a.UR.X, b.UR.X), math.Min(a.UR.Y, b.UR.Y)
+	if urx <= llx || ury <= lly {
+		return nil
+	}
+	return types.NewRectangle(llx, lly, urx, ury)
+}
 
-	return defaultMediaBox
+// formSize 返回可见区域在阅读器中显示的宽高（pt），已考虑旋转与 UserUnit。
+func (g *pageGeometry) formSize() (w, h float64) {
+	w, h = g.cropBox.Width()*g.userUnit, g.cropBox.Height()*g.userUnit
+	if g.rotate == 90 || g.rotate == 270 {
+		w, h = h, w
+	}
+	return w, h
+}
+
+// formBBox 返回提示类 XObject 的 BBox：以显示方向为准的 0 0 w h。
+func (g *pageGeometry) formBBox() types.Array {
+	w, h := g.formSize()
+	return types.NewRectangle(0, 0, w, h).Array()
+}
+
+// formMatrix 返回提示类 XObject 的 Matrix，把 formBBox 映射到用户空间的可见区域，
+// 并抵消页面旋转与 UserUnit，使文字在阅读器中正向、按 pt 显示。
+func (g *pageGeometry) formMatrix() types.Array {
+	s := 1 / g.userUnit
+	c := g.cropBox
+	var m [6]float64
+	switch g.rotate {
+	case 90:
+		m = [6]float64{0, s, -s, 0, c.UR.X, c.LL.Y}
+	case 180:
+		m = [6]float64{-s, 0, 0, -s, c.UR.X, c.UR.Y}
+	case 270:
+		m = [6]float64{0, -s, s, 0, c.LL.X, c.UR.Y}
+	default:
+		m = [6]float64{s, 0, 0, s, c.LL.X, c.LL.Y}
+	}
+	a := make(types.Array, len(m))
+	for i, v := range m {
+		a[i] = types.Float(v)
+	}
+	return a
 }
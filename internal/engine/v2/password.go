@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// 加密输入无法打开的原因，Run 直接返回给界面，预检据此给出建议
+var (
+	errNeedsPassword = errors.New("此文档设置了打开密码，请提供打开密码后再处理")
+	errWrongPassword = errors.New("提供的打开密码不正确，无法打开此文档")
+	errOwnEncrypted  = errors.New("此文档已由本工具加密过，不能再次加密！请使用原始文档重新处理")
+	errCertEncrypted = errors.New("此文档使用证书加密，需要接收者的私钥才能打开，请使用加密前的原始文档")
+)
+
+// inputPasswords 返回打开 input 时依次尝试的密码：InputPasswords 与密码列表文件中为该文件指定的密码优先，
+// 其后是 InputPassword 与列表中的通用密码。
+func inputPasswords(opt Options, input string) ([]string, error) {
+	var specific, common []string
+	if pw, ok := lookupPassword(opt.InputPasswords, input); ok {
+		specific = append(specific, pw)
+	}
+	if opt.InputPassword != "" {
+		common = append(common, opt.InputPassword)
+	}
+	if opt.InputPasswordFile != "" {
+		files, list, err := readPasswordFile(opt.InputPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		if pw, ok := lookupPassword(files, input); ok {
+			specific = append(specific, pw)
+		}
+		common = append(common, list...)
+	}
+	return append(specific, common...), nil
+}
+
+// lookupPassword 先按完整路径、再按文件名查找。
+func lookupPassword(m map[string]string, input string) (string, bool) {
+	if pw, ok := m[input]; ok {
+		return pw, true
+	}
+	pw, ok := m[filepath.Base(input)]
+	return pw, ok
+}
+
+// readPasswordFile 读取密码列表文件（UTF-8）。每行为“文件名<Tab>密码”或单独的密码，
+// 前者只用于该文件（文件名可为完整路径），后者对所有文件依次尝试；空行与 # 开头的行忽略。
+func readPasswordFile(path string) (map[string]string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read password file: %w", err)
+	}
+	defer f.Close()
+
+	files := map[string]string{}
+	var list []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		line = strings.TrimPrefix(line, "\ufeff")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, pw, ok := strings.Cut(line, "\t"); ok {
+			files[strings.TrimSpace(name)] = pw
+			continue
+		}
+		list = append(list, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read password file: %w", err)
+	}
+	return files, list, nil
+}
+
+// openPDF 读取文档。先不带密码读取（未加密或只设置了权限密码的文档可直接打开），
+// 需要打开密码时依次尝试 inputPasswords；打开后 ctx.UserPW 为实际使用的密码。
+func openPDF(rs io.ReadSeeker, opt Options, input string) (*model.Context, error) {
+	ctx, err := readWithPassword(rs, "")
+	if err != nil && strings.Contains(err.Error(), `filter must be "Standard"`) {
+		return nil, errCertEncrypted
+	}
+	if err == nil || !isWrongPassword(err) {
+		return ctx, err
+	}
+	candidates, err := inputPasswords(opt, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, pw := range candidates {
+		ctx, err := readWithPassword(rs, pw)
+		if err == nil || !isWrongPassword(err) {
+			return ctx, err
+		}
+	}
+
+	// 本工具的输出总带有所有者密码（记录在密码库中，早期版本为固定规则），能用它打开且含本工具的图层即为重复加密
+	owners := []string{ownerPassword(opt), ownerPWMask}
+	if pw, ok := vaultOwnerPassword(input); ok {
+		owners = append([]string{pw}, owners...)
+	}
+	for _, pw := range owners {
+		if ctx, err := readWithPassword(rs, pw); err == nil && hasEngineOCG(ctx) {
+			return nil, errOwnEncrypted
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errNeedsPassword
+	}
+	return nil, errWrongPassword
+}
+
+// readWithPassword 用 pw 同时作为用户密码与所有者密码读取，pdfcpu 会分别校验。
+func readWithPassword(rs io.ReadSeeker, pw string) (*model.Context, error) {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW, conf.OwnerPW = pw, pw
+	return api.ReadContext(rs, conf)
+}
+
+func isWrongPassword(err error) bool {
+	return errors.Is(err, pdfcpu.ErrWrongPassword) || strings.Contains(err.Error(), pdfcpu.ErrWrongPassword.Error())
+}
+
+// isPasswordError 判断是否为加密输入无法打开的错误。
+func isPasswordError(err error) bool {
+	return errors.Is(err, errNeedsPassword) || errors.Is(err, errWrongPassword) || errors.Is(err, errOwnEncrypted) ||
+		errors.Is(err, errCertEncrypted)
+}
+
+// hasEngineOCG 判断文档是否含本工具创建的图层。
+func hasEngineOCG(ctx *model.Context) bool {
+	oc, err := ctx.DereferenceDict(ctx.RootDict["OCProperties"])
+	if err != nil || oc == nil {
+		return false
+	}
+	ocgs, _ := ctx.DereferenceArray(oc["OCGs"])
+	for _, o := range ocgs {
+		if isEngineOCG(ctx, o) {
+			return true
+		}
+	}
+	return false
+}
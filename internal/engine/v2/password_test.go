@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// writePasswordFile 写出密码列表文件，返回其路径。
+func writePasswordFile(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "passwords.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInputPasswordsOrder(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.pdf")
+	opt := Options{
+		InputPassword:  "common",
+		InputPasswords: map[string]string{"a.pdf": "by-name"},
+		InputPasswordFile: writePasswordFile(t, dir, "\ufeff# 注释\r\n\r\n"+
+			input+"\tfile-by-path\r\n"+
+			"b.pdf\tother-file\r\n"+
+			"list 1\r\n"+
+			"list 2\n"),
+	}
+	got, err := inputPasswords(opt, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 为该文件指定的密码在前，其后是通用密码与列表中的密码；其他文件的密码不尝试
+	want := []string{"by-name", "file-by-path", "common", "list 1", "list 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("inputPasswords = %q, want %q", got, want)
+	}
+
+	opt.InputPasswordFile = filepath.Join(dir, "missing.txt")
+	if _, err := inputPasswords(opt, input); err == nil {
+		t.Fatal("missing password file accepted")
+	}
+}
+
+func TestRunEncryptedInput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.pdf")
+	writeTestPDF(t, plain, 2)
+	input := filepath.Join(dir, "in.pdf")
+	if err := api.EncryptFile(plain, input, model.NewAESConfiguration("u1", "o1", 256)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(opt *Options)
+		wantErr error
+	}{
+		{"no password", func(opt *Options) {}, errNeedsPassword},
+		{"wrong password", func(opt *Options) { opt.InputPassword = "bad" }, errWrongPassword},
+		{"password", func(opt *Options) { opt.InputPassword = "u1" }, nil},
+		{"later candidate", func(opt *Options) {
+			opt.InputPasswords = map[string]string{"in.pdf": "bad"}
+			opt.InputPassword = "u1"
+		}, nil},
+		{"password file", func(opt *Options) {
+			opt.InputPassword = "bad"
+			opt.InputPasswordFile = writePasswordFile(t, t.TempDir(), "other\nu1\n")
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := testOptions(input, filepath.Join(t.TempDir(), "out.pdf"))
+			tt.setup(&opt)
+			err := Run(opt)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			// 输出按新的设置加密，不再需要输入的打开密码
+			ctx := readTestPDF(t, opt.Output, "")
+			if ctx.PageCount != 2 || !hasEngineOCG(ctx) {
+				t.Fatalf("output pages = %d, protected = %v", ctx.PageCount, hasEngineOCG(ctx))
+			}
+		})
+	}
+}
+
+func TestRunRejectsEncryptedOutputs(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+
+	// 本工具以打开密码加密的输出
+	own := testOptions(input, filepath.Join(dir, "own.pdf"))
+	own.PwdEnabled, own.UserPassword = true, "u1"
+	if err := Run(own); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// 证书加密的输出，pdfcpu 只支持标准安全处理程序
+	cert := testOptions(input, filepath.Join(dir, "cert.pdf"))
+	cert.Recipients = []Recipient{{CertPath: newTestRecipient(t, dir, "alice", 1).path}}
+	if err := Run(cert); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, tt := range []struct {
+		input   string
+		wantErr error
+	}{
+		{own.Output, errOwnEncrypted},
+		{cert.Output, errCertEncrypted},
+	} {
+		opt := testOptions(tt.input, filepath.Join(dir, "again.pdf"))
+		if err := Run(opt); !errors.Is(err, tt.wantErr) {
+			t.Fatalf("%s: Run = %v, want %v", filepath.Base(tt.input), err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// PreflightReport 是单个输入文档的预检结果，批量处理前展示给用户。
+type PreflightReport struct {
+	File    string
+	Size    int64
+	Pages   int
+	Version string
+	// CanProcess 为 false 时该文档无法处理（无法读取或需要打开密码）
+	CanProcess bool
+
+	Encrypted         bool
+	NeedsPassword     bool   // 设置了打开密码
+	WrongPassword     bool   // 提供的打开密码均不正确
+	EncryptionHandler string // 如 "Standard V4 R4 AES-128"、"Adobe.PubSec V5 AES-256"
+	Signed            bool
+	XFA               bool
+	PDFA              string // XMP 中声明的 PDF/A 标准，如 "PDF/A-2b"
+	Tagged            bool
+	EmptyPages        []int // 没有内容流的页码
+	MalformedXRef     bool  // 交叉引用表损坏，读取时需要重建
+	HasOCGs           bool
+	HasJavaScript     bool
+	Protected         bool // 已经过本工具处理
+
+	EstimatedMemory     int64 // 处理时的峰值内存估算（字节）
+	EstimatedOutputSize int64 // 输出文件大小估算（字节）
+
+	Warnings []string
+	Actions  []string
+}
+
+// 估算用的经验值
+const (
+	preflightMemFactor     = 4         // 读取、解码与写出时文件内容的倍数
+	preflightPageMem       = 16 << 10  // 每页对象与缓存
+	preflightPageOverhead  = 1 << 10   // 每页新增的资源字典、包装 XObject 等
+	preflightDocOverhead   = 24 << 10  // OCG、遮罩、提示文字、脚本
+	preflightEmbedFontSize = 200 << 10 // 嵌入字体子集
+	preflightMemWarn       = 1 << 30
+)
+
+var (
+	pdfaPartRe        = regexp.MustCompile(`pdfaid:part(?:>|\s*=\s*["'])\s*(\d)`)
+	pdfaConformanceRe = regexp.MustCompile(`pdfaid:conformance(?:>|\s*=\s*["'])\s*([A-Za-z])`)
+	trailerEncryptRe  = regexp.MustCompile(`/Encrypt\s*(\d+)\s+(\d+)\s+R`)
+)
+
+// PreflightBatch 对 opt.Files 中的每个文件做预检，文件列表格式与 RunBatch 相同。
+func PreflightBatch(opt Options) ([]PreflightReport, error) {
+	files := splitFiles(opt.Files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no valid files provided for preflight")
+	}
+	reports := make([]PreflightReport, 0, len(files))
+	for _, f := range files {
+		reports = append(reports, Preflight(f, opt))
+	}
+	return reports, nil
+}
+
+// Preflight 读取并分析单个文档，不做任何修改；opt 用于估算输出大小。
+func Preflight(path string, opt Options) PreflightReport {
+	r := PreflightReport{File: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		r.warn("无法访问文件："+err.Error(), "请确认文件存在且有读取权限")
+		return r
+	}
+	r.Size = info.Size()
+
+	f, err := os.Open(path)
+	if err != nil {
+		r.warn("无法打开文件："+err.Error(), "请确认文件未被其他程序占用")
+		return r
+	}
+	defer f.Close()
+
+	r.MalformedXRef = !checkStartXRef(f, r.Size)
+	ctx, err := openPDF(f, opt, path)
+	if err != nil {
+		if isPasswordError(err) {
+			r.Encrypted, r.NeedsPassword = true, true
+			r.WrongPassword = errors.Is(err, errWrongPassword)
+			r.Protected = errors.Is(err, errOwnEncrypted)
+			r.EncryptionHandler = rawEncryptionHandler(f)
+		}
+		r.report(opt)
+		if !r.NeedsPassword {
+			r.unreadable("无法解析文档：" + err.Error())
+		}
+		return r
+	}
+	if ctx.Read.RepairOffset != 0 {
+		r.MalformedXRef = true
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		r.report(opt)
+		r.unreadable("无法读取页面树：" + err.Error())
+		return r
+	}
+
+	r.CanProcess = true
+	r.Pages = ctx.PageCount
+	r.Version = ctx.VersionString()
+	if ctx.Encrypt != nil {
+		r.Encrypted = true
+		r.NeedsPassword = ctx.UserPW != ""
+		if d, err := ctx.DereferenceDict(*ctx.Encrypt); err == nil && d != nil {
+			r.EncryptionHandler = encryptionHandler(d)
+		}
+	}
+	inspectCatalog(ctx, &r)
+	for i := 1; i <= ctx.PageCount; i++ {
+		pageDict, _, _, err := ctx.PageDict(i, false)
+		if err != nil || pageDict == nil {
+			r.EmptyPages = append(r.EmptyPages, i)
+			continue
+		}
+		if !pageHasContent(ctx, pageDict["Contents"]) {
+			r.EmptyPages = append(r.EmptyPages, i)
+		}
+		if _, ok := pageDict["AA"]; ok {
+			r.HasJavaScript = true
+		}
+	}
+	r.report(opt)
+	return r
+}
+
+func (r *PreflightReport) warn(warning, action string) {
+	r.Warnings = append(r.Warnings, warning)
+	if action != "" {
+		r.Actions = append(r.Actions, action)
+	}
+}
+
+// unreadable 记录读取失败；交叉引用表损坏时修复建议已经给出，不再重复。
+func (r *PreflightReport) unreadable(warning string) {
+	action := "请用 PDF 工具修复并另存后重试"
+	if r.MalformedXRef {
+		action = ""
+	}
+	r.warn(warning, action)
+}
+
+// report 根据检测结果生成估算值、警告与建议操作。
+func (r *PreflightReport) report(opt Options) {
+	r.EstimatedMemory = r.Size*preflightMemFactor + int64(r.Pages)*preflightPageMem
+	r.EstimatedOutputSize = r.Size + int64(r.Pages)*preflightPageOverhead + preflightDocOverhead
+	if opt.EmbedFont {
+		r.EstimatedOutputSize += preflightEmbedFontSize
+	}
+	if opt.ExpiredCover.Enabled && opt.ExpiredCover.LogoPath != "" {
+		if fi, err := os.Stat(opt.ExpiredCover.LogoPath); err == nil {
+			r.EstimatedOutputSize += fi.Size()
+		}
+	}
+
+	incremental := opt.OutputMode == OutputIncremental
+	switch {
+	case r.NeedsPassword && r.CanProcess && incremental:
+		r.warn(fmt.Sprintf("文档已加密（%s），将用提供的打开密码解密", r.EncryptionHandler), "增量更新模式保留原有加密与打开密码")
+	case r.NeedsPassword && r.CanProcess:
+		r.warn(fmt.Sprintf("文档已加密（%s），将用提供的打开密码解密，处理后按新的设置重新加密", r.EncryptionHandler), "")
+	case strings.HasPrefix(r.EncryptionHandler, "Adobe.PubSec") && !r.CanProcess:
+		r.warn(fmt.Sprintf("文档使用证书加密（%s），需要接收者的私钥才能打开", r.EncryptionHandler), "请使用加密前的原始文档")
+	case r.NeedsPassword && r.Protected:
+		r.warn(fmt.Sprintf("文档已由本工具加密（%s）", r.EncryptionHandler), "")
+	case r.WrongPassword:
+		r.warn(fmt.Sprintf("文档已加密（%s），提供的打开密码不正确", r.EncryptionHandler), "请核对打开密码，或在密码列表文件中按文件名指定密码")
+	case r.NeedsPassword:
+		r.warn(fmt.Sprintf("文档已加密（%s），需要打开密码", r.EncryptionHandler), "请提供打开密码，或在密码列表文件中按文件名指定密码")
+	case r.Encrypted && incremental:
+		r.warn(fmt.Sprintf("文档已设置权限密码（%s）", r.EncryptionHandler), "增量更新模式保留原有加密与权限设置")
+	case r.Encrypted:
+		r.warn(fmt.Sprintf("文档已设置权限密码（%s）", r.EncryptionHandler), "处理后原有加密与权限设置将被替换")
+	case incremental:
+		r.warn("增量更新模式不会设置密码与权限", "如需加密请改用重写模式")
+	}
+	if r.Protected {
+		r.warn("文档已经过本工具处理", "请使用原始文档重新处理，避免重复叠加保护")
+	}
+	if r.Signed {
+		switch opt.SignedInputPolicy {
+		case SignedInputRefuse:
+			r.CanProcess = false
+			r.warn("文档包含数字签名，按当前设置将拒绝处理", "请使用签名前的原始文档，或改用增量更新模式")
+		case SignedInputIncremental:
+			r.warn("文档包含数字签名，保护将作为新修订追加，原签名修订仍可验证，但不会设置密码与权限", "")
+		default:
+			if incremental {
+				r.warn("文档包含数字签名，保护将作为新修订追加，原签名修订仍可验证", "")
+				break
+			}
+			r.warn("文档包含数字签名，处理后签名将失效", "请使用签名前的原始文档处理后再签名，或改用增量更新模式保留原签名")
+		}
+	}
+	if r.XFA {
+		r.warn("文档包含 XFA 动态表单，处理后表单可能无法显示", "请先将表单展平或另存为静态 PDF")
+	}
+	if r.PDFA != "" {
+		r.warn(fmt.Sprintf("文档声明符合 %s，加密与 JavaScript 会使其不再符合该标准", r.PDFA), "如需长期归档请另行保留原件")
+	}
+	if r.Tagged {
+		r.warn("文档带有结构标签，处理后辅助功能（屏幕阅读、重排）可能受影响", "")
+	}
+	if len(r.EmptyPages) > 0 {
+		r.warn(fmt.Sprintf("%d 个页面没有内容，将按空白页加上保护：%s", len(r.EmptyPages), formatPageList(r.EmptyPages)), "")
+	}
+	if r.MalformedXRef {
+		r.warn("交叉引用表损坏，读取时需要重建", "建议先用 PDF 工具修复并另存后再处理")
+	}
+	if r.HasOCGs {
+		r.warn("文档已有图层（OCG），处理后原有图层保留", "")
+	}
+	if r.HasJavaScript {
+		r.warn("文档包含 JavaScript，将在有效期检查之后继续执行", "")
+	}
+	if r.EstimatedMemory > preflightMemWarn {
+		r.warn(fmt.Sprintf("预计占用内存约 %s", formatSize(r.EstimatedMemory)), "建议压缩文档或减少同时处理的文件数量")
+	}
+}
+
+// inspectCatalog 检查文档级特征：签名、XFA、PDF/A、结构标签、图层与脚本。
+func inspectCatalog(ctx *model.Context, r *PreflightReport) {
+	root := ctx.RootDict
+
+	if form, err := ctx.DereferenceDict(root["AcroForm"]); err == nil && form != nil {
+		if _, ok := form["XFA"]; ok {
+			r.XFA = true
+		}
+	}
+	r.Signed = inspectSignatures(ctx).signed
+
+	if mi, err := ctx.DereferenceDict(root["MarkInfo"]); err == nil && mi != nil {
+		if b := mi.BooleanEntry("Marked"); b != nil && *b {
+			r.Tagged = true
+		}
+	}
+	if _, ok := root["StructTreeRoot"]; ok {
+		r.Tagged = true
+	}
+	r.PDFA = pdfaClaim(ctx, root["Metadata"])
+
+	if oc, err := ctx.DereferenceDict(root["OCProperties"]); err == nil && oc != nil {
+		ocgs, _ := ctx.DereferenceArray(oc["OCGs"])
+		r.HasOCGs = len(ocgs) > 0
+	}
+	r.Protected = hasEngineOCG(ctx)
+
+	if names, err := ctx.DereferenceDict(root["Names"]); err == nil && names != nil {
+		if _, ok := names["JavaScript"]; ok {
+			r.HasJavaScript = true
+		}
+	}
+	if _, ok := root["AA"]; ok {
+		r.HasJavaScript = true
+	}
+	if oa, err := ctx.DereferenceDict(root["OpenAction"]); err == nil && oa != nil {
+		if s := oa.NameEntry("S"); s != nil && *s == "JavaScript" {
+			r.HasJavaScript = true
+		}
+	}
+}
+
+// isEngineOCG 判断 OCG 是否由本工具创建（见 createOCG 写入的 CreatorInfo）。
+func isEngineOCG(ctx *model.Context, o types.Object) bool {
+	d, err := ctx.DereferenceDict(o)
+	if err != nil || d == nil {
+		return false
+	}
+	usage, err := ctx.DereferenceDict(d["Usage"])
+	if err != nil || usage == nil {
+		return false
+	}
+	ci, err := ctx.DereferenceDict(usage["CreatorInfo"])
+	if err != nil || ci == nil {
+		return false
+	}
+	s, err := types.StringOrHexLiteral(ci["Creator"])
+	return err == nil && s != nil && *s == ocgCreator
+}
+
+// pdfaClaim 从 XMP 元数据中读取 pdfaid:part 与 pdfaid:conformance。
+func pdfaClaim(ctx *model.Context, o types.Object) string {
+	if o == nil {
+		return ""
+	}
+	sd, _, err := ctx.DereferenceStreamDict(o)
+	if err != nil || sd == nil {
+		return ""
+	}
+	if err := sd.Decode(); err != nil {
+		return ""
+	}
+	m := pdfaPartRe.FindSubmatch(sd.Content)
+	if m == nil {
+		return ""
+	}
+	claim := "PDF/A-" + string(m[1])
+	if c := pdfaConformanceRe.FindSubmatch(sd.Content); c != nil {
+		claim += strings.ToLower(string(c[1]))
+	}
+	return claim
+}
+
+// pageHasContent 判断页面 Contents 是否包含非空内容流。
+func pageHasContent(ctx *model.Context, o types.Object) bool {
+	o, err := ctx.Dereference(o)
+	if err != nil || o == nil {
+		return false
+	}
+	switch c := o.(type) {
+	case types.Array:
+		for _, e := range c {
+			if pageHasContent(ctx, e) {
+				return true
+			}
+		}
+	case types.StreamDict:
+		if err := c.Decode(); err != nil {
+			return len(c.Raw) > 0
+		}
+		return len(bytes.TrimSpace(c.Content)) > 0
+	}
+	return false
+}
+
+// encryptionHandler 描述加密字典：安全处理程序、版本与算法。
+func encryptionHandler(d types.Dict) string {
+	filter := "Standard"
+	if f := d.NameEntry("Filter"); f != nil {
+		filter = *f
+	}
+	v, rev := 0, 0
+	if i := d.IntEntry("V"); i != nil {
+		v = *i
+	}
+	if i := d.IntEntry("R"); i != nil {
+		rev = *i
+	}
+	alg := "RC4"
+	switch {
+	case v >= 5:
+		alg = "AES-256"
+	case v == 4:
+		if cf, ok := d["CF"].(types.Dict); ok {
+			if std, ok := cf["StdCF"].(types.Dict); ok {
+				if m := std.NameEntry("CFM"); m != nil && *m == "AESV2" {
+					alg = "AES-128"
+				}
+			}
+			if pub, ok := cf["DefaultCryptFilter"].(types.Dict); ok {
+				if m := pub.NameEntry("CFM"); m != nil && *m == "AESV2" {
+					alg = "AES-128"
+				}
+			}
+		}
+	}
+	if rev == 0 {
+		// 公钥安全处理程序没有 R
+		return fmt.Sprintf("%s V%d %s", filter, v, alg)
+	}
+	return fmt.Sprintf("%s V%d R%d %s", filter, v, rev, alg)
+}
+
+// 分块扫描文件时每块的大小与相邻块的重叠，重叠须不小于要匹配的内容的长度
+const (
+	scanChunkSize = 1 << 20
+	scanOverlap   = 256
+	// 加密字典的最大读取长度
+	maxEncryptDictSize = 8 << 10
+)
+
+// scanFile 用固定大小的缓冲区从头扫描 rs，对每块调用 fn，base 为块在文件中的偏移，相邻块重叠 scanOverlap 字节。
+// fn 返回 false 时停止。
+func scanFile(rs io.ReadSeeker, fn func(b []byte, base int64) bool) error {
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, scanChunkSize)
+	var base int64
+	keep := 0
+	for {
+		n, err := io.ReadFull(rs, buf[keep:])
+		b := buf[:keep+n]
+		if n > 0 && !fn(b, base) {
+			return nil
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keep = min(scanOverlap, len(b))
+		copy(buf, b[len(b)-keep:])
+		base += int64(len(b) - keep)
+	}
+}
+
+// rawEncryptionHandler 在无法解密读取时直接从文件中找到加密字典，返回加密方式。
+func rawEncryptionHandler(rs io.ReadSeeker) string {
+	d, err := rawEncryptDict(rs)
+	if err != nil {
+		return "未知"
+	}
+	return encryptionHandler(d)
+}
+
+// rawEncryptDict 不经解密直接从文件中读出加密字典（加密字典本身不加密，且不会放在对象流中）。
+// 文件分块扫描，不整体读入内存。
+func rawEncryptDict(rs io.ReadSeeker) (types.Dict, error) {
+	// 取最后一个 trailer（或交叉引用流字典）中的 /Encrypt 引用
+	var ref [][]byte
+	err := scanFile(rs, func(b []byte, _ int64) bool {
+		if refs := trailerEncryptRe.FindAllSubmatch(b, -1); len(refs) > 0 {
+			last := refs[len(refs)-1]
+			ref = [][]byte{bytes.Clone(last[1]), bytes.Clone(last[2])}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return nil, errors.New("no /Encrypt in trailer")
+	}
+	objNr, _ := strconv.Atoi(string(ref[0]))
+	genNr, _ := strconv.Atoi(string(ref[1]))
+	objRe := regexp.MustCompile(fmt.Sprintf(`(?:^|[^0-9])%d\s+%d\s+obj`, objNr, genNr))
+	at := int64(-1)
+	err = scanFile(rs, func(b []byte, base int64) bool {
+		if loc := objRe.FindIndex(b); loc != nil {
+			at = base + int64(loc[1])
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if at < 0 {
+		return nil, fmt.Errorf("encryption dictionary %d %d R not found", objNr, genNr)
+	}
+	if _, err := rs.Seek(at, io.SeekStart); err != nil {
+		return nil, err
+	}
+	body := make([]byte, maxEncryptDictSize)
+	n, _ := io.ReadFull(rs, body)
+	body = body[:n]
+	if end := bytes.Index(body, []byte("endobj")); end >= 0 {
+		body = body[:end]
+	}
+	s := string(body)
+	o, err := model.ParseObject(&s)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := o.(types.Dict)
+	if !ok {
+		return nil, fmt.Errorf("encryption dictionary %d %d R is not a dictionary", objNr, genNr)
+	}
+	return d, nil
+}
+
+// checkStartXRef 检查 startxref 指向的位置是否为 xref 表或交叉引用流。
+func checkStartXRef(rs io.ReadSeeker, size int64) bool {
+	const tail = 2048
+	off := size - tail
+	if off < 0 {
+		off = 0
+	}
+	if _, err := rs.Seek(off, io.SeekStart); err != nil {
+		return false
+	}
+	b := make([]byte, size-off)
+	n, err := io.ReadFull(rs, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	b = b[:n]
+	i := bytes.LastIndex(b, []byte("startxref"))
+	if i < 0 {
+		return false
+	}
+	fields := bytes.Fields(b[i+len("startxref"):])
+	if len(fields) == 0 {
+		return false
+	}
+	xref, err := strconv.ParseInt(string(fields[0]), 10, 64)
+	if err != nil || xref <= 0 || xref >= size {
+		return false
+	}
+	head := make([]byte, 32)
+	if _, err := rs.Seek(xref, io.SeekStart); err != nil {
+		return false
+	}
+	n, _ = io.ReadFull(rs, head)
+	head = bytes.TrimLeft(head[:n], " \r\n\t")
+	if bytes.HasPrefix(head, []byte("xref")) {
+		return true
+	}
+	// 交叉引用流：N G obj
+	f := bytes.Fields(head)
+	return len(f) >= 3 && string(f[2]) == "obj"
+}
+
+func formatPageList(pages []int) string {
+	const max = 10
+	s := make([]string, 0, max+1)
+	for i, p := range pages {
+		if i == max {
+			s = append(s, "…")
+			break
+		}
+		s = append(s, strconv.Itoa(p))
+	}
+	return strings.Join(s, ", ")
+}
+
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+}
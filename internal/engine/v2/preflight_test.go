@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// writeEncryptedTestPDF 写出一个以 userPW 为打开密码的 AES-256 文档；padding 大于 0 时在第一页追加该长度的内容流，
+// 使加密字典位于文件中靠后的位置。
+func writeEncryptedTestPDF(t *testing.T, path, userPW string, padding int) {
+	t.Helper()
+	plain := path + ".plain.pdf"
+	writeTestPDF(t, plain, 2)
+	if padding > 0 {
+		editTestPDF(t, plain, func(ctx *model.Context) {
+			// 随机字节的十六进制形式，写出时压缩不了多少
+			buf := make([]byte, padding/2)
+			rand.Read(buf)
+			sd, err := ctx.NewStreamDictForBuf([]byte("% " + hex.EncodeToString(buf) + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sd.Encode(); err != nil {
+				t.Fatal(err)
+			}
+			ref, err := ctx.IndRefForNewObject(*sd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageDict, _, _, err := ctx.PageDict(1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageDict["Contents"] = types.Array{pageDict["Contents"], *ref}
+		})
+	}
+	if err := api.EncryptFile(plain, path, model.NewAESConfiguration(userPW, "owner-"+userPW, 256)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// hasWarning 判断报告中是否有包含 s 的警告。
+func hasWarning(r PreflightReport, s string) bool {
+	for _, w := range r.Warnings {
+		if strings.Contains(w, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPreflightEncryptedInput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeEncryptedTestPDF(t, input, "u1", 0)
+
+	tests := []struct {
+		name     string
+		password string
+		wrong    bool
+		canRun   bool
+		warning  string
+	}{
+		{"no password", "", false, false, "需要打开密码"},
+		{"wrong password", "bad", true, false, "打开密码不正确"},
+		{"password", "u1", false, true, "将用提供的打开密码解密"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := testOptions(input, "")
+			opt.InputPassword = tt.password
+			r := Preflight(input, opt)
+			if !r.Encrypted || !r.NeedsPassword || r.WrongPassword != tt.wrong || r.CanProcess != tt.canRun {
+				t.Fatalf("report %+v", r)
+			}
+			if !strings.HasPrefix(r.EncryptionHandler, "Standard V5 ") || !strings.HasSuffix(r.EncryptionHandler, " AES-256") {
+				t.Fatalf("encryption handler %q", r.EncryptionHandler)
+			}
+			if !hasWarning(r, tt.warning) {
+				t.Fatalf("warnings %v, want %q", r.Warnings, tt.warning)
+			}
+			if tt.canRun && r.Pages != 2 {
+				t.Fatalf("pages = %d", r.Pages)
+			}
+		})
+	}
+}
+
+func TestPreflightEncryptDictBeyondFirstChunk(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeEncryptedTestPDF(t, input, "u1", 4*scanChunkSize)
+	if fi, err := os.Stat(input); err != nil || fi.Size() < 2*scanChunkSize {
+		t.Fatalf("input is too small to span several chunks: %v", err)
+	}
+	r := Preflight(input, testOptions(input, ""))
+	if !r.NeedsPassword || !strings.HasSuffix(r.EncryptionHandler, " AES-256") {
+		t.Fatalf("report %+v", r)
+	}
+}
+
+func TestPreflightProtectedOutput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	r := Preflight(opt.Output, opt)
+	if !r.CanProcess || !r.Encrypted || r.NeedsPassword || !r.Protected || !r.HasOCGs || !r.HasJavaScript {
+		t.Fatalf("report %+v", r)
+	}
+	if !hasWarning(r, "已经过本工具处理") {
+		t.Fatalf("warnings %v", r.Warnings)
+	}
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+)
+
+const presetFileName = "presets.json"
+
+// Preset 是命名的处理设置（例如“培训资料 30 天”“法务机密”），保存在配置目录中，可导出给团队共用。
+// 输入输出文件与各类密码不随预设保存，应用时沿用本次的设置。
+type Preset struct {
+	Name        string
+	Description string
+	// 有效期天数：大于 0 时应用预设以当前时间为开始时间；为 0 时使用 Options 中的开始与结束时间
+	ValidDays int
+	Options   Options
+	UpdatedAt time.Time
+}
+
+// presetFile 是预设的存储与导出格式。
+type presetFile struct {
+	Version int
+	Presets []Preset
+}
+
+var presetMu sync.Mutex
+
+// presetPath 返回预设文件路径，与 activation.json 位于同一配置目录。
+func presetPath() (string, error) {
+	d, err := license.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(d, presetFileName), nil
+}
+
+// sanitizedOptions 去掉与单次处理相关的字段（输入输出、操作人）以及全部密码，用于预设与审计日志。
+func sanitizedOptions(opt Options) Options {
+	opt.Input, opt.Output, opt.Files, opt.OutputDir = "", "", "", ""
+	opt.UserPassword, opt.OwnerPassword, opt.generatedOwnerPW = "", "", ""
+	opt.InputPassword, opt.InputPasswords, opt.InputPasswordFile = "", nil, ""
+	opt.Sign.Password = ""
+	opt.PasswordManifestPassword = ""
+	opt.Operator = ""
+	return opt
+}
+
+// normalizePreset 检查预设并整理为保存的形式。
+func normalizePreset(p Preset) (Preset, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return p, fmt.Errorf("预设名称不能为空")
+	}
+	if p.ValidDays < 0 {
+		return p, fmt.Errorf("预设 %q 的有效期天数不能为负数", p.Name)
+	}
+	if err := validateSecurityOptions(p.Options); err != nil {
+		return p, fmt.Errorf("预设 %q：%w", p.Name, err)
+	}
+	p.Options = sanitizedOptions(p.Options)
+	if p.ValidDays > 0 {
+		p.Options.StartTime, p.Options.EndTime = "", ""
+	}
+	return p, nil
+}
+
+func readPresetFile(path string) ([]Preset, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f presetFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parse presets: %w", err)
+	}
+	return f.Presets, nil
+}
+
+func writePresetFile(path string, presets []Preset) error {
+	b, err := json.MarshalIndent(presetFile{Version: 1, Presets: presets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadPresets 读取已保存的预设，文件不存在时返回空列表。调用方需持有 presetMu。
+func loadPresets() ([]Preset, string, error) {
+	p, err := presetPath()
+	if err != nil {
+		return nil, "", err
+	}
+	presets, err := readPresetFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, p, nil
+	}
+	return presets, p, err
+}
+
+// mergePresets 按名称替换同名预设，新的预设追加在末尾。
+func mergePresets(presets []Preset, updates ...Preset) []Preset {
+	for _, u := range updates {
+		replaced := false
+		for i := range presets {
+			if presets[i].Name == u.Name {
+				presets[i], replaced = u, true
+				break
+			}
+		}
+		if !replaced {
+			presets = append(presets, u)
+		}
+	}
+	return presets
+}
+
+// ListPresets 返回已保存的全部预设。
+func ListPresets() ([]Preset, error) {
+	presetMu.Lock()
+	defer presetMu.Unlock()
+	presets, _, err := loadPresets()
+	return presets, err
+}
+
+// GetPreset 按名称查找预设。
+func GetPreset(name string) (*Preset, error) {
+	presets, err := ListPresets()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	for i := range presets {
+		if presets[i].Name == name {
+			return &presets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("预设 %q 不存在", name)
+}
+
+// SavePreset 保存预设，同名预设被替换。
+func SavePreset(p Preset) error {
+	p, err := normalizePreset(p)
+	if err != nil {
+		return err
+	}
+	p.UpdatedAt = time.Now()
+
+	presetMu.Lock()
+	defer presetMu.Unlock()
+	presets, path, err := loadPresets()
+	if err != nil {
+		return err
+	}
+	return writePresetFile(path, mergePresets(presets, p))
+}
+
+// DeletePreset 删除预设。
+func DeletePreset(name string) error {
+	presetMu.Lock()
+	defer presetMu.Unlock()
+	presets, path, err := loadPresets()
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	for i := range presets {
+		if presets[i].Name == name {
+			return writePresetFile(path, append(presets[:i], presets[i+1:]...))
+		}
+	}
+	return fmt.Errorf("预设 %q 不存在", name)
+}
+
+// ExportPresets 把 names 指定的预设（为空时为全部）导出到 path，供其他电脑导入。
+func ExportPresets(path string, names ...string) error {
+	presets, err := ListPresets()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		var picked []Preset
+		for _, n := range names {
+			found := false
+			for _, p := range presets {
+				if p.Name == strings.TrimSpace(n) {
+					picked, found = append(picked, p), true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("预设 %q 不存在", n)
+			}
+		}
+		presets = picked
+	}
+	return writePresetFile(path, presets)
+}
+
+// ImportPresets 导入 path 中的预设，同名预设被替换，返回导入的名称。任一预设无效时不导入任何预设。
+func ImportPresets(path string) ([]string, error) {
+	imported, err := readPresetFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read presets %s: %w", path, err)
+	}
+	var names []string
+	for i := range imported {
+		if imported[i], err = normalizePreset(imported[i]); err != nil {
+			return nil, err
+		}
+		if imported[i].UpdatedAt.IsZero() {
+			imported[i].UpdatedAt = time.Now()
+		}
+		names = append(names, imported[i].Name)
+	}
+
+	presetMu.Lock()
+	defer presetMu.Unlock()
+	presets, p, err := loadPresets()
+	if err != nil {
+		return nil, err
+	}
+	return names, writePresetFile(p, mergePresets(presets, imported...))
+}
+
+// Apply 以预设为基础生成本次的处理设置：输入输出与密码取自 opt，ValidDays 大于 0 时从当前时间起计算有效期。
+func (p Preset) Apply(opt Options) Options {
+	out := p.Options
+	out.Input, out.Output, out.Files, out.OutputDir = opt.Input, opt.Output, opt.Files, opt.OutputDir
+	out.UserPassword, out.OwnerPassword = opt.UserPassword, opt.OwnerPassword
+	out.InputPassword, out.InputPasswords, out.InputPasswordFile = opt.InputPassword, opt.InputPasswords, opt.InputPasswordFile
+	out.Sign.Password = opt.Sign.Password
+	out.PasswordManifestPassword = opt.PasswordManifestPassword
+	out.Operator = opt.Operator
+	if p.ValidDays > 0 {
+		now := time.Now()
+		out.StartTime = now.Format(time.RFC3339)
+		out.EndTime = now.AddDate(0, 0, p.ValidDays).Format(time.RFC3339)
+	}
+	return out
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// 页面资源中保护对象使用的名称，所有页面共用。
+const (
+	resNormalContent = "NormalContent"
+	resExpired       = "expired"
+	resExpiredMask   = "expired_mask"
+	resText          = "text"
+)
+
+func maskResName(i int) string {
+	return fmt.Sprintf("mask_%02d", i+1)
+}
+
+// protection 保存整个文档共用的保护对象：OCG 为文档级（每种一个），
+// 遮罩按 MediaBox、提示 XObject 按页面几何缓存复用，字体按提示文字各建一份，
+// 所有页面共用同一个包装内容流和 Properties 字典，OCProperties 在 finish 中一次写入。
+type protection struct {
+	ctx *model.Context
+	opt Options
+
+	maskOCGs       []*types.IndirectRef
+	expiredOCG     *types.IndirectRef
+	expiredMaskOCG *types.IndirectRef
+	textOCG        *types.IndirectRef
+
+	// OCG 名称带文档级随机后缀，JS 按完整名称精确识别引擎创建的 OCG，不会误伤原文档图层
+	ocgNames   map[*types.IndirectRef]string
+	properties *types.IndirectRef // 共用的 Properties 资源字典
+	content    *types.IndirectRef // 共用的页面包装内容流
+
+	annotOCMD *types.IndirectRef // 注释共用的 OCMD，见 annotationOC
+
+	textFonts    *textFonts
+	expiredFonts *textFonts
+	cover        *coverAssets
+
+	masks map[string]*types.IndirectRef
+	forms map[string]*types.IndirectRef
+}
+
+// newProtection 创建文档级 OCG、共用的 Properties 字典与包装内容流。
+func newProtection(ctx *model.Context, opt Options) (*protection, error) {
+	p := &protection{
+		ctx:      ctx,
+		opt:      opt,
+		ocgNames: map[*types.IndirectRef]string{},
+		masks:    map[string]*types.IndirectRef{},
+		forms:    map[string]*types.IndirectRef{},
+	}
+	nonce := make([]byte, 4)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate ocg suffix: %w", err)
+	}
+	suffix := "." + hex.EncodeToString(nonce)
+	newOCG := func(name string) (*types.IndirectRef, error) {
+		ref, err := createOCG(ctx, name+suffix)
+		if err == nil {
+			p.ocgNames[ref] = name + suffix
+		}
+		return ref, err
+	}
+
+	props := types.Dict{}
+	for i := 0; i < maskNum; i++ {
+		ocg, err := newOCG(maskResName(i))
+		if err != nil {
+			return nil, fmt.Errorf("create mask ocg: %w", err)
+		}
+		p.maskOCGs = append(p.maskOCGs, ocg)
+		props[maskResName(i)] = *ocg
+	}
+	for _, o := range []struct {
+		name string
+		ref  **types.IndirectRef
+	}{
+		{resExpired, &p.expiredOCG},
+		{resExpiredMask, &p.expiredMaskOCG},
+		{resText, &p.textOCG},
+	} {
+		ocg, err := newOCG(o.name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s ocg: %w", o.name, err)
+		}
+		*o.ref = ocg
+		props[o.name] = *ocg
+	}
+
+	var err error
+	if p.properties, err = ctx.IndRefForNewObject(props); err != nil {
+		return nil, err
+	}
+	if p.content, err = buildProtectedPageContent(ctx, maskNum); err != nil {
+		return nil, fmt.Errorf("build page content: %w", err)
+	}
+	return p, nil
+}
+
+// ocgs 返回引擎创建的全部 OCG。
+func (p *protection) ocgs() []*types.IndirectRef {
+	return append(append([]*types.IndirectRef(nil), p.maskOCGs...), p.expiredOCG, p.expiredMaskOCG, p.textOCG)
+}
+
+// jsOCG 是交给 OpenAction 脚本的引擎 OCG：I 为它在 /OCProperties /OCGs 中的位置（getOCGs() 按此顺序返回），
+// N 为名称，脚本用它确认位置上的对象确实是这个 OCG。
+type jsOCG struct {
+	I int    `json:"i"`
+	N string `json:"n"`
+}
+
+// jsOCGs 按对象引用在已安装的 /OCGs 中定位引擎的 OCG，须在 finish 之后调用。all 为引擎创建的全部 OCG（有效期内关闭），
+// expiredOff 为过期后需要关闭的 fallback 提示与过期遮罩；total 为 /OCGs 的长度。
+func (p *protection) jsOCGs() (all, expiredOff []jsOCG, total int) {
+	var arr types.Array
+	if props, err := p.ctx.DereferenceDict(p.ctx.RootDict["OCProperties"]); err == nil && props != nil {
+		arr, _ = p.ctx.DereferenceArray(props["OCGs"])
+	}
+	index := map[int]int{}
+	for i, o := range arr {
+		if ref, ok := o.(types.IndirectRef); ok {
+			index[ref.ObjectNumber.Value()] = i
+		}
+	}
+	entry := func(ref *types.IndirectRef) jsOCG {
+		i, ok := index[ref.ObjectNumber.Value()]
+		if !ok {
+			i = -1
+		}
+		return jsOCG{I: i, N: p.ocgNames[ref]}
+	}
+	for _, ref := range p.ocgs() {
+		all = append(all, entry(ref))
+	}
+	return all, []jsOCG{entry(p.textOCG), entry(p.expiredMaskOCG)}, len(arr)
+}
+
+// mask 返回覆盖 mediaBox 的遮罩 XObject，同尺寸页面共用。
+func (p *protection) mask(mediaBox *types.Rectangle) (*types.IndirectRef, error) {
+	key := mediaBox.String()
+	if ref, ok := p.masks[key]; ok {
+		return ref, nil
+	}
+	ref, err := buildMaskXObject(p.ctx, mediaBox)
+	if err != nil {
+		return nil, err
+	}
+	p.masks[key] = ref
+	return ref, nil
+}
+
+// form 返回 kind 对应的提示 XObject，几何相同（可见区域、旋转、UserUnit）的页面共用。
+func (p *protection) form(kind string, geo *pageGeometry, build func() (*types.IndirectRef, error)) (*types.IndirectRef, error) {
+	key := kind + geo.formMatrix().String() + geo.formBBox().String()
+	if ref, ok := p.forms[key]; ok {
+		return ref, nil
+	}
+	ref, err := build()
+	if err != nil {
+		return nil, err
+	}
+	p.forms[key] = ref
+	return ref, nil
+}
+
+// fallbackForm 返回不支持 JS 的阅读器显示的提示 XObject。
+func (p *protection) fallbackForm(geo *pageGeometry) (*types.IndirectRef, error) {
+	return p.form(resText, geo, func() (*types.IndirectRef, error) {
+		if p.textFonts == nil {
+			p.textFonts = newTextFonts(p.ctx, p.opt.UnsupportedText, embedFontName(p.opt), p.opt.TextLang)
+		}
+		return buildTextXObject(p.ctx, geo, p.textFonts, p.opt.UnsupportedText, p.opt.MessageStyle)
+	})
+}
+
+// expiredForm 返回过期后显示的 XObject：开启封面时为封面，否则为 ExperiredText 提示。
+func (p *protection) expiredForm(geo *pageGeometry) (*types.IndirectRef, error) {
+	return p.form(resExpired, geo, func() (*types.IndirectRef, error) {
+		if p.opt.ExpiredCover.Enabled {
+			if p.cover == nil {
+				a, err := newCoverAssets(p.ctx, p.opt)
+				if err != nil {
+					return nil, err
+				}
+				p.cover = a
+			}
+			return buildCoverXObject(p.ctx, geo, p.cover)
+		}
+		if p.expiredFonts == nil {
+			p.expiredFonts = newTextFonts(p.ctx, p.opt.ExperiredText, embedFontName(p.opt), p.opt.TextLang)
+		}
+		return buildTextXObject(p.ctx, geo, p.expiredFonts, p.opt.ExperiredText, p.opt.MessageStyle)
+	})
+}
+
+// finish 在所有页面处理完后一次性写入 OCProperties；注释已展平时删除表单（控件已合并进页面内容）。
+func (p *protection) finish() {
+	applyOCProperties(p.ctx, p.ocgs())
+	if p.opt.FlattenAnnotations {
+		delete(p.ctx.RootDict, "AcroForm")
+	}
+}
@@ -0,0 +1,259 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// 独立于 cms 包解析 EnvelopedData（RFC 5652 6.1）所用的结构
+type testEnvelope struct {
+	ContentType asn1.ObjectIdentifier
+	Content     struct {
+		Version        int
+		RecipientInfos []struct {
+			Version      int
+			RID          asn1.RawValue
+			KeyAlgorithm pkix.AlgorithmIdentifier
+			EncryptedKey []byte
+		} `asn1:"set"`
+		EncryptedContentInfo struct {
+			ContentType asn1.ObjectIdentifier
+			Algorithm   struct {
+				Algorithm asn1.ObjectIdentifier
+				IV        []byte
+			}
+			EncryptedContent []byte `asn1:"tag:0"`
+		}
+	} `asn1:"explicit,tag:0"`
+}
+
+type testIssuerSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type testRecipient struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+	path string
+}
+
+func newTestRecipient(t *testing.T, dir, name string, serial int64) testRecipient {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	path := filepath.Join(dir, name+".crt")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return testRecipient{key: key, cert: cert, path: path}
+}
+
+// openEnvelope 用接收者私钥打开信封，返回其中的种子与权限；信封不含该接收者时 ok 为 false。
+func openEnvelope(t *testing.T, env []byte, r testRecipient) (seed []byte, perms int32, ok bool) {
+	t.Helper()
+	var e testEnvelope
+	if _, err := asn1.Unmarshal(env, &e); err != nil {
+		t.Fatalf("parse envelope: %v", err)
+	}
+	for _, ri := range e.Content.RecipientInfos {
+		var is testIssuerSerial
+		if _, err := asn1.Unmarshal(ri.RID.FullBytes, &is); err != nil {
+			t.Fatalf("parse recipient id: %v", err)
+		}
+		if is.Serial.Cmp(r.cert.SerialNumber) != 0 || !bytes.Equal(is.Issuer.FullBytes, r.cert.RawIssuer) {
+			continue
+		}
+		key, err := rsa.DecryptPKCS1v15(rand.Reader, r.key, ri.EncryptedKey)
+		if err != nil {
+			t.Fatalf("unwrap content key: %v", err)
+		}
+		content := cbcDecrypt(t, key, e.Content.EncryptedContentInfo.Algorithm.IV, e.Content.EncryptedContentInfo.EncryptedContent)
+		if len(content) != pubSecSeedLen+4 {
+			t.Fatalf("envelope content is %d bytes", len(content))
+		}
+		return content[:pubSecSeedLen], int32(binary.BigEndian.Uint32(content[pubSecSeedLen:])), true
+	}
+	return nil, 0, false
+}
+
+// cbcDecrypt 解密 AES-CBC 并去掉 PKCS#5 填充。
+func cbcDecrypt(t *testing.T, key, iv, data []byte) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		t.Fatalf("ciphertext length %d", len(data))
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		t.Fatalf("bad padding %d", pad)
+	}
+	return out[:len(out)-pad]
+}
+
+var testStreamRe = regexp.MustCompile(`(?s)(\d+) 0 obj\s*<<(.*?)>>\s*stream\r?\n`)
+
+// decryptStreams 用文件密钥（AESV3，直接使用文件密钥）解密输出中的全部流，交叉引用流不加密。
+func decryptStreams(t *testing.T, data, key []byte) [][]byte {
+	t.Helper()
+	lengthRe := regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	var streams [][]byte
+	for _, m := range testStreamRe.FindAllSubmatchIndex(data, -1) {
+		dict := data[m[4]:m[5]]
+		if regexp.MustCompile(`/Type\s*/XRef`).Match(dict) {
+			continue
+		}
+		lm := lengthRe.FindSubmatch(dict)
+		if lm == nil || len(lm[2]) > 0 {
+			t.Fatalf("stream %s has no direct /Length", data[m[2]:m[3]])
+		}
+		n, _ := strconv.Atoi(string(lm[1]))
+		enc := data[m[1] : m[1]+n]
+		plain := cbcDecrypt(t, key, enc[:aes.BlockSize], enc[aes.BlockSize:])
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(plain))
+			if err != nil {
+				t.Fatalf("inflate stream %s: %v", data[m[2]:m[3]], err)
+			}
+			if plain, err = io.ReadAll(zr); err != nil {
+				t.Fatalf("inflate stream %s: %v", data[m[2]:m[3]], err)
+			}
+		}
+		streams = append(streams, plain)
+	}
+	return streams
+}
+
+func TestCertificateEncryptionDecryptsWithRecipientKey(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 2)
+	alice := newTestRecipient(t, dir, "alice", 1)
+	bob := newTestRecipient(t, dir, "bob", 2)
+	outsider := newTestRecipient(t, dir, "outsider", 3)
+
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.Recipients = []Recipient{
+		{CertPath: alice.path, Permissions: Permissions{PrintHighRes: true, Copy: true}},
+		{CertPath: bob.path},
+	}
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(opt.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(opt.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := rawEncryptDict(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc.NameEntry("Filter") == nil || *enc.NameEntry("Filter") != "Adobe.PubSec" || *enc.NameEntry("SubFilter") != "adbe.pkcs7.s5" {
+		t.Fatalf("encryption dictionary %v", enc)
+	}
+	cf := enc.DictEntry("CF").DictEntry("DefaultCryptFilter")
+	var envelopes [][]byte
+	for _, o := range cf.ArrayEntry("Recipients") {
+		var b []byte
+		switch v := o.(type) {
+		case types.HexLiteral:
+			b, err = v.Bytes()
+		case types.StringLiteral:
+			b, err = types.Unescape(v.Value())
+		}
+		if err != nil || len(b) == 0 {
+			t.Fatalf("recipient entry %v: %v", o, err)
+		}
+		envelopes = append(envelopes, b)
+	}
+	if len(envelopes) != 2 {
+		t.Fatalf("%d envelopes, want one per permission set", len(envelopes))
+	}
+
+	// 文件密钥为 SHA-256(种子 + 全部信封)，EncryptMetadata 为 true 时不追加 0xFFFFFFFF
+	fileKey := func(seed []byte) []byte {
+		h := sha256.New()
+		h.Write(seed)
+		for _, e := range envelopes {
+			h.Write(e)
+		}
+		return h.Sum(nil)
+	}
+	wantPerms := map[string]bool{"alice": true, "bob": false}
+	for name, r := range map[string]testRecipient{"alice": alice, "bob": bob} {
+		var seed []byte
+		var perms int32
+		for _, e := range envelopes {
+			if s, p, ok := openEnvelope(t, e, r); ok {
+				seed, perms = s, p
+			}
+		}
+		if seed == nil {
+			t.Fatalf("%s: no envelope for the recipient", name)
+		}
+		canPrint, canCopy := perms&int32(model.PermissionPrintRev3) != 0, perms&int32(model.PermissionExtract) != 0
+		if canPrint != wantPerms[name] || canCopy != wantPerms[name] {
+			t.Fatalf("%s: permissions %b", name, uint32(perms))
+		}
+		found := false
+		for _, s := range decryptStreams(t, data, fileKey(seed)) {
+			if bytes.Contains(s, []byte("(Page 1) Tj")) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%s: page content not found after decryption", name)
+		}
+	}
+	for _, e := range envelopes {
+		if _, _, ok := openEnvelope(t, e, outsider); ok {
+			t.Fatal("envelope opens with a key that is not a recipient")
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
+)
+
+const registryFileName = "registry.json"
+
+// 已发放文档的状态（IssuedDocument.Status）
+const (
+	IssuedActive   = "active"
+	IssuedExpiring = "expiring"
+	IssuedExpired  = "expired"
+	IssuedRevoked  = "revoked"
+)
+
+// DefaultExpiringDays 是“即将到期”的默认天数。
+const DefaultExpiringDays = 7
+
+// IssuedDocument 是已发放文档登记表中的一条记录，每个成功写出的文件一条。
+type IssuedDocument struct {
+	// 输出 trailer 中 ID 的第二项，续期生成的新文件有新的 ID
+	ID           string
+	DocumentID   string
+	Source       string
+	SourceSHA256 string
+	Output       string
+	OutputSHA256 string
+	Recipient    string
+	Operator     string
+	IssuedAt     time.Time
+	StartTime    time.Time
+	EndTime      time.Time
+	// 发放时设置了打开密码；密码不登记，续期时需要重新提供
+	PasswordProtected bool
+	Revoked           bool
+	RevokedAt         time.Time
+	RevokeReason      string
+	// 续期关系：RenewalOf 为被续期的记录，RenewedBy 为续期后的新记录
+	RenewalOf string
+	RenewedBy string
+	// 处理设置，不含任何密码，续期时据此重新生成
+	Options Options
+}
+
+// Status 返回文档在 now 时的状态，到期前 expiringDays 天内为即将到期。
+func (d IssuedDocument) Status(now time.Time, expiringDays int) string {
+	switch {
+	case d.Revoked:
+		return IssuedRevoked
+	case !now.Before(d.EndTime):
+		return IssuedExpired
+	case d.EndTime.Sub(now) <= time.Duration(expiringDays)*24*time.Hour:
+		return IssuedExpiring
+	}
+	return IssuedActive
+}
+
+// IssuedQuery 是登记表的查询条件，零值匹配全部记录。
+type IssuedQuery struct {
+	// 在文件名、路径、接收者、操作人、文档 ID 中查找（不区分大小写）
+	Text   string
+	Status string
+	// 到期日在此区间内（到期日历）
+	ExpiresFrom time.Time
+	ExpiresTo   time.Time
+	// 即将到期的天数，0 时为 DefaultExpiringDays
+	ExpiringDays int
+	// 包含已被续期取代的记录
+	IncludeRenewed bool
+}
+
+// IssuedRecord 是查询结果：登记的记录与查询时的状态。
+type IssuedRecord struct {
+	Document IssuedDocument
+	Status   string
+}
+
+// RenewOptions 是续期的设置。
+type RenewOptions struct {
+	// 新的有效期天数，从当前时间起计算；为 0 时沿用原来的有效期长度
+	ValidDays int
+	// 原文档设置了打开密码时必须提供新文档的打开密码
+	UserPassword string
+	// 源文件的打开密码
+	InputPassword string
+	// 原文档启用了数字签名时签名证书（PKCS#12）的密码，密码不登记，续期时需要重新提供
+	SignPassword string
+	Operator     string
+}
+
+// registryFile 是登记表的存储格式。
+type registryFile struct {
+	Version   int
+	Documents []IssuedDocument
+}
+
+var registryMu sync.Mutex
+
+// registryPath 返回登记表路径，与 activation.json 位于同一配置目录。
+func registryPath() (string, error) {
+	d, err := license.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(d, registryFileName), nil
+}
+
+// loadRegistry 读取登记表，文件不存在时返回空列表。调用方需持有 registryMu。
+func loadRegistry() ([]IssuedDocument, string, error) {
+	p, err := registryPath()
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, p, nil
+	}
+	if err != nil {
+		return nil, p, err
+	}
+	var f registryFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, p, fmt.Errorf("parse registry: %w", err)
+	}
+	return f.Documents, p, nil
+}
+
+func saveRegistry(path string, docs []IssuedDocument) error {
+	b, err := json.MarshalIndent(registryFile{Version: 1, Documents: docs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// updateRegistry 在持有锁的情况下读取、修改并写回登记表。
+func updateRegistry(fn func([]IssuedDocument) ([]IssuedDocument, error)) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	docs, p, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if docs, err = fn(docs); err != nil {
+		return err
+	}
+	return saveRegistry(p, docs)
+}
+
+// issuedDocument 由处理成功的审计记录生成登记表中的记录。
+func issuedDocument(e AuditEntry, opt Options) (IssuedDocument, error) {
+	if e.InstanceID == "" {
+		return IssuedDocument{}, fmt.Errorf("output %s has no file ID", e.Output)
+	}
+	start, end, err := parseOptionTimes(opt.StartTime, opt.EndTime)
+	if err != nil {
+		return IssuedDocument{}, err
+	}
+	return IssuedDocument{
+		ID:                e.InstanceID,
+		DocumentID:        e.DocumentID,
+		Source:            e.Input,
+		SourceSHA256:      e.InputSHA256,
+		Output:            e.Output,
+		OutputSHA256:      e.OutputSHA256,
+		Recipient:         e.Recipient,
+		Operator:          e.Operator,
+		IssuedAt:          e.FinishedAt,
+		StartTime:         start,
+		EndTime:           end,
+		PasswordProtected: len(opt.Recipients) == 0 && strings.TrimSpace(opt.UserPassword) != "",
+		Options:           e.Options,
+	}, nil
+}
+
+// registerIssued 把一批文档写入登记表，只读写一次登记表；ID 为空的记录（无法登记的输出）被跳过。
+func registerIssued(issued ...IssuedDocument) error {
+	if !slices.ContainsFunc(issued, func(d IssuedDocument) bool { return d.ID != "" }) {
+		return nil
+	}
+	return updateRegistry(func(docs []IssuedDocument) ([]IssuedDocument, error) {
+		return mergeIssued(docs, issued...), nil
+	})
+}
+
+// mergeIssued 按 ID 替换已有的记录，新的记录追加在末尾。
+func mergeIssued(docs []IssuedDocument, issued ...IssuedDocument) []IssuedDocument {
+	index := make(map[string]int, len(docs))
+	for i, d := range docs {
+		index[d.ID] = i
+	}
+	for _, d := range issued {
+		if d.ID == "" {
+			continue
+		}
+		if i, ok := index[d.ID]; ok {
+			docs[i] = d
+			continue
+		}
+		index[d.ID] = len(docs)
+		docs = append(docs, d)
+	}
+	return docs
+}
+
+func (q IssuedQuery) match(r IssuedRecord) bool {
+	d := r.Document
+	if d.RenewedBy != "" && !q.IncludeRenewed {
+		return false
+	}
+	if q.Status != "" && r.Status != q.Status {
+		return false
+	}
+	if !q.ExpiresFrom.IsZero() && d.EndTime.Before(q.ExpiresFrom) {
+		return false
+	}
+	if !q.ExpiresTo.IsZero() && d.EndTime.After(q.ExpiresTo) {
+		return false
+	}
+	if text := strings.ToLower(strings.TrimSpace(q.Text)); text != "" {
+		for _, f := range []string{d.Source, d.Output, d.Recipient, d.Operator, d.ID, d.DocumentID} {
+			if strings.Contains(strings.ToLower(f), text) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ListIssued 按条件查询登记表，结果按到期时间从早到晚排列。
+func ListIssued(q IssuedQuery) ([]IssuedRecord, error) {
+	registryMu.Lock()
+	docs, _, err := loadRegistry()
+	registryMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	days := q.ExpiringDays
+	if days <= 0 {
+		days = DefaultExpiringDays
+	}
+	now := time.Now()
+	var records []IssuedRecord
+	for _, d := range docs {
+		r := IssuedRecord{Document: d, Status: d.Status(now, days)}
+		if q.match(r) {
+			records = append(records, r)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Document.EndTime.Before(records[j].Document.EndTime) })
+	return records, nil
+}
+
+// ExpiringIssued 返回 days 天内到期且未撤销、未续期的文档。
+func ExpiringIssued(days int) ([]IssuedRecord, error) {
+	if days <= 0 {
+		days = DefaultExpiringDays
+	}
+	return ListIssued(IssuedQuery{Status: IssuedExpiring, ExpiringDays: days})
+}
+
+// findIssued 按 ID 查找记录，允许只给出 ID 的前缀（至少 8 位）。
+func findIssued(docs []IssuedDocument, id string) (int, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	found := -1
+	for i := range docs {
+		cur := strings.ToLower(docs[i].ID)
+		if cur == id {
+			return i, nil
+		}
+		if len(id) >= 8 && strings.HasPrefix(cur, id) {
+			if found >= 0 {
+				return -1, fmt.Errorf("ID %q 对应多条记录，请输入完整的 ID", id)
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return -1, fmt.Errorf("登记表中没有 ID 为 %q 的文档", id)
+	}
+	return found, nil
+}
+
+// GetIssued 按 ID 返回登记的文档。
+func GetIssued(id string) (*IssuedDocument, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	docs, _, err := loadRegistry()
+	if err != nil {
+		return nil, err
+	}
+	i, err := findIssued(docs, id)
+	if err != nil {
+		return nil, err
+	}
+	return &docs[i], nil
+}
+
+// RevokeIssued 把文档标记为已撤销。撤销只记录在登记表中，已发出的文件仍按原有效期显示。
+func RevokeIssued(id, reason string) error {
+	return updateRegistry(func(docs []IssuedDocument) ([]IssuedDocument, error) {
+		i, err := findIssued(docs, id)
+		if err != nil {
+			return nil, err
+		}
+		if docs[i].Revoked {
+			return nil, fmt.Errorf("文档 %s 已撤销", docs[i].ID)
+		}
+		docs[i].Revoked, docs[i].RevokedAt, docs[i].RevokeReason = true, time.Now(), strings.TrimSpace(reason)
+		return docs, nil
+	})
+}
+
+// RenewIssued 以登记的处理设置从源文件重新生成文档，有效期从当前时间起计算。
+// 新文件写在原输出旁（重名时自动加后缀）并登记为新记录，原记录标记为已被续期。
+func RenewIssued(id string, ro RenewOptions) (*IssuedDocument, error) {
+	old, err := GetIssued(id)
+	if err != nil {
+		return nil, err
+	}
+	if old.Revoked {
+		return nil, fmt.Errorf("文档 %s 已撤销，不能续期", old.ID)
+	}
+	if old.RenewedBy != "" {
+		return nil, fmt.Errorf("文档 %s 已续期为 %s，请对新文档续期", old.ID, old.RenewedBy)
+	}
+	if old.PasswordProtected && strings.TrimSpace(ro.UserPassword) == "" {
+		return nil, fmt.Errorf("原文档设置了打开密码，续期需要提供新的打开密码")
+	}
+	if _, err := os.Stat(old.Source); err != nil {
+		return nil, fmt.Errorf("源文件不可用：%w", err)
+	}
+	if sum, _ := fileSHA256(old.Source); sum != old.SourceSHA256 {
+		logging.Warn("source changed since the document was issued", "source", old.Source, "id", old.ID)
+	}
+
+	opt := old.Options
+	opt.Input, opt.Output = old.Source, old.Output
+	opt.UserPassword, opt.InputPassword = ro.UserPassword, ro.InputPassword
+	opt.Operator = ro.Operator
+	if opt.Sign.Enabled {
+		// 签名证书的密码不登记；PKCS#12 证书没有密码无法签名，不必重新生成文档
+		opt.Sign.Password = ro.SignPassword
+		ext := strings.ToLower(filepath.Ext(opt.Sign.CertPath))
+		if ro.SignPassword == "" && (ext == ".p12" || ext == ".pfx") {
+			return nil, fmt.Errorf("原文档使用 PKCS#12 证书签名，续期需要提供签名证书的密码")
+		}
+	}
+	validFor := old.EndTime.Sub(old.StartTime)
+	if ro.ValidDays > 0 {
+		validFor = time.Duration(ro.ValidDays) * 24 * time.Hour
+	}
+	now := time.Now()
+	opt.StartTime = now.Format(time.RFC3339)
+	opt.EndTime = now.Add(validFor).Format(time.RFC3339)
+
+	out, doc, err := processFile(opt, old.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ID == "" {
+		return nil, fmt.Errorf("续期已生成 %s，但无法登记新文档", out)
+	}
+
+	// 新记录与续期关系一次写入登记表
+	err = updateRegistry(func(docs []IssuedDocument) ([]IssuedDocument, error) {
+		i, err := findIssued(docs, old.ID)
+		if err != nil {
+			return nil, err
+		}
+		docs[i].RenewedBy = doc.ID
+		doc.RenewalOf = docs[i].ID
+		return mergeIssued(docs, doc), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("续期已生成 %s，但更新登记表失败：%w", out, err)
+	}
+	return &doc, nil
+}
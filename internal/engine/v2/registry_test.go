@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchRegistersEveryOutput(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	opt := testOptions("", "")
+	opt.Files = writeBatchInputs(t, dir, 3)
+	opt.OutputDir = filepath.Join(dir, "out")
+	if err := os.Mkdir(opt.OutputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := RunBatch(opt); err != nil || n != 3 {
+		t.Fatalf("RunBatch = %d, %v", n, err)
+	}
+	records, err := ListIssued(IssuedQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	// testOptions 的有效期只有一天，登记后即为即将到期
+	for _, r := range records {
+		if r.Status != IssuedExpiring || filepath.Dir(r.Document.Output) != opt.OutputDir {
+			t.Fatalf("record %+v", r)
+		}
+		ids[r.Document.ID] = true
+	}
+	if len(records) != 3 || len(ids) != 3 {
+		t.Fatalf("%d records with %d IDs, want 3", len(records), len(ids))
+	}
+}
+
+func TestRenewIssuedSigned(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.Sign = SignOptions{Enabled: true, Reason: "test"}
+	opt.Sign.CertPath, opt.Sign.KeyPath = writeTestSigner(t, dir)
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	records, err := ListIssued(IssuedQuery{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("ListIssued = %d records, %v", len(records), err)
+	}
+	old := records[0].Document
+
+	renewed, err := RenewIssued(old.ID, RenewOptions{ValidDays: 30})
+	if err != nil {
+		t.Fatalf("RenewIssued: %v", err)
+	}
+	if renewed.RenewalOf != old.ID || renewed.ID == old.ID {
+		t.Fatalf("renewed %s is not linked to %s", renewed.ID, old.ID)
+	}
+	if got, err := GetIssued(old.ID); err != nil || got.RenewedBy != renewed.ID {
+		t.Fatalf("original record: %+v, %v", got, err)
+	}
+	out, err := os.ReadFile(renewed.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte("/ByteRange")) {
+		t.Fatal("renewed output is not signed")
+	}
+
+	// PKCS#12 证书没有密码时在重新生成之前失败
+	err = updateRegistry(func(docs []IssuedDocument) ([]IssuedDocument, error) {
+		i, err := findIssued(docs, renewed.ID)
+		if err != nil {
+			return nil, err
+		}
+		docs[i].Options.Sign.CertPath = filepath.Join(dir, "signer.p12")
+		return docs, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RenewIssued(renewed.ID, RenewOptions{}); err == nil || !strings.Contains(err.Error(), "签名证书的密码") {
+		t.Fatalf("RenewIssued without the certificate password = %v", err)
+	}
+	if records, _ := ListIssued(IssuedQuery{IncludeRenewed: true}); len(records) != 2 {
+		t.Fatalf("%d records after the failed renewal, want 2", len(records))
+	}
+}
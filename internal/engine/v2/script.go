@@ -0,0 +1,286 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	pdffont "github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/cg917658910/win-pdf/internal/logging"
+)
+
+// textScript 标识一个字符所属的书写系统，用于选择输出字体。
+type textScript int
+
+const (
+	scriptLatin     textScript = iota // WinAnsi 可编码，走 Helvetica
+	scriptHan                         // 汉字，字符集取决于语言（GB1/CNS1/Japan1/Korea1）
+	scriptKana                        // 平假名/片假名，Adobe-Japan1
+	scriptHangul                      // 谚文，Adobe-Korea1
+	scriptCJKCommon                   // CJK 标点、全角符号，跟随上下文的 CJK 字符集
+	scriptOther                       // 其它文字，需要嵌入 Unicode 字体
+)
+
+// classifyRune 返回 r 的书写系统。
+func classifyRune(r rune) textScript {
+	if r >= 0x20 && r != 0x7F {
+		if _, ok := charmap.Windows1252.EncodeRune(r); ok {
+			return scriptLatin
+		}
+	}
+	switch {
+	case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+		return scriptKana
+	case unicode.Is(unicode.Hangul, r):
+		return scriptHangul
+	case unicode.Is(unicode.Han, r):
+		return scriptHan
+	case r >= 0x3000 && r <= 0x303F, r >= 0xFF00 && r <= 0xFFEF, r >= 0x2000 && r <= 0x206F:
+		return scriptCJKCommon
+	}
+	return scriptOther
+}
+
+// cjkFontForLang 返回汉字和 CJK 标点使用的预定义字体：
+// 优先按语言标签（zh-CN、zh-TW、ja、ko 等），为空时根据文字中的假名/谚文推断，默认简体中文。
+func cjkFontForLang(lang, text string) cidFontSpec {
+	l := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	switch {
+	case strings.HasPrefix(l, "ja"):
+		return cidFontJapan1
+	case strings.HasPrefix(l, "ko"):
+		return cidFontKorea1
+	case l == "zh-tw", l == "zh-hk", l == "zh-mo", strings.HasPrefix(l, "zh-hant"):
+		return cidFontCNS1
+	case strings.HasPrefix(l, "zh"):
+		return cidFontGB1
+	}
+	hasHan := false
+	for _, r := range text {
+		switch classifyRune(r) {
+		case scriptKana:
+			return cidFontJapan1
+		case scriptHan:
+			hasHan = true
+		}
+	}
+	if !hasHan && strings.ContainsFunc(text, func(r rune) bool { return classifyRune(r) == scriptHangul }) {
+		return cidFontKorea1
+	}
+	return cidFontGB1
+}
+
+// textRun 是使用同一字体资源输出的一段已编码文字。
+type textRun struct {
+	font  string
+	code  []byte
+	runes []rune
+}
+
+// textFonts 为文字 XObject 按需创建字体资源并负责分段编码：
+// F1 为上下文 CJK 字体（开启嵌入时为嵌入子集），F2 为 Helvetica/WinAnsi，
+// 其余 CJK 字符集与自动挑选的嵌入 Unicode 字体依次编号为 F3、F4……
+type textFonts struct {
+	ctx      *model.Context
+	dict     types.Dict
+	cjk      cidFontSpec
+	embedded *embeddedFont
+	unicode  *embeddedFont
+	names    map[string]string
+	next     int
+}
+
+// newTextFonts 为 text 准备字体；fontName 非空时嵌入该用户字体子集，lang 决定汉字使用的字符集。
+func newTextFonts(ctx *model.Context, text, fontName, lang string) *textFonts {
+	tf := &textFonts{
+		ctx:   ctx,
+		dict:  types.Dict{},
+		cjk:   cjkFontForLang(lang, text),
+		names: map[string]string{},
+		next:  3,
+	}
+	if fontName != "" {
+		f, err := createEmbeddedType0Font(ctx, fontName, text)
+		if err != nil {
+			logging.Warn("embed font failed, using fallback", "font", fontName, "fallback", tf.cjk.baseFont, "err", err)
+		} else {
+			tf.embedded = f
+		}
+	}
+
+	// 其它文字（西里尔、希腊、阿拉伯等）且未被嵌入字体覆盖时，挑选一个覆盖它们的用户字体嵌入
+	var others []rune
+	for _, r := range text {
+		if unicode.IsControl(r) || classifyRune(r) != scriptOther {
+			continue
+		}
+		if tf.embedded != nil {
+			if _, ok := tf.embedded.glyph(r); ok {
+				continue
+			}
+		}
+		others = append(others, r)
+	}
+	if len(others) > 0 {
+		if name := pickUserFontForRunes(others); name != "" {
+			f, err := createEmbeddedType0Font(ctx, name, string(others))
+			if err != nil {
+				logging.Warn("embed unicode font failed", "font", name, "err", err)
+			} else {
+				tf.unicode = f
+			}
+		} else {
+			logging.Warn("no user font covers the text; it may not render", "runes", string(others))
+		}
+	}
+	return tf
+}
+
+// resource 返回 key 对应字体的资源名，首次使用时创建字体对象。
+func (tf *textFonts) resource(key string) (string, error) {
+	if name, ok := tf.names[key]; ok {
+		return name, nil
+	}
+	var (
+		ref *types.IndirectRef
+		err error
+	)
+	name := ""
+	switch key {
+	case "embedded":
+		ref, name = tf.embedded.ref, "F1"
+	case "latin":
+		ref, err = createHelveticaFont(tf.ctx)
+		name = "F2"
+	case "unicode":
+		ref = tf.unicode.ref
+	default:
+		spec := cidFontSpecs[key]
+		ref, err = createCIDType0Font(tf.ctx, spec)
+		if spec == tf.cjk && tf.embedded == nil {
+			name = "F1"
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = fmt.Sprintf("F%d", tf.next)
+		tf.next++
+	}
+	tf.dict[name] = *ref
+	tf.names[key] = name
+	return name, nil
+}
+
+var cidFontSpecs = map[string]cidFontSpec{
+	cidFontGB1.ordering:    cidFontGB1,
+	cidFontCNS1.ordering:   cidFontCNS1,
+	cidFontJapan1.ordering: cidFontJapan1,
+	cidFontKorea1.ordering: cidFontKorea1,
+}
+
+// fontKey 决定字符 r 使用的字体，以及按该字体编码后的字节。
+func (tf *textFonts) fontKey(r rune) (string, []byte) {
+	if tf.embedded != nil {
+		if gid, ok := tf.embedded.glyph(r); ok {
+			return "embedded", []byte{byte(gid >> 8), byte(gid)}
+		}
+	}
+	ucs2 := func() []byte {
+		var b []byte
+		for _, cp := range utf16.Encode([]rune{r}) {
+			b = append(b, byte(cp>>8), byte(cp&0xFF))
+		}
+		return b
+	}
+	switch classifyRune(r) {
+	case scriptLatin:
+		c, _ := charmap.Windows1252.EncodeRune(r)
+		return "latin", []byte{c}
+	case scriptKana:
+		return cidFontJapan1.ordering, ucs2()
+	case scriptHangul:
+		return cidFontKorea1.ordering, ucs2()
+	case scriptOther:
+		if tf.unicode != nil {
+			if gid, ok := tf.unicode.glyph(r); ok {
+				return "unicode", []byte{byte(gid >> 8), byte(gid)}
+			}
+		}
+	}
+	return tf.cjk.ordering, ucs2()
+}
+
+// runeWidth 返回字符 r 在其所用字体中的字宽（1000 单位字形空间）。
+func (tf *textFonts) runeWidth(r rune) int {
+	key, code := tf.fontKey(r)
+	switch key {
+	case "embedded":
+		return tf.embedded.width(uint16(code[0])<<8 | uint16(code[1]))
+	case "unicode":
+		return tf.unicode.width(uint16(code[0])<<8 | uint16(code[1]))
+	case "latin":
+		return pdffont.CharWidth("Helvetica", rune(code[0]))
+	}
+	// 预定义 CJK 字体：半角片假名与其余半角字符按 500，其余按全角 1000
+	if r >= 0xFF61 && r <= 0xFFDC || classifyRune(r) == scriptOther {
+		return 500
+	}
+	return 1000
+}
+
+// segment 把 text 切分为使用同一字体的连续段。
+func (tf *textFonts) segment(text string) ([]textRun, error) {
+	var runs []textRun
+	lastKey := ""
+	for _, r := range text {
+		key, code := tf.fontKey(r)
+		if len(runs) == 0 || key != lastKey {
+			name, err := tf.resource(key)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, textRun{font: name})
+			lastKey = key
+		}
+		cur := &runs[len(runs)-1]
+		cur.code = append(cur.code, code...)
+		cur.runes = append(cur.runes, r)
+	}
+	return runs, nil
+}
+
+// pickUserFontForRunes 从已安装的用户字体中挑选覆盖 runes 最多的一个。
+func pickUserFontForRunes(runes []rune) string {
+	pdffont.UserFontMetricsLock.RLock()
+	defer pdffont.UserFontMetricsLock.RUnlock()
+	names := make([]string, 0, len(pdffont.UserFontMetrics))
+	for name := range pdffont.UserFontMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	best, bestCount := "", 0
+	for _, name := range names {
+		ttf := pdffont.UserFontMetrics[name]
+		count := 0
+		for _, r := range runes {
+			if gid, ok := ttf.Chars[uint32(r)]; ok && gid != 0 {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = name, count
+		}
+		if count == len(runes) {
+			break
+		}
+	}
+	return best
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// 加密算法（Options.Encryption）
+const (
+	EncryptionAES256 = "aes-256"
+	EncryptionAES128 = "aes-128"
+	EncryptionNone   = "none"
+)
+
+// Permissions 是文档的用户访问权限（ISO 32000 表 22），零值为禁止全部操作。
+type Permissions struct {
+	PrintLowRes   bool // 打印（低分辨率，位 3）
+	PrintHighRes  bool // 高质量打印（位 12），隐含低分辨率打印
+	Modify        bool // 修改内容（位 4）
+	Copy          bool // 复制、提取文字与图形（位 5）
+	Annotate      bool // 添加、修改注释并填写表单（位 6）
+	FillForms     bool // 填写已有的表单域与签名域（位 9）
+	Accessibility bool // 为辅助功能提取内容（位 10）
+	Assemble      bool // 插入、旋转、删除页面，创建书签与缩略图（位 11）
+}
+
+// legacyPermissions 把打印、复制、编辑、转换四个选项映射为权限集合。
+func legacyPermissions(print, copy, edit, convert bool) Permissions {
+	return Permissions{
+		PrintLowRes:   print,
+		PrintHighRes:  print,
+		Copy:          copy || convert,
+		Accessibility: copy || convert,
+		Modify:        edit,
+		Annotate:      edit,
+		FillForms:     edit,
+		Assemble:      edit,
+	}
+}
+
+// permReserved 是 P 中的保留位：位 7、8 与 13–32 必须为 1，位 1、2 必须为 0（ISO 32000 表 22）。
+const permReserved = ^int32(0xF3F)
+
+// flags 从保留位开始，按权限设置各个位，结果即写入加密字典的 P。
+func (p Permissions) flags() model.PermissionFlags {
+	f := model.PermissionFlags(permReserved)
+	set := func(ok bool, bit model.PermissionFlags) {
+		if ok {
+			f |= bit
+		}
+	}
+	set(p.PrintLowRes || p.PrintHighRes, model.PermissionPrintRev2)
+	set(p.PrintHighRes, model.PermissionPrintRev3)
+	set(p.Modify, model.PermissionModify)
+	set(p.Copy, model.PermissionExtract)
+	set(p.Annotate, model.PermissionModAnnFillForm)
+	set(p.FillForms, model.PermissionFillRev3)
+	set(p.Accessibility, model.PermissionExtractRev3)
+	set(p.Assemble, model.PermissionAssembleRev3)
+	return f
+}
+
+// permissions 返回生效的权限：未设置 Permissions 时由 AllowedPrint 等四个选项推导。
+func (opt Options) permissions() Permissions {
+	if opt.Permissions != nil {
+		return *opt.Permissions
+	}
+	return legacyPermissions(opt.AllowedPrint, opt.AllowedCopy, opt.AllowedEdit, opt.AllowedConvert)
+}
+
+// pdfVersions 是可选的目标版本：图层需要 1.5，AES-128 需要 1.6，AES-256 与证书加密需要 1.7。
+var pdfVersions = map[string]model.Version{
+	"1.5": model.V15,
+	"1.6": model.V16,
+	"1.7": model.V17,
+	"2.0": model.V20,
+}
+
+// validateSecurityOptions 检查加密算法、目标 PDF 版本、密码与证书加密之间的组合。
+func validateSecurityOptions(opt Options) error {
+	enc := opt.Encryption
+	switch enc {
+	case "", EncryptionAES256, EncryptionAES128, EncryptionNone:
+	default:
+		return fmt.Errorf("不支持的加密算法 %q，可选 aes-256、aes-128 或 none", enc)
+	}
+	if enc == "" {
+		enc = EncryptionAES256
+	}
+	if enc == EncryptionNone && strings.TrimSpace(opt.UserPassword) != "" {
+		return fmt.Errorf("不加密时不能设置打开密码")
+	}
+	if len(opt.Recipients) > 0 && enc != EncryptionAES256 {
+		return fmt.Errorf("证书加密只支持 AES-256")
+	}
+
+	if opt.PDFVersion == "" {
+		return nil
+	}
+	v, ok := pdfVersions[opt.PDFVersion]
+	if !ok {
+		return fmt.Errorf("不支持的 PDF 版本 %q，可选 1.5、1.6、1.7 或 2.0（图层保护至少需要 1.5）", opt.PDFVersion)
+	}
+	switch {
+	case enc == EncryptionAES256 && v < model.V17:
+		return fmt.Errorf("AES-256 加密至少需要 PDF 1.7")
+	case enc == EncryptionAES128 && v < model.V16:
+		return fmt.Errorf("AES-128 加密至少需要 PDF 1.6")
+	case enc == EncryptionAES128 && v == model.V20:
+		return fmt.Errorf("PDF 2.0 已弃用 AES-128，请改用 AES-256")
+	}
+	return nil
+}
+
+// validateIncrementalEncryption 检查增量写出时指定的加密算法与输入一致：追加的修订只能沿用输入的加密。
+// 未指定时保留输入原有的加密（或不加密）。
+func validateIncrementalEncryption(ctx *model.Context, opt Options) error {
+	if opt.Encryption == "" {
+		return nil
+	}
+	if in := inputEncryption(ctx); opt.Encryption != in {
+		return fmt.Errorf("增量更新不能更改加密方式（输入为 %s，设置为 %s），请清空加密算法或改用重写模式", in, opt.Encryption)
+	}
+	return nil
+}
+
+// inputEncryption 返回输入使用的加密算法，取值同 Options.Encryption；RC4 等其他算法返回 "other"。
+func inputEncryption(ctx *model.Context) string {
+	switch {
+	case ctx.E == nil:
+		return EncryptionNone
+	case ctx.E.R >= 5:
+		return EncryptionAES256
+	case ctx.E.R == 4 && ctx.AES4Streams:
+		return EncryptionAES128
+	}
+	return "other"
+}
+
+// applyPDFVersion 设置输出的 PDF 版本。pdfcpu 只写出 1.7 或 2.0 的文件头，更低的版本在写出后由 setHeaderVersion 改写。
+func applyPDFVersion(ctx *model.Context, version string) {
+	v, ok := pdfVersions[version]
+	if !ok {
+		return
+	}
+	// 目录中的 Version 优先于文件头，统一去掉后由文件头决定
+	ctx.RootDict.Delete("Version")
+	ctx.RootVersion = nil
+	if v == model.V20 {
+		ctx.HeaderVersion = &v
+		return
+	}
+	v17 := model.V17
+	ctx.HeaderVersion = &v17
+}
+
+// setHeaderVersion 把已写出文件头中的 1.7 改为 version（长度相同，原地改写）。
+func setHeaderVersion(path, version string) error {
+	if v, ok := pdfVersions[version]; !ok || v >= model.V17 {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	header := make([]byte, 8)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	if string(header) != "%PDF-1.7" {
+		return fmt.Errorf("unexpected header %q", header)
+	}
+	_, err = f.WriteAt([]byte(version), 5)
+	return err
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPermissionFlags(t *testing.T) {
+	all := Permissions{PrintLowRes: true, PrintHighRes: true, Modify: true, Copy: true, Annotate: true, FillForms: true, Accessibility: true, Assemble: true}
+	tests := []struct {
+		name  string
+		perms Permissions
+		want  int32
+	}{
+		{"none", Permissions{}, -3904},                    // 0xFFFFF0C0：只有保留位
+		{"all", all, -4},                                  // 0xFFFFFFFC：位 1、2 仍为 0
+		{"print", Permissions{PrintHighRes: true}, -1852}, // 0xFFFFF8C4：位 3 与 12
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := int32(tt.perms.flags()); got != tt.want {
+				t.Fatalf("flags = %d (%#x), want %d", got, uint32(got), tt.want)
+			}
+			// 写入加密字典的 P 与之相同
+			testConfigDir(t)
+			opt := testOptions(input, filepath.Join(dir, tt.name+".pdf"))
+			opt.Permissions = &tt.perms
+			if err := Run(opt); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			ctx := readTestPDF(t, opt.Output, "")
+			if ctx.E == nil || int32(ctx.E.P) != tt.want {
+				t.Fatalf("written P = %+v, want %d", ctx.E, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/cms"
+	"github.com/cg917658910/win-pdf/internal/logging"
+	"github.com/cg917658910/win-pdf/internal/tsa"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// SignOptions 控制输出文件的数字签名（PAdES，CMS 分离式签名）。
+type SignOptions struct {
+	Enabled bool
+	// CertPath 为 PKCS#12（.p12/.pfx）文件，或 PEM 证书链（签名证书与中间证书）
+	CertPath string
+	// KeyPath 为 PEM 私钥；为空时从 CertPath 中读取
+	KeyPath string
+	// Password 为 PKCS#12 文件的密码
+	Password    string
+	Reason      string
+	Location    string
+	ContactInfo string
+	// Visible 为 true 时在页面上显示签名外观，否则为不可见签名
+	Visible bool
+	// Page 为显示签名的页码，0 或超出范围时为最后一页
+	Page int
+	// Rect 为签名框（pt，以可见区域左下角为原点、按阅读器显示方向），全为 0 时放在右下角
+	Rect [4]float64
+	// TSAURL 为 RFC 3161 时间戳服务地址，为空时不加时间戳；"local" 使用进程内的测试 TSA
+	TSAURL string
+}
+
+const (
+	sigDefaultWidth  = 180
+	sigDefaultHeight = 54
+	sigDefaultMargin = 36
+	sigFontSize      = 9
+	sigPadding       = 4
+	// 时间戳令牌（含 TSA 证书链）预留的空间
+	sigTimestampReserve = 8192
+	localTSA            = "local"
+)
+
+var (
+	testTSAOnce sync.Once
+	testTSA     *tsa.Local
+	testTSAErr  error
+)
+
+// loadSigner 读取签名私钥与证书链，证书不在有效期内时给出提示。
+func loadSigner(opt SignOptions) (cms.Signer, error) {
+	s, err := cms.LoadSigner(opt.CertPath, opt.KeyPath, opt.Password)
+	if err != nil {
+		return cms.Signer{}, err
+	}
+	if now := time.Now(); now.Before(s.Certificate.NotBefore) || now.After(s.Certificate.NotAfter) {
+		logging.Warn("signing certificate is not valid now", "subject", s.Certificate.Subject.CommonName,
+			"notBefore", s.Certificate.NotBefore.Format(time.DateOnly), "notAfter", s.Certificate.NotAfter.Format(time.DateOnly))
+	}
+	return s, nil
+}
+
+// signFile 以增量更新的方式给已写出的 path 加上签名：原文件字节不变，签名覆盖整个受保护的文档。
+// userPW、ownerPW 为打开 path 所用的密码。
+func signFile(path string, opt Options, signer cms.Signer, userPW, ownerPW string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read output: %w", err)
+	}
+	conf := model.NewDefaultConfiguration()
+	conf.UserPW = userPW
+	conf.OwnerPW = ownerPW
+	ctx, err := api.ReadContext(bytes.NewReader(data), conf)
+	if err != nil {
+		return fmt.Errorf("read output: %w", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return err
+	}
+
+	before := usedObjects(ctx)
+	inc := newIncrement(ctx, int64(len(data)))
+	if err := addSignatureField(ctx, inc, opt, signer); err != nil {
+		return err
+	}
+	inc.addNewObjects(before)
+
+	tail, err := inc.write()
+	if err != nil {
+		return fmt.Errorf("write increment: %w", err)
+	}
+	start, end, err := inc.patchByteRange()
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	h.Write(data)
+	h.Write(tail[:start])
+	h.Write(tail[end:])
+	sd, err := cms.SignDetached(signer, h.Sum(nil))
+	if err != nil {
+		return err
+	}
+	if url := strings.TrimSpace(opt.Sign.TSAURL); url != "" {
+		if err := addTimestamp(sd, url); err != nil {
+			return err
+		}
+	}
+	der, err := sd.Bytes()
+	if err != nil {
+		return err
+	}
+	// 占位符为 <00...>，签名按十六进制写入，剩余部分保持 0
+	if 2*len(der) > end-start-2 {
+		return fmt.Errorf("signature (%d bytes) exceeds reserved space", len(der))
+	}
+	hex.Encode(tail[start+1:], der)
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(tail); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addTimestamp 对签名值请求 RFC 3161 时间戳，作为 id-aa-timeStampToken 未签名属性加入签名。
+func addTimestamp(sd *cms.SignedData, url string) error {
+	var t tsa.Timestamper
+	if url == localTSA {
+		testTSAOnce.Do(func() {
+			testTSA, testTSAErr = tsa.NewTestLocal()
+			logging.Warn("using the built-in test TSA; its timestamps are not trusted by readers")
+		})
+		if testTSAErr != nil {
+			return fmt.Errorf("start test TSA: %w", testTSAErr)
+		}
+		t = testTSA
+	} else {
+		t = &tsa.Client{URL: url}
+	}
+	digest := sha256.Sum256(sd.Signature())
+	token, err := t.Timestamp(digest[:])
+	if err != nil {
+		return fmt.Errorf("timestamp: %w", err)
+	}
+	sd.AddUnsignedAttribute(cms.OIDAttrTimeStampToken, token)
+	return nil
+}
+
+// signatureReserve 返回 /Contents 预留的字节数：证书链、签名值与属性之外再留出余量。
+func signatureReserve(signer cms.Signer, timestamp bool) int {
+	n := len(signer.Certificate.Raw) + 4096
+	if timestamp {
+		n += sigTimestampReserve
+	}
+	for _, c := range signer.Chain {
+		n += len(c.Raw)
+	}
+	switch k := signer.Key.Public().(type) {
+	case *rsa.PublicKey:
+		n += k.Size()
+	case *ecdsa.PublicKey:
+		n += 2 * (k.Curve.Params().BitSize + 7) / 8
+	}
+	return n
+}
+
+// addSignatureField 新建签名字典与签名域控件，挂到目标页与 AcroForm 上。
+func addSignatureField(ctx *model.Context, inc *increment, opt Options, signer cms.Signer) error {
+	so := opt.Sign
+	pageNr := so.Page
+	if pageNr < 1 || pageNr > ctx.PageCount {
+		pageNr = ctx.PageCount
+	}
+	pageDict, pageRef, inh, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return fmt.Errorf("get page dict: %w", err)
+	}
+	if pageDict == nil || pageRef == nil {
+		return fmt.Errorf("page %d not found", pageNr)
+	}
+
+	signed := time.Now()
+	sig := types.Dict{
+		"Type":      types.Name("Sig"),
+		"Filter":    types.Name("Adobe.PPKLite"),
+		"SubFilter": types.Name("ETSI.CAdES.detached"),
+		"M":         types.StringLiteral(types.DateString(signed)),
+		"Contents":  types.NewHexLiteral(make([]byte, signatureReserve(signer, strings.TrimSpace(so.TSAURL) != ""))),
+	}
+	name := signer.Certificate.Subject.CommonName
+	for k, v := range map[string]string{"Name": name, "Reason": so.Reason, "Location": so.Location, "ContactInfo": so.ContactInfo} {
+		if v = strings.TrimSpace(v); v != "" {
+			sig[k] = pdfTextString(v)
+		}
+	}
+	sigRef, err := ctx.IndRefForNewObject(sig)
+	if err != nil {
+		return err
+	}
+	inc.addSignature(sigRef.ObjectNumber.Value())
+
+	form, formNr, err := acroForm(ctx)
+	if err != nil {
+		return err
+	}
+	widget := types.Dict{
+		"Type":    types.Name("Annot"),
+		"Subtype": types.Name("Widget"),
+		"FT":      types.Name("Sig"),
+		"T":       pdfTextString(uniqueFieldName(ctx, form, "Signature")),
+		"V":       *sigRef,
+		"F":       types.Integer(132), // Print | Locked
+		"P":       *pageRef,
+	}
+	geo := newPageGeometry(ctx, pageDict, inh)
+	rect, ap, err := signatureAppearance(ctx, geo, opt, name, signed)
+	if err != nil {
+		return fmt.Errorf("build signature appearance: %w", err)
+	}
+	widget["Rect"] = rect
+	widget["AP"] = types.Dict{"N": *ap}
+	// 与注释一样只在遮罩全部关闭时可见，过期或阅读器不执行 JS 时不显示在遮罩之上
+	oc, err := maskOCMD(ctx, pageDict)
+	if err != nil {
+		return err
+	}
+	if oc != nil {
+		widget["OC"] = oc
+	}
+	widgetRef, err := ctx.IndRefForNewObject(widget)
+	if err != nil {
+		return err
+	}
+
+	if err := appendRef(ctx, inc, pageDict, pageRef.ObjectNumber.Value(), "Annots", *widgetRef); err != nil {
+		return fmt.Errorf("add annotation: %w", err)
+	}
+	if err := appendRef(ctx, inc, form, formNr, "Fields", *widgetRef); err != nil {
+		return fmt.Errorf("add form field: %w", err)
+	}
+	// SigFlags: SignaturesExist | AppendOnly
+	flags := 0
+	if f, ok := form["SigFlags"].(types.Integer); ok {
+		flags = f.Value()
+	}
+	form["SigFlags"] = types.Integer(flags | 3)
+	inc.add(formNr)
+	return nil
+}
+
+// acroForm 返回文档的 AcroForm 字典及其所在对象的编号，没有时在 Root 中新建。
+func acroForm(ctx *model.Context) (types.Dict, int, error) {
+	rootNr := ctx.Root.ObjectNumber.Value()
+	if ref, ok := ctx.RootDict["AcroForm"].(types.IndirectRef); ok {
+		d, err := ctx.DereferenceDict(ref)
+		if err != nil {
+			return nil, 0, fmt.Errorf("dereference AcroForm: %w", err)
+		}
+		if d != nil {
+			return d, ref.ObjectNumber.Value(), nil
+		}
+	}
+	d, ok := ctx.RootDict["AcroForm"].(types.Dict)
+	if !ok {
+		d = types.Dict{"Fields": types.Array{}}
+		ctx.RootDict["AcroForm"] = d
+	}
+	return d, rootNr, nil
+}
+
+// appendRef 把 ref 追加到 d[key] 数组，数组为间接对象时修改该对象，并登记需要重写的对象。
+func appendRef(ctx *model.Context, inc *increment, d types.Dict, objNr int, key string, ref types.IndirectRef) error {
+	if ir, ok := d[key].(types.IndirectRef); ok {
+		// 对象流中的对象要先解码，Dereference 会把解码结果写回对象表
+		a, err := ctx.DereferenceArray(ir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		e, found := ctx.FindTableEntryLight(ir.ObjectNumber.Value())
+		if !found || e.Free {
+			return fmt.Errorf("invalid %s reference", key)
+		}
+		e.Object = append(a, ref)
+		inc.add(ir.ObjectNumber.Value())
+		return nil
+	}
+	a, _ := d[key].(types.Array)
+	d[key] = append(a, ref)
+	inc.add(objNr)
+	return nil
+}
+
+// uniqueFieldName 返回与已有顶层表单域不重名的 prefix1、prefix2……
+func uniqueFieldName(ctx *model.Context, form types.Dict, prefix string) string {
+	used := map[string]bool{}
+	if fields, err := ctx.DereferenceArray(form["Fields"]); err == nil {
+		for _, o := range fields {
+			if d, err := ctx.DereferenceDict(o); err == nil && d != nil {
+				if t, err := ctx.DereferenceStringOrHexLiteral(d["T"], model.V10, nil); err == nil {
+					used[t] = true
+				}
+			}
+		}
+	}
+	for i := 1; ; i++ {
+		if name := fmt.Sprintf("%s%d", prefix, i); !used[name] {
+			return name
+		}
+	}
+}
+
+// signatureAppearance 返回签名控件的 Rect 与外观流；不可见签名的 Rect 为 0 0 0 0，外观为空。
+// 外观按显示方向排版，Matrix 抵消页面旋转，使签名文字在阅读器中正向显示。
+func signatureAppearance(ctx *model.Context, geo *pageGeometry, opt Options, name string, signed time.Time) (types.Array, *types.IndirectRef, error) {
+	so := opt.Sign
+	if !so.Visible {
+		sd, err := ctx.NewStreamDictForBuf(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		sd.InsertName("Type", "XObject")
+		sd.InsertName("Subtype", "Form")
+		sd.Insert("BBox", types.NewRectangle(0, 0, 0, 0).Array())
+		if err := sd.Encode(); err != nil {
+			return nil, nil, err
+		}
+		ref, err := ctx.IndRefForNewObject(*sd)
+		return types.NewRectangle(0, 0, 0, 0).Array(), ref, err
+	}
+
+	box := so.Rect
+	if box == [4]float64{} {
+		w, _ := geo.formSize()
+		box = [4]float64{w - sigDefaultMargin - sigDefaultWidth, sigDefaultMargin, w - sigDefaultMargin, sigDefaultMargin + sigDefaultHeight}
+	}
+	bw, bh := math.Abs(box[2]-box[0]), math.Abs(box[3]-box[1])
+	if bw < 1 || bh < 1 {
+		return nil, nil, fmt.Errorf("signature rect too small: %v", box)
+	}
+
+	// 把显示方向的矩形映射到页面用户空间
+	m := geo.formMatrix()
+	mv := make([]float64, 6)
+	for i, o := range m {
+		mv[i] = float64(o.(types.Float))
+	}
+	x0, y0 := box[0]*mv[0]+box[1]*mv[2]+mv[4], box[0]*mv[1]+box[1]*mv[3]+mv[5]
+	x1, y1 := box[2]*mv[0]+box[3]*mv[2]+mv[4], box[2]*mv[1]+box[3]*mv[3]+mv[5]
+	rect := types.NewRectangle(math.Min(x0, x1), math.Min(y0, y1), math.Max(x0, x1), math.Max(y0, y1)).Array()
+
+	lines := []string{"数字签名：" + name, "日期：" + signed.Format("2006-01-02 15:04:05 -07:00")}
+	if r := strings.TrimSpace(so.Reason); r != "" {
+		lines = append(lines, "原因："+r)
+	}
+	if l := strings.TrimSpace(so.Location); l != "" {
+		lines = append(lines, "地点："+l)
+	}
+	text := strings.Join(lines, "\n")
+	tf := newTextFonts(ctx, text, embedFontName(opt), opt.TextLang)
+
+	size := sigFontSize
+	lineH := float64(size) * 1.2
+	maxWidth := int((bw - 2*sigPadding) * 1000 / float64(size))
+	wrapped := wrapText(tf, text, maxWidth)
+	// 行数超出框高时缩小字号
+	if h := float64(len(wrapped)) * lineH; h > bh-2*sigPadding {
+		scale := (bh - 2*sigPadding) / h
+		size = int(math.Max(4, float64(size)*scale))
+		lineH = float64(size) * 1.2
+		wrapped = wrapText(tf, text, int((bw-2*sigPadding)*1000/float64(size)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("q\n0.2 0.3 0.6 RG\n0.5 w\n")
+	sb.WriteString(fmt.Sprintf("0.25 0.25 %s %s re\nS\n0 0 0 rg\n", fmtFloat(bw-0.5), fmtFloat(bh-0.5)))
+	if err := writeLines(&sb, tf, wrapped, bh-sigPadding, float64(size), lineH, func(float64) float64 { return sigPadding }); err != nil {
+		return nil, nil, err
+	}
+	sb.WriteString("Q\n")
+
+	sd, err := ctx.NewStreamDictForBuf([]byte(sb.String()))
+	if err != nil {
+		return nil, nil, err
+	}
+	sd.InsertName("Type", "XObject")
+	sd.InsertName("Subtype", "Form")
+	sd.Insert("BBox", types.NewRectangle(0, 0, bw, bh).Array())
+	sd.Insert("Matrix", types.Array{m[0], m[1], m[2], m[3], types.Float(0), types.Float(0)})
+	sd.Insert("Resources", types.Dict{"Font": tf.dict})
+	if err := sd.Encode(); err != nil {
+		return nil, nil, err
+	}
+	ref, err := ctx.IndRefForNewObject(*sd)
+	return rect, ref, err
+}
+
+// pdfTextString 编码 PDF 文本字符串：ASCII 直接写入，其余使用带 BOM 的 UTF-16BE。
+func pdfTextString(s string) types.Object {
+	for _, r := range s {
+		if r < 0x20 || r > 0x7E {
+			return types.NewHexLiteral([]byte(types.EncodeUTF16String(s)))
+		}
+	}
+	esc, err := types.Escape(s)
+	if err != nil {
+		return types.NewHexLiteral([]byte(types.EncodeUTF16String(s)))
+	}
+	return types.StringLiteral(*esc)
+}
+
+// maskOCMD 返回页面上注释共用的 OCMD（见 annotationOC）：页面没有注释时按 Properties 资源中的遮罩 OCG 新建一个相同的。
+// 页面未经本工具处理时返回 nil。
+func maskOCMD(ctx *model.Context, pageDict types.Dict) (types.Object, error) {
+	res, err := ctx.DereferenceDict(pageDict["Resources"])
+	if err != nil || res == nil {
+		return nil, err
+	}
+	props, err := ctx.DereferenceDict(res["Properties"])
+	if err != nil || props == nil {
+		return nil, err
+	}
+	ocgs := types.Array{}
+	masks := map[int]bool{}
+	for i := 0; i < maskNum; i++ {
+		ref, ok := props[maskResName(i)].(types.IndirectRef)
+		if !ok || !isEngineOCG(ctx, ref) {
+			return nil, nil
+		}
+		ocgs = append(ocgs, ref)
+		masks[ref.ObjectNumber.Value()] = true
+	}
+
+	annots, _ := ctx.DereferenceArray(pageDict["Annots"])
+	for _, a := range annots {
+		d, err := ctx.DereferenceDict(a)
+		if err != nil || d == nil {
+			continue
+		}
+		ref, ok := d["OC"].(types.IndirectRef)
+		if !ok {
+			continue
+		}
+		if oc, err := ctx.DereferenceDict(ref); err == nil && oc != nil && isMaskOCMD(oc, masks) {
+			return ref, nil
+		}
+	}
+	ref, err := ctx.IndRefForNewObject(types.Dict{
+		"Type": types.Name("OCMD"),
+		"OCGs": ocgs,
+		"P":    types.Name("AllOff"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return *ref, nil
+}
+
+// isMaskOCMD 判断 d 是否为只由遮罩 OCG 组成的 AllOff OCMD（不含注释原有的图层条件）。
+func isMaskOCMD(d types.Dict, masks map[int]bool) bool {
+	if p := d.NameEntry("P"); p == nil || *p != "AllOff" || d["VE"] != nil {
+		return false
+	}
+	ocgs, ok := d["OCGs"].(types.Array)
+	if !ok || len(ocgs) != len(masks) {
+		return false
+	}
+	for _, o := range ocgs {
+		if ref, ok := o.(types.IndirectRef); !ok || !masks[ref.ObjectNumber.Value()] {
+			return false
+		}
+	}
+	return true
+}
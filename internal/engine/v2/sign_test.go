@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// signatureWidget 返回第一页上的签名域控件。
+func signatureWidget(t *testing.T, ctx *model.Context) types.Dict {
+	t.Helper()
+	pageDict, _, _, err := ctx.PageDict(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annots, _ := ctx.DereferenceArray(pageDict["Annots"])
+	for _, a := range annots {
+		if d, err := ctx.DereferenceDict(a); err == nil && d != nil {
+			if ft := d.NameEntry("FT"); ft != nil && *ft == "Sig" {
+				return d
+			}
+		}
+	}
+	t.Fatal("no signature widget on page 1")
+	return nil
+}
+
+func TestVisibleSignatureHiddenByMasks(t *testing.T) {
+	for _, annotated := range []bool{false, true} {
+		name := "plain"
+		if annotated {
+			name = "annotated"
+		}
+		t.Run(name, func(t *testing.T) {
+			testConfigDir(t)
+			dir := t.TempDir()
+			input := filepath.Join(dir, "in.pdf")
+			writeTestPDF(t, input, 1)
+			if annotated {
+				editTestPDF(t, input, func(ctx *model.Context) {
+					addTestAnnotations(t, ctx, types.Dict{
+						"Subtype":  types.Name("Text"),
+						"Rect":     types.NewRectangle(72, 600, 92, 620).Array(),
+						"Contents": types.StringLiteral("note"),
+					})
+				})
+			}
+			opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+			opt.Sign = SignOptions{Enabled: true, Visible: true, Reason: "test"}
+			opt.Sign.CertPath, opt.Sign.KeyPath = writeTestSigner(t, dir)
+			if err := Run(opt); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			ctx := readTestPDF(t, opt.Output, "")
+			widget := signatureWidget(t, ctx)
+			ref, ok := widget["OC"].(types.IndirectRef)
+			if !ok {
+				t.Fatalf("signature widget has no /OC: %v", widget)
+			}
+			oc, err := ctx.DereferenceDict(ref)
+			if err != nil || oc == nil {
+				t.Fatalf("resolve /OC: %v", err)
+			}
+			ocgs, _ := oc["OCGs"].(types.Array)
+			if p := oc.NameEntry("P"); p == nil || *p != "AllOff" || len(ocgs) != maskNum {
+				t.Fatalf("signature /OC = %v, want an AllOff OCMD over the masks", oc)
+			}
+			for _, g := range ocgs {
+				if !isEngineOCG(ctx, g) {
+					t.Fatalf("OCMD member %v is not an engine OCG", g)
+				}
+			}
+			if !annotated {
+				return
+			}
+			// 页面已有注释时沿用注释的 OCMD
+			pageDict, _, _, _ := ctx.PageDict(1, false)
+			annots, _ := ctx.DereferenceArray(pageDict["Annots"])
+			note, _ := ctx.DereferenceDict(annots[0])
+			if got, _ := note["OC"].(types.IndirectRef); got.ObjectNumber != ref.ObjectNumber {
+				t.Fatalf("signature uses OCMD %v, annotations use %v", ref, note["OC"])
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+
+	"github.com/cg917658910/win-pdf/internal/logging"
+)
+
+// 已签名输入的处理策略（Options.SignedInputPolicy）
+const (
+	// SignedInputWarn 提示后照常处理，原有签名失效（默认）
+	SignedInputWarn = "warn"
+	// SignedInputRefuse 拒绝处理已签名的文档
+	SignedInputRefuse = "refuse"
+	// SignedInputIncremental 把保护作为新修订追加在原文件之后，原签名覆盖的修订保持可验证
+	SignedInputIncremental = "incremental"
+)
+
+// signatureInfo 描述输入文档中的签名。
+type signatureInfo struct {
+	signed bool
+	// docMDP 为认证签名允许的修改级别（1 禁止修改，2 允许填表与签名，3 另允许注释），0 表示不是认证签名
+	docMDP int
+}
+
+// inspectSignatures 检查文档是否已签名：存在已签名的签名域、SigFlags 标记了签名，或有认证签名（Perms）。
+func inspectSignatures(ctx *model.Context) signatureInfo {
+	var info signatureInfo
+	root := ctx.RootDict
+	if form, err := ctx.DereferenceDict(root["AcroForm"]); err == nil && form != nil {
+		if f := form.IntEntry("SigFlags"); f != nil && *f&1 != 0 {
+			info.signed = true
+		}
+		if fields, err := ctx.DereferenceArray(form["Fields"]); err == nil && hasSignedField(ctx, fields, 0) {
+			info.signed = true
+		}
+	}
+	if perms, err := ctx.DereferenceDict(root["Perms"]); err == nil && perms != nil {
+		info.signed = true
+		if _, ok := perms["DocMDP"]; ok {
+			info.docMDP = docMDPLevel(ctx, perms["DocMDP"])
+		}
+	}
+	return info
+}
+
+// hasSignedField 递归查找已签名的签名域（FT 为 Sig 且有 V）。
+func hasSignedField(ctx *model.Context, fields types.Array, depth int) bool {
+	if depth > 32 {
+		return false
+	}
+	for _, f := range fields {
+		d, err := ctx.DereferenceDict(f)
+		if err != nil || d == nil {
+			continue
+		}
+		if ft := d.NameEntry("FT"); ft != nil && *ft == "Sig" && d["V"] != nil {
+			return true
+		}
+		if kids, err := ctx.DereferenceArray(d["Kids"]); err == nil && hasSignedField(ctx, kids, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// docMDPLevel 读取认证签名 Reference 中 DocMDP 变换的 P 参数，缺省为 2。
+func docMDPLevel(ctx *model.Context, o types.Object) int {
+	sig, err := ctx.DereferenceDict(o)
+	if err != nil || sig == nil {
+		return 2
+	}
+	refs, err := ctx.DereferenceArray(sig["Reference"])
+	if err != nil {
+		return 2
+	}
+	for _, r := range refs {
+		d, err := ctx.DereferenceDict(r)
+		if err != nil || d == nil {
+			continue
+		}
+		if m := d.NameEntry("TransformMethod"); m == nil || *m != "DocMDP" {
+			continue
+		}
+		params, err := ctx.DereferenceDict(d["TransformParams"])
+		if err != nil || params == nil {
+			break
+		}
+		if p := params.IntEntry("P"); p != nil && *p >= 1 && *p <= 3 {
+			return *p
+		}
+		break
+	}
+	return 2
+}
+
+// applySignedInputPolicy 按策略处理已签名的输入，返回是否以增量更新方式写出。
+func applySignedInputPolicy(ctx *model.Context, opt *Options) (bool, error) {
+	info := inspectSignatures(ctx)
+	if !info.signed {
+		return false, nil
+	}
+	switch {
+	case opt.SignedInputPolicy == SignedInputRefuse:
+		return false, fmt.Errorf("此文档包含数字签名，处理后签名将失效，已按设置拒绝处理")
+	case opt.SignedInputPolicy == SignedInputIncremental, opt.OutputMode == OutputIncremental:
+	default:
+		logging.Warn("input is digitally signed; processing will invalidate its signatures", "input", opt.Input)
+		return false, nil
+	}
+
+	// 展平会删除签名域，增量更新时跳过
+	logging.Info("input is digitally signed; appending the protection as a new revision", "input", opt.Input)
+	if opt.FlattenAnnotations {
+		logging.Info("annotation flattening is skipped in incremental mode to keep the signature fields", "input", opt.Input)
+		opt.FlattenAnnotations = false
+	}
+	if info.docMDP == 1 {
+		logging.Warn("document is certified with no changes permitted; readers will report the new revision as a violation", "input", opt.Input)
+	} else {
+		logging.Info("readers will report the new revision as changes made after signing", "input", opt.Input)
+	}
+	return true, nil
+}
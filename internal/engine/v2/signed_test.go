@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSignedInputPolicy(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.pdf")
+	writeTestPDF(t, plain, 2)
+	// 已签名的输入：本工具签名后的输出
+	signed := filepath.Join(dir, "signed.pdf")
+	opt := testOptions(plain, signed)
+	opt.Sign = SignOptions{Enabled: true, Reason: "test"}
+	opt.Sign.CertPath, opt.Sign.KeyPath = writeTestSigner(t, dir)
+	if err := Run(opt); err != nil {
+		t.Fatalf("sign input: %v", err)
+	}
+	if !inspectSignatures(readTestPDF(t, signed, "")).signed {
+		t.Fatal("signed input not detected")
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		policy      string
+		incremental bool // 原文件字节（含原签名覆盖的范围）是输出的前缀
+	}{
+		{"warn", signed, SignedInputWarn, false},
+		{"incremental", signed, SignedInputIncremental, true},
+		{"unsigned", plain, SignedInputIncremental, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, _ := os.ReadFile(tt.input)
+			opt := testOptions(tt.input, filepath.Join(dir, tt.name+".pdf"))
+			opt.SignedInputPolicy = tt.policy
+			opt.FlattenAnnotations = true
+			if err := Run(opt); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			out, err := os.ReadFile(opt.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.HasPrefix(out, in) != tt.incremental {
+				t.Fatalf("original revision kept = %v, want %v", !tt.incremental, tt.incremental)
+			}
+			ctx := readTestPDF(t, opt.Output, "")
+			if ctx.PageCount != 2 {
+				t.Fatalf("page count = %d", ctx.PageCount)
+			}
+			// 增量更新跳过展平，签名域保留；重写时展平删除签名域
+			if got := inspectSignatures(ctx).signed; got != tt.incremental {
+				t.Fatalf("signature field kept = %v, want %v", got, tt.incremental)
+			}
+		})
+	}
+
+	t.Run("refuse", func(t *testing.T) {
+		opt := testOptions(signed, filepath.Join(dir, "refuse.pdf"))
+		opt.SignedInputPolicy = SignedInputRefuse
+		if err := Run(opt); err == nil || !strings.Contains(err.Error(), "数字签名") {
+			t.Fatalf("Run = %v", err)
+		}
+		if _, err := os.Stat(opt.Output); !os.IsNotExist(err) {
+			t.Fatal("output written for a refused input")
+		}
+		if r := Preflight(signed, opt); r.CanProcess || !r.Signed {
+			t.Fatalf("preflight %+v", r)
+		}
+	})
+}
@@ -0,0 +1,463 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+const vaultFileName = "owner-passwords.vault"
+
+// OwnerPasswordEntry 是所有者密码库中的一条记录，按输出文件的 SHA-256 与文档 ID 查找。
+// DocumentID 为 trailer 中 ID 的第一项（沿用输入的永久 ID），InstanceID 为第二项（每次写出都不同）。
+type OwnerPasswordEntry struct {
+	DocumentID    string    `json:"document_id"`
+	InstanceID    string    `json:"instance_id"`
+	OutputSHA256  string    `json:"output_sha256"`
+	File          string    `json:"file"`
+	OwnerPassword string    `json:"owner_password"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// VaultPassphraseEnv 是密码库口令的环境变量。设置后新建的密码库用口令保护密钥，可在其他电脑上打开；
+// 未设置时在 Windows 上用 DPAPI 按当前用户保护。
+const VaultPassphraseEnv = "WIN_PDF_VAULT_PASSPHRASE"
+
+// 密码库密钥的保护方式（vaultHeader.Protection）
+const (
+	vaultProtectionDPAPI      = "dpapi"
+	vaultProtectionPassphrase = "passphrase"
+	vaultKDFIterations        = 600000
+	vaultVersion              = 2
+)
+
+var errVaultNoOSProtection = errors.New("本系统不支持 DPAPI，请设置环境变量 " + VaultPassphraseEnv + " 作为密码库口令")
+
+// 密码库为 JSON Lines：第一行是 vaultHeader，其后每行是一条用 AES-256-GCM 单独加密的记录，新记录直接追加。
+// 密钥随机生成，只以受保护的形式（DPAPI 或由口令派生的密钥加密）保存在文件头中。
+type vaultHeader struct {
+	Version    int    `json:"version"`
+	Protection string `json:"protection"`
+	Salt       string `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Key        string `json:"key"`
+}
+
+type vaultRecord struct {
+	Nonce string `json:"nonce"`
+	Data  string `json:"data"`
+}
+
+// 批量处理时多个 worker 同时写入密码库；vaultKeys 缓存已解开的密钥（按文件头中受保护的密钥），避免重复派生
+var (
+	vaultMu   sync.Mutex
+	vaultKeys = map[string][]byte{}
+)
+
+// newOwnerPassword 生成随机的所有者密码（24 个 URL 安全字符，AES-128 的 32 字节上限内）。
+func newOwnerPassword() (string, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// vaultPath 返回密码库路径，与 activation.json 位于同一配置目录。
+func vaultPath() (string, error) {
+	d, err := license.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(d, vaultFileName), nil
+}
+
+// newVaultHeader 生成新的密码库密钥及保存它的文件头：设置了口令时用口令保护，否则用 DPAPI。
+func newVaultHeader() (vaultHeader, []byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return vaultHeader{}, nil, err
+	}
+	h := vaultHeader{Version: vaultVersion}
+	var wrapped []byte
+	if pass := os.Getenv(VaultPassphraseEnv); pass != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return vaultHeader{}, nil, err
+		}
+		h.Protection, h.Salt, h.Iterations = vaultProtectionPassphrase, base64.StdEncoding.EncodeToString(salt), vaultKDFIterations
+		aead, err := passphraseCipher(pass, salt, h.Iterations)
+		if err != nil {
+			return vaultHeader{}, nil, err
+		}
+		if wrapped, err = sealBytes(aead, key); err != nil {
+			return vaultHeader{}, nil, err
+		}
+	} else {
+		var err error
+		if wrapped, err = protectVaultKey(key); err != nil {
+			return vaultHeader{}, nil, err
+		}
+		h.Protection = vaultProtectionDPAPI
+	}
+	h.Key = base64.StdEncoding.EncodeToString(wrapped)
+	vaultKeys[h.Key] = key
+	return h, key, nil
+}
+
+// vaultKey 解开文件头中的密码库密钥。
+func vaultKey(h vaultHeader) ([]byte, error) {
+	if key, ok := vaultKeys[h.Key]; ok {
+		return key, nil
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(h.Key)
+	if err != nil {
+		return nil, fmt.Errorf("parse vault: %w", err)
+	}
+	var key []byte
+	switch h.Protection {
+	case vaultProtectionDPAPI:
+		if key, err = unprotectVaultKey(wrapped); err != nil {
+			return nil, fmt.Errorf("unlock vault (created by another user or machine?): %w", err)
+		}
+	case vaultProtectionPassphrase:
+		pass := os.Getenv(VaultPassphraseEnv)
+		if pass == "" {
+			return nil, fmt.Errorf("密码库使用口令保护，请设置环境变量 %s", VaultPassphraseEnv)
+		}
+		salt, err := base64.StdEncoding.DecodeString(h.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("parse vault: %w", err)
+		}
+		aead, err := passphraseCipher(pass, salt, h.Iterations)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = openSealed(aead, wrapped); err != nil {
+			return nil, fmt.Errorf("密码库口令不正确：%w", err)
+		}
+	default:
+		return nil, fmt.Errorf("parse vault: unknown key protection %q", h.Protection)
+	}
+	vaultKeys[h.Key] = key
+	return key, nil
+}
+
+func passphraseCipher(pass string, salt []byte, iterations int) (cipher.AEAD, error) {
+	kek, err := pbkdf2.Key(sha256.New, pass, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	return newGCM(kek)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealBytes 加密 plain，返回 nonce 与密文连在一起的结果。
+func sealBytes(aead cipher.AEAD, plain []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func openSealed(aead cipher.AEAD, b []byte) ([]byte, error) {
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+}
+
+func sealRecord(aead cipher.AEAD, e OwnerPasswordEntry) ([]byte, error) {
+	plain, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(vaultRecord{
+		Nonce: base64.StdEncoding.EncodeToString(nonce),
+		Data:  base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plain, nil)),
+	})
+	return append(b, '\n'), err
+}
+
+// loadVault 读取并解密全部记录，密码库不存在时返回空列表。调用方需持有 vaultMu。
+// 无法解析的行（例如写入中断留下的半行）会被跳过。
+func loadVault() ([]OwnerPasswordEntry, error) {
+	p, err := vaultPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lines := bytes.Split(b, []byte("\n"))
+	var h vaultHeader
+	if err := json.Unmarshal(lines[0], &h); err != nil || h.Version != vaultVersion {
+		return nil, fmt.Errorf("parse vault: invalid header")
+	}
+	key, err := vaultKey(h)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	var entries []OwnerPasswordEntry
+	for _, line := range lines[1:] {
+		var r vaultRecord
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &r) != nil {
+			continue
+		}
+		nonce, err1 := base64.StdEncoding.DecodeString(r.Nonce)
+		data, err2 := base64.StdEncoding.DecodeString(r.Data)
+		if err1 != nil || err2 != nil || len(nonce) != aead.NonceSize() {
+			continue
+		}
+		plain, err := aead.Open(nil, nonce, data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt vault: %w", err)
+		}
+		var e OwnerPasswordEntry
+		if err := json.Unmarshal(plain, &e); err != nil {
+			return nil, fmt.Errorf("parse vault: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// appendVault 把一条记录追加到密码库末尾，密码库不存在时新建。调用方需持有 vaultMu。
+func appendVault(e OwnerPasswordEntry) error {
+	p, err := vaultPath()
+	if err != nil {
+		return err
+	}
+	var key []byte
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	switch {
+	case err == nil:
+		var h vaultHeader
+		if h, key, err = newVaultHeader(); err == nil {
+			var b []byte
+			if b, err = json.Marshal(h); err == nil {
+				_, err = f.Write(append(b, '\n'))
+			}
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(p)
+			return err
+		}
+	case errors.Is(err, os.ErrExist):
+		if key, err = existingVaultKey(p); err != nil {
+			return err
+		}
+		if f, err = os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0o600); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	aead, err := newGCM(key)
+	if err == nil {
+		var rec []byte
+		if rec, err = sealRecord(aead, e); err == nil {
+			_, err = f.Write(rec)
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// existingVaultKey 读取已有密码库文件头中的密钥。
+func existingVaultKey(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	f.Close()
+	var h vaultHeader
+	if err != nil || json.Unmarshal(line, &h) != nil || h.Version != vaultVersion {
+		return nil, fmt.Errorf("parse vault: invalid header")
+	}
+	return vaultKey(h)
+}
+
+// recordOwnerPassword 把写出的 path 使用的所有者密码追加到密码库。id 为写出时 trailer 中的 ID。
+// 本系统没有可用的密钥保护（非 Windows 且未设置口令）时不保存，只记录警告，输出照常保留。
+func recordOwnerPassword(path, ownerPW string, id types.Array) error {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return err
+	}
+	e := OwnerPasswordEntry{
+		DocumentID:    idString(id, 0),
+		InstanceID:    idString(id, 1),
+		OutputSHA256:  sum,
+		File:          path,
+		OwnerPassword: ownerPW,
+		CreatedAt:     time.Now(),
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		e.File = abs
+	}
+
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	err = appendVault(e)
+	if errors.Is(err, errVaultNoOSProtection) {
+		logging.Warn("owner password not recorded: no vault key protection", "file", e.File, "env", VaultPassphraseEnv)
+		return nil
+	}
+	return err
+}
+
+// LookupOwnerPassword 查找 path 的所有者密码：先按文件的 SHA-256 精确匹配，
+// 文件在本工具之外被改动过（例如另行签名的增量更新）时再按文档 ID 的第二项查找。
+// 同一输入的多份输出共用第一项，不能据此区分。
+func LookupOwnerPassword(path string) (*OwnerPasswordEntry, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	vaultMu.Lock()
+	entries, err := loadVault()
+	vaultMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].OutputSHA256 == sum {
+			return &entries[i], nil
+		}
+	}
+	if _, id := fileIDs(path); id != "" {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].InstanceID == id {
+				return &entries[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("密码库中没有 %s 的所有者密码", filepath.Base(path))
+}
+
+// ExportOwnerPasswords 返回密码库中的全部记录，供管理员备份或迁移。
+func ExportOwnerPasswords() ([]OwnerPasswordEntry, error) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	return loadVault()
+}
+
+// vaultOwnerPassword 供打开输入时识别本工具的输出，查找失败时忽略。
+func vaultOwnerPassword(path string) (string, bool) {
+	e, err := LookupOwnerPassword(path)
+	if err != nil {
+		return "", false
+	}
+	return e.OwnerPassword, true
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idString 返回 ID 第 i 项的小写十六进制形式。
+func idString(id types.Array, i int) string {
+	if len(id) <= i {
+		return ""
+	}
+	switch v := id[i].(type) {
+	case types.HexLiteral:
+		return strings.ToLower(v.Value())
+	case types.StringLiteral:
+		b, err := types.Unescape(v.Value())
+		if err != nil {
+			return ""
+		}
+		return hex.EncodeToString(b)
+	}
+	return ""
+}
+
+var trailerIDRe = regexp.MustCompile(`/ID\s*\[\s*<([0-9A-Fa-f]*)>\s*<([0-9A-Fa-f]+)>`)
+
+// fileIDs 不解密文档，直接从文件末尾的 trailer（或交叉引用流字典）中读取 ID 的两项（小写十六进制）。
+func fileIDs(path string) (documentID, instanceID string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", ""
+	}
+	const tailSize = 64 << 10
+	off := fi.Size() - tailSize
+	if off < 0 {
+		off = 0
+	}
+	tail := make([]byte, fi.Size()-off)
+	if _, err := f.ReadAt(tail, off); err != nil && err != io.EOF {
+		return "", ""
+	}
+	// 增量更新后以最后一个 trailer 为准
+	if i := bytes.LastIndex(tail, []byte("/ID")); i >= 0 {
+		if m := trailerIDRe.FindSubmatch(tail[i:]); m != nil {
+			return strings.ToLower(string(m[1])), strings.ToLower(string(m[2]))
+		}
+	}
+	return "", ""
+}
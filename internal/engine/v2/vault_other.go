@@ -0,0 +1,13 @@
+//go:build !windows
+
+package engine
+
+// 其他系统没有 DPAPI，密码库需要用口令保护（见 VaultPassphraseEnv），未设置口令时不保存所有者密码。
+
+func protectVaultKey([]byte) ([]byte, error) {
+	return nil, errVaultNoOSProtection
+}
+
+func unprotectVaultKey([]byte) ([]byte, error) {
+	return nil, errVaultNoOSProtection
+}
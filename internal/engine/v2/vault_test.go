@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+func TestVaultAppendAndLookup(t *testing.T) {
+	testConfigDir(t)
+	dir := t.TempDir()
+	var files []string
+	for i, pw := range []string{"owner-one", "owner-two"} {
+		f := filepath.Join(dir, "out"+string(rune('a'+i))+".pdf")
+		if err := os.WriteFile(f, []byte(pw+" content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		id := types.Array{types.HexLiteral("aa"), types.HexLiteral("b" + string(rune('0'+i)))}
+		if err := recordOwnerPassword(f, pw, id); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	p, _ := vaultPath()
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := license.GetMachineCode()
+	for _, secret := range []string{"owner-one", "owner-two", code} {
+		if secret != "" && strings.Contains(string(raw), secret) {
+			t.Fatalf("vault contains %q in plain text", secret)
+		}
+	}
+	sc := bufio.NewScanner(strings.NewReader(string(raw)))
+	lines := 0
+	var h vaultHeader
+	for sc.Scan() {
+		if lines == 0 {
+			json.Unmarshal(sc.Bytes(), &h)
+		}
+		lines++
+	}
+	if lines != 3 || h.Protection != vaultProtectionPassphrase {
+		t.Fatalf("vault has %d lines, protection %q; want a header and one line per entry", lines, h.Protection)
+	}
+
+	e, err := LookupOwnerPassword(files[1])
+	if err != nil || e.OwnerPassword != "owner-two" || e.InstanceID != "b1" {
+		t.Fatalf("lookup = %+v, %v", e, err)
+	}
+
+	// 换一个口令后无法解开（清掉已解开密钥的缓存）
+	vaultMu.Lock()
+	clear(vaultKeys)
+	vaultMu.Unlock()
+	t.Setenv(VaultPassphraseEnv, "wrong")
+	if _, err := LookupOwnerPassword(files[0]); err == nil {
+		t.Fatal("vault opened with a wrong passphrase")
+	}
+}
+
+func TestRunWithoutVaultProtection(t *testing.T) {
+	testConfigDir(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("DPAPI protects the vault on Windows")
+	}
+	t.Setenv(VaultPassphraseEnv, "")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.pdf")
+	writeTestPDF(t, input, 1)
+	opt := testOptions(input, filepath.Join(dir, "out.pdf"))
+	opt.PwdEnabled, opt.UserPassword = true, "user-pw"
+	if err := Run(opt); err != nil {
+		t.Fatalf("Run without vault protection: %v", err)
+	}
+	// 输出照常保留，密码库不保存记录
+	if ctx := readTestPDF(t, opt.Output, "user-pw"); ctx.PageCount != 1 {
+		t.Fatalf("page count = %d", ctx.PageCount)
+	}
+	p, _ := vaultPath()
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Fatalf("vault created without key protection: %v", err)
+	}
+	if _, err := LookupOwnerPassword(opt.Output); err == nil {
+		t.Fatal("owner password found without a vault")
+	}
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package engine
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// DPAPI 的附加熵，其他程序以同一用户身份调用 DPAPI 也需要它才能解开密钥
+var vaultEntropy = []byte("win-pdf owner password vault")
+
+// protectVaultKey 用 DPAPI 以当前 Windows 用户的凭据加密密码库密钥，只有同一用户在本机才能解开。
+func protectVaultKey(key []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(dataBlob(key), nil, dataBlob(vaultEntropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, fmt.Errorf("CryptProtectData: %w", err)
+	}
+	return blobBytes(&out), nil
+}
+
+// unprotectVaultKey 用 DPAPI 解开 protectVaultKey 加密的密钥。
+func unprotectVaultKey(b []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(dataBlob(b), nil, dataBlob(vaultEntropy), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, fmt.Errorf("CryptUnprotectData: %w", err)
+	}
+	return blobBytes(&out), nil
+}
+
+func dataBlob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// blobBytes 复制 DPAPI 分配的输出并释放它。
+func blobBytes(b *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return append([]byte(nil), unsafe.Slice(b.Data, b.Size)...)
+}
@@ -12,6 +12,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/cg917658910/win-pdf/internal/logging"
 )
 
 const appName = "win-pdf"
@@ -216,7 +218,8 @@ func IsActivated() (bool, *ActivationInfo, error) {
 	}
 
 	if ai.MachineCode != mc {
-		fmt.Printf("Machine code mismatch: expected %s, got %s\n", mc, ai.MachineCode)
+		// 机器码可用于生成注册码，不写入日志（日志会随诊断包发出）
+		logging.Warn("activation machine code mismatch")
 		return false, nil, nil
 	}
 
@@ -6,8 +6,8 @@ import (
 	"path/filepath"
 )
 
-// configDir 返回用于存放应用配置的目录（优先 UserConfigDir，回退到 ~/.config）
-func configDir() (string, error) {
+// ConfigDir 返回用于存放应用配置的目录（优先 UserConfigDir，回退到 ~/.config）
+func ConfigDir() (string, error) {
 	ud, err := os.UserConfigDir()
 	if err != nil {
 		hd, hErr := os.UserHomeDir()
@@ -21,7 +21,7 @@ func configDir() (string, error) {
 
 // activationFilePath 返回 activation.json 的绝对路径并确保目录存在
 func activationFilePath() (string, error) {
-	d, err := configDir()
+	d, err := ConfigDir()
 	if err != nil {
 		return "", err
 	}
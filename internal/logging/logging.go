@@ -0,0 +1,250 @@
+// Package logging 是 engine/v2、license 与界面共用的分级结构化日志。
+// Init 之前只输出到标准错误；Init 之后同时以 JSON Lines 写入配置目录下按大小轮转的日志文件。
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fileName = "win-pdf.log"
+	// 单个日志文件的大小上限与保留的历史文件数
+	maxFileSize = 5 << 20
+	maxBackups  = 5
+	// 轮转失败后至少间隔这么久再重试，避免每次写入都关闭、重开文件
+	rotateRetry = time.Minute
+)
+
+var (
+	logger atomic.Pointer[slog.Logger]
+	level  = new(slog.LevelVar)
+
+	mu     sync.Mutex
+	logDir string
+	file   *rotatingFile
+)
+
+func init() {
+	logger.Store(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+}
+
+// Init 在 dir 下打开日志文件，之后的日志同时写入文件与标准错误。重复调用时切换到新的目录。
+func Init(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	rf, err := openRotating(filepath.Join(dir, fileName), maxFileSize, maxBackups)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+	}
+	file, logDir = rf, dir
+	opts := &slog.HandlerOptions{Level: level}
+	logger.Store(slog.New(fanout{
+		slog.NewTextHandler(os.Stderr, opts),
+		slog.NewJSONHandler(rf, opts),
+	}))
+	return nil
+}
+
+// Close 关闭日志文件，之后只输出到标准错误。
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	logger.Store(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file, logDir = nil, ""
+	return err
+}
+
+// SetLevel 设置输出的最低级别，默认为 Info。
+func SetLevel(l slog.Level) { level.Set(l) }
+
+// ParseLevel 解析 debug、info、warn、error（不区分大小写）。
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return l, fmt.Errorf("无效的日志级别 %q，应为 debug、info、warn 或 error", s)
+	}
+	return l, nil
+}
+
+// L 返回当前的日志记录器。
+func L() *slog.Logger { return logger.Load() }
+
+func Debug(msg string, args ...any) { L().Debug(msg, args...) }
+func Info(msg string, args ...any)  { L().Info(msg, args...) }
+func Warn(msg string, args ...any)  { L().Warn(msg, args...) }
+func Error(msg string, args ...any) { L().Error(msg, args...) }
+
+// Files 返回日志文件，当前文件在前、历史文件按从新到旧排列；未调用 Init 时返回空列表。
+func Files() ([]string, error) {
+	mu.Lock()
+	dir := logDir
+	mu.Unlock()
+	if dir == "" {
+		return nil, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, fileName+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches) // win-pdf.log 排在 win-pdf.log.1 之前
+	return matches, nil
+}
+
+// Sync 把缓冲的日志写入磁盘，导出日志前调用。
+func Sync() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return nil
+	}
+	return file.Sync()
+}
+
+// fanout 把每条日志交给全部处理器。
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, l slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, l) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// rotatingFile 在文件超过 max 字节时依次改名为 .1、.2 …，最多保留 backups 个历史文件。
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	max     int64
+	backups int
+	f       *os.File
+	size    int64
+	// retryAt 之前不再尝试轮转
+	retryAt time.Time
+}
+
+var _ io.WriteCloser = (*rotatingFile)(nil)
+
+func openRotating(path string, max int64, backups int) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, max: max, backups: backups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) rotate() error {
+	err := rf.f.Close()
+	rf.f = nil
+	if err == nil {
+		os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.backups))
+		for i := rf.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		err = os.Rename(rf.path, rf.path+".1")
+	}
+	// 改名失败（例如文件被其他程序占用）时继续写入当前文件，稍后再重试
+	if err != nil {
+		rf.retryAt = time.Now().Add(rotateRetry)
+	}
+	return errors.Join(err, rf.open())
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.max && !time.Now().Before(rf.retryAt) {
+		if err := rf.rotate(); rf.f == nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) Sync() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	return rf.f.Sync()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
@@ -0,0 +1,186 @@
+package tsa
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/cms"
+)
+
+var (
+	// 本地测试 TSA 的策略，仅用于开发与内网环境
+	localPolicy       = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+	oidKPTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+	oidExtKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+const (
+	localCertLifetime  = 10 * 365 * 24 * time.Hour
+	localCommonName    = "win-pdf Local Test TSA"
+	localSerialBits    = 64
+	localAccuracySecs  = 1
+	localMaxRequestLen = 64 << 10
+)
+
+// Local 是进程内的 RFC 3161 TSA，可直接调用 Timestamp，也可作为 HTTP 服务（application/timestamp-query）。
+type Local struct {
+	signer cms.Signer
+	mu     sync.Mutex
+	last   time.Time
+}
+
+// NewLocal 使用 signer 签发时间戳，签名证书应带有关键的 timeStamping 扩展用途。
+func NewLocal(signer cms.Signer) *Local {
+	return &Local{signer: signer}
+}
+
+// NewTestLocal 生成一次性的自签名 P-256 证书并返回使用它的 TSA，证书只在当前进程内有效。
+func NewTestLocal() (*Local, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), localSerialBits))
+	if err != nil {
+		return nil, err
+	}
+	// RFC 3161 2.3 要求 ExtendedKeyUsage 只含 timeStamping 且为关键扩展
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{oidKPTimeStamping})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:    serial,
+		Subject:         pkix.Name{CommonName: localCommonName},
+		NotBefore:       now.Add(-time.Hour),
+		NotAfter:        now.Add(localCertLifetime),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: eku}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("create TSA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return NewLocal(cms.Signer{Key: key, Certificate: cert}), nil
+}
+
+// Certificate 返回 TSA 签名证书，验证方需要信任它。
+func (l *Local) Certificate() *x509.Certificate {
+	return l.signer.Certificate
+}
+
+// Timestamp 直接签发 digest 的时间戳令牌。
+func (l *Local) Timestamp(digest []byte) ([]byte, error) {
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("tsa: invalid sha-256 digest length %d", len(digest))
+	}
+	return l.issue(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDSHA256}, HashedMessage: digest},
+	})
+}
+
+// Respond 处理一个 DER 编码的 TimeStampReq，返回 TimeStampResp；请求无效时返回拒绝状态而不是错误。
+func (l *Local) Respond(req []byte) ([]byte, error) {
+	var r timeStampReq
+	if rest, err := asn1.Unmarshal(req, &r); err != nil || len(rest) > 0 || r.Version != 1 {
+		return reject(failBadDataFormat, "malformed request")
+	}
+	if !r.MessageImprint.HashAlgorithm.Algorithm.Equal(cms.OIDSHA256) {
+		return reject(failBadAlg, "only SHA-256 is supported")
+	}
+	if len(r.MessageImprint.HashedMessage) != sha256.Size {
+		return reject(failBadDataFormat, "invalid digest length")
+	}
+	if len(r.ReqPolicy) > 0 && !r.ReqPolicy.Equal(localPolicy) {
+		return reject(failBadRequest, "unsupported policy")
+	}
+	token, err := l.issue(r)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// ServeHTTP 实现 RFC 3161 第 3.4 节的 HTTP 传输。
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, localMaxRequestLen))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := l.Respond(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeReply)
+	w.Write(resp)
+}
+
+func (l *Local) issue(r timeStampReq) ([]byte, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), localSerialBits))
+	if err != nil {
+		return nil, err
+	}
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         localPolicy,
+		MessageImprint: r.MessageImprint,
+		SerialNumber:   serial,
+		GenTime:        l.now(),
+		Accuracy:       accuracy{Seconds: localAccuracySecs},
+		Nonce:          r.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tsa: encode TSTInfo: %w", err)
+	}
+	sd, err := cms.SignContent(l.signer, cms.OIDTSTInfo, info)
+	if err != nil {
+		return nil, err
+	}
+	return sd.Bytes()
+}
+
+// now 返回精确到秒的 UTC 时间，保证同一 TSA 签发的时间单调不减。
+func (l *Local) now() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := time.Now().UTC().Truncate(time.Second)
+	if t.Before(l.last) {
+		t = l.last
+	}
+	l.last = t
+	return t
+}
+
+func reject(failBit int, msg string) ([]byte, error) {
+	fail := asn1.BitString{Bytes: make([]byte, failBit/8+1), BitLength: failBit + 1}
+	fail.Bytes[failBit/8] |= 0x80 >> uint(failBit%8)
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{Status: statusRejection, StatusString: freeText(msg), FailInfo: fail},
+	})
+}
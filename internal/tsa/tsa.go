@@ -0,0 +1,224 @@
+// Package tsa 实现 RFC 3161 时间戳协议：向 TSA 请求时间戳令牌，以及一个本地测试 TSA。
+//
+// 摘要算法固定为 SHA-256，与 cms 包一致。
+package tsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/cms"
+)
+
+// Timestamper 对摘要签发时间戳令牌，返回 DER 编码的 ContentInfo（SignedData，内容为 TSTInfo）。
+type Timestamper interface {
+	Timestamp(digest []byte) ([]byte, error)
+}
+
+const (
+	contentTypeQuery = "application/timestamp-query"
+	contentTypeReply = "application/timestamp-reply"
+	defaultTimeout   = 30 * time.Second
+	maxReplySize     = 1 << 20
+)
+
+// PKIStatus 取值（RFC 3161 2.4.2）
+const (
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+	statusRejection       = 2
+)
+
+// PKIFailureInfo 位（RFC 3161 2.4.2）
+const (
+	failBadAlg        = 0
+	failBadRequest    = 2
+	failBadDataFormat = 5
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     asn1.RawValue         `asn1:"optional,tag:0"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString asn1.RawValue  `asn1:"optional"` // PKIFreeText：SEQUENCE OF UTF8String
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// 解析令牌只需要到 encapContentInfo 为止，其后的证书与签名者信息不解析
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContent     struct {
+		ContentType asn1.ObjectIdentifier
+		Content     []byte `asn1:"explicit,optional,tag:0"`
+	}
+}
+
+// Client 通过 HTTP 向 TSA 请求时间戳，URL 中的用户名与密码按 Basic 认证发送。
+type Client struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// Timestamp 请求 digest（SHA-256）的时间戳令牌，并核对令牌中的摘要与随机数。
+func (c *Client) Timestamp(digest []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: cms.OIDSHA256}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("tsa: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", contentTypeQuery)
+	httpReq.Header.Set("Accept", contentTypeReply)
+	resp, err := (&http.Client{Timeout: timeout}).Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("tsa: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxReplySize))
+	if err != nil {
+		return nil, fmt.Errorf("tsa: read reply: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tsa: %s", resp.Status)
+	}
+	return parseReply(body, digest, nonce)
+}
+
+// parseReply 检查 TimeStampResp 的状态并返回其中的令牌。
+func parseReply(body, digest []byte, nonce *big.Int) ([]byte, error) {
+	var r timeStampResp
+	if _, err := asn1.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("tsa: parse reply: %w", err)
+	}
+	if s := r.Status.Status; s != statusGranted && s != statusGrantedWithMods {
+		msg := strings.Join(parseFreeText(r.Status.StatusString), "; ")
+		return nil, fmt.Errorf("tsa: request rejected (status %d, failInfo %x): %s", s, r.Status.FailInfo.Bytes, msg)
+	}
+	if len(r.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("tsa: reply has no token")
+	}
+	info, err := parseToken(r.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest) {
+		return nil, errors.New("tsa: token digest does not match request")
+	}
+	if nonce != nil && (info.Nonce == nil || info.Nonce.Cmp(nonce) != 0) {
+		return nil, errors.New("tsa: token nonce does not match request")
+	}
+	return r.TimeStampToken.FullBytes, nil
+}
+
+// parseToken 取出令牌中的 TSTInfo。
+func parseToken(token []byte) (*tstInfo, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return nil, fmt.Errorf("tsa: parse token: %w", err)
+	}
+	if !ci.ContentType.Equal(cms.OIDSignedData) {
+		return nil, fmt.Errorf("tsa: unexpected token content type %s", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("tsa: parse token: %w", err)
+	}
+	if !sd.EncapContent.ContentType.Equal(cms.OIDTSTInfo) {
+		return nil, fmt.Errorf("tsa: unexpected encapsulated content type %s", sd.EncapContent.ContentType)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContent.Content, &info); err != nil {
+		return nil, fmt.Errorf("tsa: parse TSTInfo: %w", err)
+	}
+	return &info, nil
+}
+
+// freeText 编码 PKIFreeText；asn1 包不能为 []string 指定 UTF8String，逐项编码。
+func freeText(msgs ...string) asn1.RawValue {
+	var b []byte
+	for _, m := range msgs {
+		e, _ := asn1.MarshalWithParams(m, "utf8")
+		b = append(b, e...)
+	}
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: b}
+}
+
+func parseFreeText(v asn1.RawValue) []string {
+	var msgs []string
+	for rest := v.Bytes; len(rest) > 0; {
+		var s string
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &s); err != nil {
+			break
+		}
+		msgs = append(msgs, s)
+	}
+	return msgs
+}
@@ -0,0 +1,204 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cg917658910/win-pdf/internal/cms"
+)
+
+// 验证令牌签名所需的 SignedData 字段
+type testSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContent     struct {
+		ContentType asn1.ObjectIdentifier
+		Content     []byte `asn1:"explicit,optional,tag:0"`
+	}
+	Certificates asn1.RawValue `asn1:"optional,tag:0"`
+	SignerInfos  []struct {
+		Version            int
+		SID                asn1.RawValue
+		DigestAlgorithm    asn1.RawValue
+		SignedAttrs        asn1.RawValue `asn1:"tag:0"`
+		SignatureAlgorithm asn1.RawValue
+		Signature          []byte
+	} `asn1:"set"`
+}
+
+func newTestTSA(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewTestLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func request(t *testing.T, digest []byte, nonce *big.Int, alg asn1.ObjectIdentifier) []byte {
+	t.Helper()
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: alg}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// checkTokenSignature 用 TSA 证书验证令牌的签名，签名属性中的摘要须为 TSTInfo 的 SHA-256。
+func checkTokenSignature(t *testing.T, token []byte, cert *x509.Certificate) {
+	t.Helper()
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		t.Fatal(err)
+	}
+	var sd testSignedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		t.Fatalf("parse SignedData: %v", err)
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil || len(certs) == 0 || !certs[0].Equal(cert) {
+		t.Fatalf("token certificates do not contain the TSA certificate (%v)", err)
+	}
+	si := sd.SignerInfos[0]
+	signed := append([]byte(nil), si.SignedAttrs.FullBytes...)
+	signed[0] = 0x31 // [0] IMPLICIT 改回 SET OF
+	if err := cert.CheckSignature(x509.ECDSAWithSHA256, signed, si.Signature); err != nil {
+		t.Fatalf("token signature: %v", err)
+	}
+	want := sha256.Sum256(sd.EncapContent.Content)
+	if !bytes.Contains(si.SignedAttrs.Bytes, want[:]) {
+		t.Fatal("signed attributes do not carry the TSTInfo digest")
+	}
+}
+
+func TestLocalTimestamp(t *testing.T) {
+	l := newTestTSA(t)
+	digest := sha256.Sum256([]byte("signature value"))
+	before := time.Now().Add(-time.Second)
+	token, err := l.Timestamp(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := parseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest[:]) || !info.MessageImprint.HashAlgorithm.Algorithm.Equal(cms.OIDSHA256) {
+		t.Fatalf("message imprint = %x", info.MessageImprint.HashedMessage)
+	}
+	if info.GenTime.Before(before.Truncate(time.Second)) || info.GenTime.After(time.Now()) {
+		t.Fatalf("genTime %v out of range", info.GenTime)
+	}
+	if !info.Policy.Equal(localPolicy) || info.Accuracy.Seconds != localAccuracySecs {
+		t.Fatalf("policy %s accuracy %+v", info.Policy, info.Accuracy)
+	}
+	checkTokenSignature(t, token, l.Certificate())
+
+	if _, err := l.Timestamp([]byte("short")); err == nil {
+		t.Fatal("expected an error for a non SHA-256 digest")
+	}
+}
+
+func TestClientOverHTTP(t *testing.T) {
+	l := newTestTSA(t)
+	srv := httptest.NewServer(l)
+	defer srv.Close()
+
+	digest := sha256.Sum256([]byte("signature value"))
+	token, err := (&Client{URL: srv.URL}).Timestamp(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := parseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Nonce == nil {
+		t.Fatal("client did not send a nonce")
+	}
+	checkTokenSignature(t, token, l.Certificate())
+}
+
+func TestParseReply(t *testing.T) {
+	l := newTestTSA(t)
+	digest := sha256.Sum256([]byte("signature value"))
+	nonce := big.NewInt(42)
+	reply, err := l.Respond(request(t, digest[:], nonce, cms.OIDSHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseReply(reply, digest[:], nonce); err != nil {
+		t.Fatalf("valid reply: %v", err)
+	}
+
+	other := sha256.Sum256([]byte("other"))
+	if _, err := parseReply(reply, other[:], nonce); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Fatalf("digest mismatch: %v", err)
+	}
+	if _, err := parseReply(reply, digest[:], big.NewInt(43)); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("nonce mismatch: %v", err)
+	}
+	if _, err := parseReply([]byte("not DER"), digest[:], nonce); err == nil {
+		t.Fatal("expected an error for a malformed reply")
+	}
+}
+
+func TestRespondRejects(t *testing.T) {
+	l := newTestTSA(t)
+	digest := sha256.Sum256([]byte("signature value"))
+	sha1 := asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	for name, tc := range map[string]struct {
+		req  []byte
+		bit  int
+		text string
+	}{
+		"algorithm": {request(t, digest[:], nil, sha1), failBadAlg, "SHA-256"},
+		"length":    {request(t, digest[:10], nil, cms.OIDSHA256), failBadDataFormat, "digest length"},
+		"malformed": {[]byte{0x30, 0x00}, failBadDataFormat, "malformed"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reply, err := l.Respond(tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r timeStampResp
+			if _, err := asn1.Unmarshal(reply, &r); err != nil {
+				t.Fatal(err)
+			}
+			if r.Status.Status != statusRejection || r.Status.FailInfo.At(tc.bit) != 1 {
+				t.Fatalf("status %d failInfo %x", r.Status.Status, r.Status.FailInfo.Bytes)
+			}
+			if _, err := parseReply(reply, digest[:], nil); err == nil || !strings.Contains(err.Error(), tc.text) {
+				t.Fatalf("parseReply: %v", err)
+			}
+		})
+	}
+}
+
+func TestFreeTextRoundTrip(t *testing.T) {
+	msgs := []string{"rejected", "请求无效"}
+	b, err := asn1.Marshal(freeText(msgs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v asn1.RawValue
+	if _, err := asn1.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if got := parseFreeText(v); strings.Join(got, "|") != strings.Join(msgs, "|") {
+		t.Fatalf("parseFreeText = %q", got)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+
+	"github.com/cg917658910/win-pdf/internal/logging"
+	"github.com/wailsapp/wails/v2/pkg/logger"
+)
+
+// wailsLogger 把 Wails 运行时自身的日志转交给 logging，与应用日志写入同一文件。
+type wailsLogger struct{}
+
+var _ logger.Logger = wailsLogger{}
+
+func (wailsLogger) Print(message string)   { logging.Info(message, "source", "wails") }
+func (wailsLogger) Trace(message string)   { logging.Debug(message, "source", "wails") }
+func (wailsLogger) Debug(message string)   { logging.Debug(message, "source", "wails") }
+func (wailsLogger) Info(message string)    { logging.Info(message, "source", "wails") }
+func (wailsLogger) Warning(message string) { logging.Warn(message, "source", "wails") }
+func (wailsLogger) Error(message string)   { logging.Error(message, "source", "wails") }
+
+func (wailsLogger) Fatal(message string) {
+	logging.Error(message, "source", "wails", "fatal", true)
+	logging.Close()
+	os.Exit(1)
+}
@@ -2,7 +2,12 @@ package main
 
 import (
 	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
 
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -12,6 +17,14 @@ import (
 var assets embed.FS
 
 func main() {
+	// 日志写入配置目录下的 logs，失败时只输出到控制台
+	if dir, err := license.ConfigDir(); err == nil {
+		if err := logging.Init(filepath.Join(dir, "logs")); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+	defer logging.Close()
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -24,7 +37,8 @@ func main() {
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 		},
-		Menu: NewAppMenu(app),
+		Menu:   NewAppMenu(app),
+		Logger: wailsLogger{},
 		//BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup: app.startup,
 		Bind: []interface{}{
@@ -33,6 +47,6 @@ func main() {
 	})
 
 	if err != nil {
-		println("Error:", err.Error())
+		logging.Error("wails run", "err", err)
 	}
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  audit search [-q text] [-since date] [-until date] [-result ok|failed] [-machine code] [-limit n] [-json]
+  audit export -o file.csv|file.jsonl [-q text] [-since date] [-until date] [-result ok|failed] [-machine code]
+
+dates are YYYY-MM-DD or RFC 3339; -until with a plain date includes that whole day`)
+	os.Exit(2)
+}
+
+// parseDate 解析 YYYY-MM-DD（本地时间）或 RFC 3339 时间；endOfDay 时 YYYY-MM-DD 取当天结束。
+func parseDate(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("无效的日期 %q，应为 YYYY-MM-DD 或 RFC 3339 格式", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+	if cmd != "search" && cmd != "export" {
+		usage()
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.Usage = usage
+	text := fs.String("q", "", "match file name, path, hash, document ID, recipient or operator")
+	since := fs.String("since", "", "only entries started at or after this date")
+	until := fs.String("until", "", "only entries started at or before this date")
+	result := fs.String("result", "", "ok or failed")
+	machine := fs.String("machine", "", "only entries from the machine with this machine code (or machine ID)")
+	limit := fs.Int("limit", 50, "newest entries to show, 0 for all (search only)")
+	asJSON := fs.Bool("json", false, "print entries as JSON Lines (search only)")
+	output := fs.String("o", "", "export file; .csv exports CSV, anything else JSON Lines (export only)")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() > 0 {
+		usage()
+	}
+
+	q := engine.AuditQuery{Text: *text, Result: *result}
+	if m := strings.TrimSpace(*machine); m != "" {
+		// 审计记录只有本机标识；16 位的参数视为标识本身
+		q.MachineID = m
+		if len(m) != 16 {
+			q.MachineID = engine.MachineIDFor(m)
+		}
+	}
+	var err error
+	if q.Since, err = parseDate(*since, false); err == nil {
+		q.Until, err = parseDate(*until, true)
+	}
+	if err == nil && q.Result != "" && q.Result != engine.AuditOK && q.Result != engine.AuditFailed {
+		err = fmt.Errorf("-result 只能是 %s 或 %s", engine.AuditOK, engine.AuditFailed)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cmd == "export" {
+		if *output == "" {
+			usage()
+		}
+		n, err := engine.ExportAudit(*output, q)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "导出审计日志失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("已导出 %d 条记录到 %s\n", n, *output)
+		return
+	}
+
+	q.Limit = *limit
+	entries, err := engine.SearchAudit(q)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "读取审计日志失败: %v\n", err)
+		os.Exit(1)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintf(os.Stderr, "输出 JSON 失败: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("没有符合条件的记录")
+	}
+	for _, e := range entries {
+		fmt.Printf("%s  %-6s  %s\n", e.StartedAt.Local().Format("2006-01-02 15:04:05"), e.Result, e.Input)
+		if e.Result == engine.AuditOK {
+			fmt.Printf("    输出: %s\n    SHA-256: %s\n    文档 ID: %s\n", e.Output, e.OutputSHA256, e.DocumentID)
+		} else {
+			fmt.Printf("    错误: %s\n", e.Error)
+		}
+		if e.Recipient != "" {
+			fmt.Printf("    接收者: %s\n", e.Recipient)
+		}
+		fmt.Printf("    操作人: %s  机器标识: %s\n", e.Operator, e.MachineID)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+	"github.com/cg917658910/win-pdf/internal/license"
+	"github.com/cg917658910/win-pdf/internal/logging"
+)
+
+func main() {
+	out := flag.String("o", "", "output ZIP (default win-pdf-diagnostics-<time>.zip)")
+	inpw := flag.String("inpw", "", "open password for encrypted input files")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: diag [-o bundle.zip] [-inpw password] [failing.pdf ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// 与界面使用同一日志目录，诊断包才能带上界面的日志
+	dir, err := license.ConfigDir()
+	if err == nil {
+		err = logging.Init(filepath.Join(dir, "logs"))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "打开日志目录失败: %v\n", err)
+	}
+	defer logging.Close()
+
+	if *out == "" {
+		*out = "win-pdf-diagnostics-" + time.Now().Format("20060102-150405") + ".zip"
+	}
+	if err := engine.ExportDiagnostics(*out, flag.Args(), engine.Options{InputPassword: *inpw}); err != nil {
+		fmt.Fprintf(os.Stderr, "导出诊断信息失败: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("诊断信息已导出到 %s\n", *out)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  issued list [-q text] [-status active|expiring|expired|revoked] [-days n] [-all] [-json]
+  issued expiring [-days n]
+  issued calendar [-from date] [-to date]
+  issued show <id>
+  issued revoke [-reason text] <id>
+  issued renew [-days n] [-pw password] [-inpw password] [-signpw password] [-operator name] <id>
+
+<id> may be the first 8 or more characters of the document ID; dates are YYYY-MM-DD`)
+	os.Exit(2)
+}
+
+var statusNames = map[string]string{
+	engine.IssuedActive:   "有效",
+	engine.IssuedExpiring: "即将到期",
+	engine.IssuedExpired:  "已过期",
+	engine.IssuedRevoked:  "已撤销",
+}
+
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("无效的日期 %q，应为 YYYY-MM-DD 格式", s)
+	}
+	return t, nil
+}
+
+func printRecords(records []engine.IssuedRecord) {
+	if len(records) == 0 {
+		fmt.Println("没有符合条件的文档")
+	}
+	for _, r := range records {
+		d := r.Document
+		fmt.Printf("%s  %-8s  到期 %s  %s\n", d.ID[:min(12, len(d.ID))], statusNames[r.Status], d.EndTime.Local().Format("2006-01-02 15:04"), d.Output)
+		if d.Recipient != "" {
+			fmt.Printf("    接收者: %s\n", d.Recipient)
+		}
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	fs.Usage = usage
+	text := fs.String("q", "", "match file name, path, recipient, operator or document ID (list)")
+	status := fs.String("status", "", "active, expiring, expired or revoked (list)")
+	days := fs.Int("days", 0, "expiring window in days (list, expiring) or new validity in days (renew)")
+	all := fs.Bool("all", false, "include documents replaced by a renewal (list)")
+	asJSON := fs.Bool("json", false, "print records as JSON (list)")
+	from := fs.String("from", "", "first expiry date (calendar, default today)")
+	to := fs.String("to", "", "last expiry date (calendar, default 30 days from -from)")
+	reason := fs.String("reason", "", "revocation reason (revoke)")
+	pw := fs.String("pw", "", "open password for the renewed file (renew)")
+	inpw := fs.String("inpw", "", "open password of the source file (renew)")
+	signpw := fs.String("signpw", "", "password of the PKCS#12 signing certificate (renew)")
+	operator := fs.String("operator", "", "operator recorded for the renewal (renew)")
+	fs.Parse(os.Args[2:])
+
+	var err error
+	switch {
+	case cmd == "list" && fs.NArg() == 0:
+		var records []engine.IssuedRecord
+		records, err = engine.ListIssued(engine.IssuedQuery{Text: *text, Status: *status, ExpiringDays: *days, IncludeRenewed: *all})
+		if err == nil && *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(records)
+		} else if err == nil {
+			printRecords(records)
+		}
+	case cmd == "expiring" && fs.NArg() == 0:
+		var records []engine.IssuedRecord
+		if records, err = engine.ExpiringIssued(*days); err == nil {
+			printRecords(records)
+		}
+	case cmd == "calendar" && fs.NArg() == 0:
+		err = calendar(*from, *to)
+	case cmd == "show" && fs.NArg() == 1:
+		var d *engine.IssuedDocument
+		if d, err = engine.GetIssued(fs.Arg(0)); err == nil {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(d)
+		}
+	case cmd == "revoke" && fs.NArg() == 1:
+		if err = engine.RevokeIssued(fs.Arg(0), *reason); err == nil {
+			fmt.Printf("已撤销 %s\n", fs.Arg(0))
+		}
+	case cmd == "renew" && fs.NArg() == 1:
+		var d *engine.IssuedDocument
+		ro := engine.RenewOptions{ValidDays: *days, UserPassword: *pw, InputPassword: *inpw, SignPassword: *signpw, Operator: *operator}
+		if d, err = engine.RenewIssued(fs.Arg(0), ro); err == nil {
+			fmt.Printf("已续期：%s\n  新 ID: %s\n  有效期: %s 至 %s\n", d.Output, d.ID,
+				d.StartTime.Local().Format("2006-01-02 15:04"), d.EndTime.Local().Format("2006-01-02 15:04"))
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// calendar 按到期日分组列出区间内到期的文档。
+func calendar(fromStr, toStr string) error {
+	from, err := parseDay(fromStr)
+	if err != nil {
+		return err
+	}
+	if from.IsZero() {
+		y, m, d := time.Now().Date()
+		from = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+	to, err := parseDay(toStr)
+	if err != nil {
+		return err
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 30)
+	}
+	records, err := engine.ListIssued(engine.IssuedQuery{ExpiresFrom: from, ExpiresTo: to.AddDate(0, 0, 1).Add(-time.Nanosecond)})
+	if err != nil {
+		return err
+	}
+
+	byDay := map[string][]engine.IssuedRecord{}
+	for _, r := range records {
+		day := r.Document.EndTime.Local().Format("2006-01-02")
+		byDay[day] = append(byDay[day], r)
+	}
+	days := make([]string, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	if len(days) == 0 {
+		fmt.Printf("%s 至 %s 没有到期的文档\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+	for _, day := range days {
+		fmt.Printf("%s（%d）\n", day, len(byDay[day]))
+		for _, r := range byDay[day] {
+			d := r.Document
+			fmt.Printf("    %s  %-8s  %s", d.ID[:min(12, len(d.ID))], statusNames[r.Status], d.Output)
+			if d.Recipient != "" {
+				fmt.Printf("（%s）", d.Recipient)
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+)
+
+func main() {
+	export := flag.String("export", "", "write every vault entry as JSON to this file ('-' for stdout)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ownerpw [-export file] [file.pdf ...]")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nThe vault key is protected with DPAPI for the current Windows user, or with the passphrase in %s when it is set.\n", engine.VaultPassphraseEnv)
+	}
+	flag.Parse()
+
+	if *export == "" && flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *export != "" {
+		entries, err := engine.ExportOwnerPasswords()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "读取密码库失败: %v\n", err)
+			os.Exit(1)
+		}
+		out := os.Stdout
+		if *export != "-" {
+			f, err := os.OpenFile(*export, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "创建导出文件失败: %v\n", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			out = f
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "输出 JSON 失败: %v\n", err)
+			os.Exit(1)
+		}
+		if *export != "-" {
+			fmt.Printf("已导出 %d 条记录到 %s\n", len(entries), *export)
+		}
+	}
+
+	failed := false
+	for _, path := range flag.Args() {
+		e, err := engine.LookupOwnerPassword(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Printf("%s\n  所有者密码: %s\n  文档 ID: %s\n  生成时间: %s\n", path, e.OwnerPassword, e.DocumentID, e.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
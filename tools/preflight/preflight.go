@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+)
+
+func main() {
+	files := flag.String("files", "", "PDF files separated by ';' or ',' (positional arguments are also accepted)")
+	asJSON := flag.Bool("json", false, "print reports as JSON")
+	embedFont := flag.Bool("embed-font", false, "include an embedded font subset in the output size estimate")
+	signedPolicy := flag.String("signed-input", engine.SignedInputWarn, "policy for signed inputs: warn, refuse or incremental")
+	outputMode := flag.String("output-mode", engine.OutputRewrite, "output mode: rewrite or incremental")
+	password := flag.String("password", "", "open password for encrypted inputs")
+	passwordFile := flag.String("password-file", "", "password list: one password per line, or file name<TAB>password")
+	preset := flag.String("preset", "", "apply a saved preset (see tools/preset); other flags override it")
+	flag.Parse()
+
+	list := *files
+	if flag.NArg() > 0 {
+		list = strings.Join(append([]string{list}, flag.Args()...), ";")
+	}
+	opts := engine.Options{Files: list, InputPassword: *password, InputPasswordFile: *passwordFile}
+	if *preset != "" {
+		p, err := engine.GetPreset(*preset)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts = p.Apply(opts)
+	}
+	// 显式指定的参数优先于预设
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "embed-font":
+			opts.EmbedFont = *embedFont
+		case "signed-input":
+			opts.SignedInputPolicy = *signedPolicy
+		case "output-mode":
+			opts.OutputMode = *outputMode
+		}
+	})
+	if *preset == "" {
+		opts.EmbedFont, opts.SignedInputPolicy, opts.OutputMode = *embedFont, *signedPolicy, *outputMode
+	}
+	reports, err := engine.PreflightBatch(opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "必须指定 -files 或文件参数")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fmt.Fprintf(os.Stderr, "输出 JSON 失败: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		for _, r := range reports {
+			printReport(r)
+		}
+	}
+
+	// 存在无法处理的文档时返回非零退出码，便于脚本判断
+	for _, r := range reports {
+		if !r.CanProcess {
+			os.Exit(1)
+		}
+	}
+}
+
+func printReport(r engine.PreflightReport) {
+	status := "可处理"
+	if !r.CanProcess {
+		status = "无法处理"
+	}
+	fmt.Printf("%s [%s]\n", r.File, status)
+	fmt.Printf("  大小: %d 字节, 页数: %d, 版本: %s\n", r.Size, r.Pages, r.Version)
+	if r.Encrypted {
+		fmt.Printf("  加密: %s\n", r.EncryptionHandler)
+	}
+	fmt.Printf("  预计内存: %d 字节, 预计输出: %d 字节\n", r.EstimatedMemory, r.EstimatedOutputSize)
+	for _, w := range r.Warnings {
+		fmt.Printf("  警告: %s\n", w)
+	}
+	for _, a := range r.Actions {
+		fmt.Printf("  建议: %s\n", a)
+	}
+	fmt.Println()
+}
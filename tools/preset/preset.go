@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	engine "github.com/cg917658910/win-pdf/internal/engine/v2"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  preset list
+  preset show <name>
+  preset delete <name>
+  preset import <file.json>
+  preset export <file.json> [name ...]`)
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+	}
+
+	var err error
+	switch cmd := args[0]; {
+	case cmd == "list" && len(args) == 1:
+		var presets []engine.Preset
+		if presets, err = engine.ListPresets(); err == nil {
+			if len(presets) == 0 {
+				fmt.Println("没有已保存的预设")
+			}
+			for _, p := range presets {
+				validity := "使用固定的开始与结束时间"
+				if p.ValidDays > 0 {
+					validity = fmt.Sprintf("有效期 %d 天", p.ValidDays)
+				}
+				fmt.Printf("%s\t%s\t%s\n", p.Name, validity, p.Description)
+			}
+		}
+	case cmd == "show" && len(args) == 2:
+		var p *engine.Preset
+		if p, err = engine.GetPreset(args[1]); err == nil {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(p)
+		}
+	case cmd == "delete" && len(args) == 2:
+		if err = engine.DeletePreset(args[1]); err == nil {
+			fmt.Printf("已删除预设 %s\n", args[1])
+		}
+	case cmd == "import" && len(args) == 2:
+		var names []string
+		if names, err = engine.ImportPresets(args[1]); err == nil {
+			fmt.Printf("已导入 %d 个预设: %v\n", len(names), names)
+		}
+	case cmd == "export" && len(args) >= 2:
+		if err = engine.ExportPresets(args[1], args[2:]...); err == nil {
+			fmt.Printf("已导出到 %s\n", args[1])
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
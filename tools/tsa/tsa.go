@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/cg917658910/win-pdf/internal/cms"
+	"github.com/cg917658910/win-pdf/internal/tsa"
+)
+
+// 本地 RFC 3161 时间戳服务，供开发与无外网环境使用；签名时把 TSAURL 指向 http://<addr>/。
+func main() {
+	addr := flag.String("addr", "127.0.0.1:3161", "listen address")
+	certPath := flag.String("cert", "", "TSA certificate (PKCS#12 or PEM chain); a temporary self-signed certificate is used when empty")
+	keyPath := flag.String("key", "", "PEM private key (when not contained in -cert)")
+	password := flag.String("password", "", "PKCS#12 password")
+	export := flag.String("export-cert", "", "write the TSA certificate as PEM to this file, so verifiers can trust it")
+	flag.Parse()
+
+	var (
+		local *tsa.Local
+		err   error
+	)
+	if *certPath != "" {
+		var s cms.Signer
+		if s, err = cms.LoadSigner(*certPath, *keyPath, *password); err == nil {
+			local = tsa.NewLocal(s)
+		}
+	} else {
+		local, err = tsa.NewTestLocal()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载 TSA 证书失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *export != "" {
+		data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: local.Certificate().Raw})
+		if err := os.WriteFile(*export, data, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "写出 TSA 证书失败: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("TSA %q listening on http://%s/\n", local.Certificate().Subject.CommonName, *addr)
+	if err := http.ListenAndServe(*addr, local); err != nil {
+		fmt.Fprintf(os.Stderr, "TSA 服务退出: %v\n", err)
+		os.Exit(1)
+	}
+}